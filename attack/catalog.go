@@ -0,0 +1,245 @@
+// Package attack holds the MITRE ATT&CK technique catalog rules are mapped to. The manager ships the enterprise
+// techniques of ATT&CK v14 and can load another catalog from a STIX bundle or a JSON technique list
+package attack
+
+import (
+	_ "embed"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// Tactic is an ATT&CK tactic, identified by its short name such as credential-access
+	Tactic struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	// Technique is an ATT&CK technique or sub-technique, such as T1078 or T1078.004
+	Technique struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		// Tactics are the short names of the tactics of the technique, sub-techniques inherit them from their parent
+		Tactics []string `json:"tactics,omitempty"`
+		// Parent is the ID of the technique of a sub-technique
+		Parent string `json:"parent,omitempty"`
+	}
+	Catalog struct {
+		techniques map[string]*Technique
+	}
+	// stixBundle is the part of a STIX 2 bundle of the ATT&CK releases the catalog is read from
+	stixBundle struct {
+		Type    string `json:"type"`
+		Objects []struct {
+			Type               string `json:"type"`
+			Name               string `json:"name"`
+			Revoked            bool   `json:"revoked"`
+			Deprecated         bool   `json:"x_mitre_deprecated"`
+			ExternalReferences []struct {
+				SourceName string `json:"source_name"`
+				ExternalID string `json:"external_id"`
+			} `json:"external_references"`
+			KillChainPhases []struct {
+				KillChainName string `json:"kill_chain_name"`
+				PhaseName     string `json:"phase_name"`
+			} `json:"kill_chain_phases"`
+		} `json:"objects"`
+	}
+)
+
+// Tactics are the enterprise tactics, in the order of the ATT&CK matrix
+var Tactics = []Tactic{
+	{ID: "reconnaissance", Name: "Reconnaissance"},
+	{ID: "resource-development", Name: "Resource Development"},
+	{ID: "initial-access", Name: "Initial Access"},
+	{ID: "execution", Name: "Execution"},
+	{ID: "persistence", Name: "Persistence"},
+	{ID: "privilege-escalation", Name: "Privilege Escalation"},
+	{ID: "defense-evasion", Name: "Defense Evasion"},
+	{ID: "credential-access", Name: "Credential Access"},
+	{ID: "discovery", Name: "Discovery"},
+	{ID: "lateral-movement", Name: "Lateral Movement"},
+	{ID: "collection", Name: "Collection"},
+	{ID: "command-and-control", Name: "Command and Control"},
+	{ID: "exfiltration", Name: "Exfiltration"},
+	{ID: "impact", Name: "Impact"},
+}
+
+var (
+	//go:embed enterprise.json
+	enterprise []byte
+
+	techniqueIDRegexp = regexp.MustCompile(`^T[0-9]{4}(\.[0-9]{3})?$`)
+
+	mu      sync.RWMutex
+	current = mustParse(enterprise)
+)
+
+func mustParse(data []byte) *Catalog {
+	c, err := Parse(data)
+	if err != nil {
+		panic(errors.Wrap(err, "parsing the embedded ATT&CK catalog"))
+	}
+	return c
+}
+
+// Current returns the catalog in use, the embedded catalog unless another one was set
+func Current() *Catalog {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetCatalog replaces the catalog in use
+func SetCatalog(c *Catalog) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = c
+}
+
+// LoadFile reads a catalog from a STIX bundle of an ATT&CK release or from a JSON list of techniques
+func LoadFile(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading the ATT&CK catalog")
+	}
+	return Parse(data)
+}
+
+// Parse reads a catalog from a STIX bundle or from a JSON list of techniques. The sub-techniques of the list get the
+// tactics of their parent when they have none
+func Parse(data []byte) (*Catalog, error) {
+	var techniques []*Technique
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "{") {
+		var err error
+		if techniques, err = parseSTIX(data); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, &techniques); err != nil {
+		return nil, errors.Wrap(err, "parsing the ATT&CK technique list")
+	}
+	c := &Catalog{techniques: make(map[string]*Technique, len(techniques))}
+	for i, t := range techniques {
+		if !techniqueIDRegexp.MatchString(t.ID) {
+			return nil, errors.Errorf("technique %v: id '%v' is not an ATT&CK technique ID", i, t.ID)
+		}
+		if parent, _, ok := strings.Cut(t.ID, "."); ok {
+			t.Parent = parent
+		}
+		c.techniques[t.ID] = t
+	}
+	for _, t := range c.techniques {
+		if t.Parent == "" || len(t.Tactics) > 0 {
+			continue
+		}
+		parent, ok := c.techniques[t.Parent]
+		if !ok {
+			return nil, errors.Errorf("sub-technique %v has no parent technique %v", t.ID, t.Parent)
+		}
+		t.Tactics = parent.Tactics
+	}
+	return c, nil
+}
+
+// parseSTIX reads the attack patterns of a bundle, skipping the revoked and deprecated ones
+func parseSTIX(data []byte) ([]*Technique, error) {
+	var bundle stixBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, errors.Wrap(err, "parsing the ATT&CK STIX bundle")
+	}
+	if bundle.Type != "bundle" {
+		return nil, errors.Errorf("the ATT&CK catalog is a STIX object of type '%v', not a bundle", bundle.Type)
+	}
+	var techniques []*Technique
+	for _, obj := range bundle.Objects {
+		if obj.Type != "attack-pattern" || obj.Revoked || obj.Deprecated {
+			continue
+		}
+		t := &Technique{Name: obj.Name}
+		for _, ref := range obj.ExternalReferences {
+			if ref.SourceName == "mitre-attack" {
+				t.ID = ref.ExternalID
+			}
+		}
+		for _, phase := range obj.KillChainPhases {
+			if phase.KillChainName == "mitre-attack" {
+				t.Tactics = append(t.Tactics, phase.PhaseName)
+			}
+		}
+		if t.ID != "" {
+			techniques = append(techniques, t)
+		}
+	}
+	return techniques, nil
+}
+
+// IsTechniqueID reports whether the ID is formatted as a technique or sub-technique ID
+func IsTechniqueID(id string) bool {
+	return techniqueIDRegexp.MatchString(id)
+}
+
+func (c *Catalog) Technique(id string) (*Technique, bool) {
+	t, ok := c.techniques[id]
+	return t, ok
+}
+
+// Techniques returns the techniques and sub-techniques sorted by ID
+func (c *Catalog) Techniques() []*Technique {
+	techniques := make([]*Technique, 0, len(c.techniques))
+	for _, t := range c.techniques {
+		techniques = append(techniques, t)
+	}
+	sort.Slice(techniques, func(i, j int) bool { return techniques[i].ID < techniques[j].ID })
+	return techniques
+}
+
+// Tactics returns the tactics of the techniques: the enterprise tactics in matrix order, then the other tactics
+// of a loaded catalog by name
+func (c *Catalog) Tactics() []Tactic {
+	known := make(map[string]bool, len(Tactics))
+	for _, t := range Tactics {
+		known[t.ID] = true
+	}
+	var others []string
+	for _, t := range c.techniques {
+		for _, tactic := range t.Tactics {
+			if !known[tactic] {
+				known[tactic] = true
+				others = append(others, tactic)
+			}
+		}
+	}
+	sort.Strings(others)
+	tactics := append([]Tactic{}, Tactics...)
+	for _, tactic := range others {
+		tactics = append(tactics, Tactic{ID: tactic, Name: tactic})
+	}
+	return tactics
+}
+
+// TechniqueName returns the name of the technique of an ID, the parent technique of a sub-technique
+func (c *Catalog) TechniqueName(id string) string {
+	t, ok := c.techniques[id]
+	if !ok {
+		return ""
+	}
+	if t.Parent != "" {
+		if parent, ok := c.techniques[t.Parent]; ok {
+			return parent.Name
+		}
+	}
+	return t.Name
+}
+
+// SubTechniqueName returns the name of a sub-technique, empty for a technique
+func (c *Catalog) SubTechniqueName(id string) string {
+	t, ok := c.techniques[id]
+	if !ok || t.Parent == "" {
+		return ""
+	}
+	return t.Name
+}
@@ -0,0 +1,100 @@
+package attack
+
+import "sort"
+
+type (
+	// Detection is a rule mapped to a technique, Tactic narrows it to one of the tactics of the technique
+	Detection struct {
+		Kind      string `json:"kind"`
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Technique string `json:"technique"`
+		Tactic    string `json:"tactic,omitempty"`
+	}
+	// Coverage lists the detections by tactic and technique, the detections without a known technique are unmapped
+	Coverage struct {
+		Tactics  []*TacticCoverage `json:"tactics"`
+		Unmapped []*Detection      `json:"unmapped"`
+	}
+	TacticCoverage struct {
+		Tactic string `json:"tactic"`
+		Name   string `json:"name"`
+		// CoveredTechniques counts the techniques of the tactic detected directly or through a sub-technique,
+		// out of the TotalTechniques of the tactic in the catalog
+		CoveredTechniques int                  `json:"covered_techniques"`
+		TotalTechniques   int                  `json:"total_techniques"`
+		Techniques        []*TechniqueCoverage `json:"techniques"`
+	}
+	TechniqueCoverage struct {
+		ID         string       `json:"id"`
+		Name       string       `json:"name"`
+		Detections []*Detection `json:"detections"`
+	}
+)
+
+// Coverage groups the detections by the tactics of their technique, the techniques of a tactic sorted by ID
+func (c *Catalog) Coverage(detections []*Detection) *Coverage {
+	coverage := &Coverage{Unmapped: []*Detection{}}
+	techniques := make(map[string]map[string]*TechniqueCoverage)
+	for _, d := range detections {
+		t, ok := c.techniques[d.Technique]
+		if !ok {
+			coverage.Unmapped = append(coverage.Unmapped, d)
+			continue
+		}
+		tactics := t.Tactics
+		if d.Tactic != "" {
+			tactics = []string{d.Tactic}
+		}
+		for _, tactic := range tactics {
+			if techniques[tactic] == nil {
+				techniques[tactic] = make(map[string]*TechniqueCoverage)
+			}
+			tc, ok := techniques[tactic][t.ID]
+			if !ok {
+				tc = &TechniqueCoverage{ID: t.ID, Name: c.fullName(t)}
+				techniques[tactic][t.ID] = tc
+			}
+			tc.Detections = append(tc.Detections, d)
+		}
+	}
+	totals := make(map[string]int)
+	for _, t := range c.techniques {
+		if t.Parent != "" {
+			continue
+		}
+		for _, tactic := range t.Tactics {
+			totals[tactic]++
+		}
+	}
+	for _, tactic := range c.Tactics() {
+		tacticCoverage := &TacticCoverage{
+			Tactic:          tactic.ID,
+			Name:            tactic.Name,
+			TotalTechniques: totals[tactic.ID],
+			Techniques:      []*TechniqueCoverage{},
+		}
+		covered := make(map[string]bool)
+		for id, tc := range techniques[tactic.ID] {
+			tacticCoverage.Techniques = append(tacticCoverage.Techniques, tc)
+			if parent := c.techniques[id].Parent; parent != "" {
+				id = parent
+			}
+			covered[id] = true
+		}
+		sort.Slice(tacticCoverage.Techniques, func(i, j int) bool {
+			return tacticCoverage.Techniques[i].ID < tacticCoverage.Techniques[j].ID
+		})
+		tacticCoverage.CoveredTechniques = len(covered)
+		coverage.Tactics = append(coverage.Tactics, tacticCoverage)
+	}
+	return coverage
+}
+
+// fullName names a sub-technique after its technique, such as Valid Accounts: Cloud Accounts
+func (c *Catalog) fullName(t *Technique) string {
+	if sub := c.SubTechniqueName(t.ID); sub != "" {
+		return c.TechniqueName(t.ID) + ": " + sub
+	}
+	return t.Name
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"flink_ueba_manager/util"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"regexp"
+	"strings"
 )
 
 const (
@@ -11,6 +14,9 @@ const (
 	DefServiceHost     = "0.0.0.0"
 	DefServicePort     = 9999
 	DefEndpointGetJobs = "http://localhost:9090/api/v2/worker/stateless/jobs"
+	// DefKafkaGroupID is the consumer group template, {kind} and {id} are replaced by the job kind and ID
+	DefKafkaGroupID = "ueba-{kind}-{id}"
+	DefSecretsDir   = "./data/secrets"
 )
 
 var AppConfig *Config
@@ -24,7 +30,57 @@ type (
 		Service         *NodeConfig      `mapstructure:"service" json:"service"`
 		Endpoint        *Endpoint        `mapstructure:"endpoint" json:"endpoint"`
 		FlinkSQLGateway *FlinkSQLGateway `mapstructure:"flink_sql_gateway" json:"flink_sql_gateway"`
-		KafkaGroupID    string           `mapstructure:"kafka_group_id" json:"kafka_group_id"`
+		// KafkaGroupID is the consumer group template of job sources, see DefKafkaGroupID
+		KafkaGroupID string     `mapstructure:"kafka_group_id" json:"kafka_group_id"`
+		Watchlist    *Watchlist `mapstructure:"watchlist" json:"watchlist"`
+		// SecretsDir is the directory the files of file: secret references are read from, their paths are
+		// relative to it
+		SecretsDir string `mapstructure:"secrets_dir" json:"secrets_dir"`
+		// AttackCatalog is a STIX bundle or a JSON technique list replacing the embedded ATT&CK catalog
+		AttackCatalog string            `mapstructure:"attack_catalog" json:"attack_catalog"`
+		Severity      *SeverityTaxonomy `mapstructure:"severity" json:"severity"`
+		// RuleGrouping compiles the rules sharing a profile predictor source into one job, each rule gets its own
+		// job otherwise. Switching it stops the rule jobs of the other mode on the first pull, and the new jobs read
+		// the sources under new consumer groups from their startup mode, so group-offsets replays the topics.
+		// A group is redeployed when its rules change, restoring a savepoint of its previous job taken into the
+		// state.savepoints.dir of the cluster. The state of the operators whose place in the job graph changed is
+		// reset, see the state_warning of the rule groups
+		RuleGrouping bool `mapstructure:"rule_grouping" json:"rule_grouping"`
+	}
+
+	// SeverityTaxonomy is the shared scale of the severities of rules and alerts
+	SeverityTaxonomy struct {
+		// Levels are the severity levels from the lowest to the highest. A severity may also be given as the
+		// 1-based rank of its level, 3 being the third level
+		Levels []*SeverityLevel `mapstructure:"levels" json:"levels"`
+		// MaxRiskScore bounds the risk scores of the alerts
+		MaxRiskScore int `mapstructure:"max_risk_score" json:"max_risk_score"`
+	}
+	SeverityLevel struct {
+		Name string `mapstructure:"name" json:"name"`
+		// Score is the risk score of the alerts of the rules of the level without a risk score
+		Score   int      `mapstructure:"score" json:"score"`
+		Aliases []string `mapstructure:"aliases" json:"aliases"`
+	}
+
+	// Watchlist configures how watchlists are stored by the manager and materialized for the rule jobs
+	Watchlist struct {
+		// StoragePath is the file the watchlists are persisted to
+		StoragePath string `mapstructure:"storage_path" json:"storage_path"`
+		// URL is the PostgreSQL database of the watchlist table, such as jdbc:postgresql://db:5432/ueba. The
+		// manager writes the watchlists to the table and the rule jobs look it up with the jdbc connector, so it
+		// must be reachable from the Flink cluster. WATCHLIST filters are rejected when it is not set
+		URL string `mapstructure:"url" json:"url"`
+		// Table is the watchlist table, created by the manager when it does not exist
+		Table    string `mapstructure:"table" json:"table"`
+		Username string `mapstructure:"username" json:"username"`
+		// Password is a secret reference, env:NAME or file:PATH in the secrets directory
+		Password string `mapstructure:"password" json:"password"`
+		// CacheTTL is how long the rule jobs cache the looked up watchlist values, so an update takes effect
+		// within it
+		CacheTTL string `mapstructure:"cache_ttl" json:"cache_ttl"`
+		// CacheMaxRows bounds the lookup cache of every rule job
+		CacheMaxRows int `mapstructure:"cache_max_rows" json:"cache_max_rows"`
 	}
 
 	FlinkSQLGateway struct {
@@ -36,8 +92,11 @@ type (
 		Port int    `mapstructure:"port" json:"port"`
 	}
 	Endpoint struct {
-		BehaviorGetJob string `mapstructure:"behavior_get_job" json:"behavior_get_job"`
-		RuleGetJob     string `mapstructure:"rule_get_job" json:"rule_get_job"`
+		BehaviorGetJob    string `mapstructure:"behavior_get_job" json:"behavior_get_job"`
+		RuleGetJob        string `mapstructure:"rule_get_job" json:"rule_get_job"`
+		CorrelationGetJob string `mapstructure:"correlation_get_job" json:"correlation_get_job"`
+		AggregationGetJob string `mapstructure:"aggregation_get_job" json:"aggregation_get_job"`
+		RiskGetJob        string `mapstructure:"risk_get_job" json:"risk_get_job"`
 	}
 )
 
@@ -54,17 +113,30 @@ func LoadFile(filepath string) (*Config, error) {
 		}
 	}
 	config := DefaultConfig()
+	if v.IsSet("severity.levels") {
+		// the configured levels replace the default ones rather than being merged into them
+		config.Severity.Levels = nil
+	}
 	if err := v.Unmarshal(&config); err != nil {
 		return nil, errors.Wrap(err, "viper.Unmarshal")
 	}
+	if err := config.Severity.Validate(); err != nil {
+		return nil, err
+	}
+	if err := config.Watchlist.Validate(); err != nil {
+		return nil, err
+	}
 	AppConfig = config
 	return config, nil
 }
 
 func DefaultEndpoint() *Endpoint {
 	return &Endpoint{
-		BehaviorGetJob: DefEndpointGetJobs,
-		RuleGetJob:     DefEndpointGetJobs,
+		BehaviorGetJob:    DefEndpointGetJobs,
+		RuleGetJob:        DefEndpointGetJobs,
+		CorrelationGetJob: DefEndpointGetJobs,
+		AggregationGetJob: DefEndpointGetJobs,
+		RiskGetJob:        DefEndpointGetJobs,
 	}
 }
 
@@ -81,10 +153,98 @@ func DefaultFlinkSQLGatewayConfig() *FlinkSQLGateway {
 	}
 }
 
+func DefaultWatchlistConfig() *Watchlist {
+	return &Watchlist{
+		StoragePath:  "./data/watchlists.json",
+		Table:        "ueba_watchlist",
+		CacheTTL:     "1m",
+		CacheMaxRows: 10000,
+	}
+}
+
+// watchlistTableRegexp matches the name of the watchlist table, optionally qualified by its schema
+var watchlistTableRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// HasTable reports whether the watchlist table is configured
+func (w *Watchlist) HasTable() bool {
+	return w.URL != ""
+}
+
+// Validate checks the watchlist table, when it is configured
+func (w *Watchlist) Validate() error {
+	if !w.HasTable() {
+		return nil
+	}
+	if !strings.HasPrefix(w.URL, "jdbc:postgresql://") {
+		return errors.New("watchlist: url must be a jdbc:postgresql:// URL")
+	}
+	if !watchlistTableRegexp.MatchString(w.Table) {
+		return errors.Errorf("watchlist: table '%v' is not a valid table name", w.Table)
+	}
+	if w.Username == "" && w.Password != "" {
+		return errors.New("watchlist: username is required when a password is set")
+	}
+	if w.Username != "" && !util.IsSecretRef(w.Password) {
+		return errors.New("watchlist: password must be a secret reference such as env:NAME or file:PATH")
+	}
+	if d, err := util.ParseDurationExtended(w.CacheTTL); err != nil || d <= 0 {
+		return errors.Errorf("watchlist: cache_ttl '%v' is not a valid duration", w.CacheTTL)
+	}
+	if w.CacheMaxRows <= 0 {
+		return errors.New("watchlist: cache_max_rows must be positive")
+	}
+	return nil
+}
+
+func DefaultSeverityTaxonomy() *SeverityTaxonomy {
+	return &SeverityTaxonomy{
+		Levels: []*SeverityLevel{
+			{Name: "informational", Score: 10, Aliases: []string{"info"}},
+			{Name: "low", Score: 25},
+			{Name: "medium", Score: 50, Aliases: []string{"moderate"}},
+			{Name: "high", Score: 75},
+			{Name: "critical", Score: 100},
+		},
+		MaxRiskScore: 100,
+	}
+}
+
+// Validate checks the levels have distinct names and aliases and scores within the maximum risk score
+func (t *SeverityTaxonomy) Validate() error {
+	if len(t.Levels) == 0 {
+		return errors.New("severity: at least one level is required")
+	}
+	if t.MaxRiskScore <= 0 {
+		return errors.New("severity: max_risk_score must be positive")
+	}
+	names := make(map[string]bool)
+	for i, level := range t.Levels {
+		if level.Name == "" {
+			return errors.Errorf("severity: level %v has no name", i)
+		}
+		for _, name := range append([]string{level.Name}, level.Aliases...) {
+			key := strings.ToLower(name)
+			if names[key] {
+				return errors.Errorf("severity: name '%v' is used by several levels", name)
+			}
+			names[key] = true
+		}
+		if level.Score < 0 || level.Score > t.MaxRiskScore {
+			return errors.Errorf("severity: score %v of level %v must be between 0 and %v", level.Score, level.Name, t.MaxRiskScore)
+		}
+	}
+	return nil
+}
+
 func DefaultConfig() *Config {
 	return &Config{
-		Service:  DefaultNodeConfig(),
-		Endpoint: DefaultEndpoint(),
+		Service:      DefaultNodeConfig(),
+		Endpoint:     DefaultEndpoint(),
+		KafkaGroupID: DefKafkaGroupID,
+		Watchlist:    DefaultWatchlistConfig(),
+		SecretsDir:   DefSecretsDir,
+		Severity:     DefaultSeverityTaxonomy(),
+		RuleGrouping: false,
 	}
 }
 
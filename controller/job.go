@@ -2,6 +2,7 @@ package controller
 
 import (
 	"flink_ueba_manager/manager"
+	"flink_ueba_manager/view"
 	"github.com/gin-gonic/gin"
 	"net/http"
 )
@@ -21,8 +22,33 @@ func (s *JobHandler) MakeHandler(g *gin.RouterGroup) {
 	group.GET("", s.getAllJobs)
 	group.GET("/succeed", s.getAllJobs)
 	group.GET("/failed", s.getAllJobs)
+	group.POST("/plan", s.planJobs)
 }
 
 func (s *JobHandler) getAllJobs(c *gin.Context) {
 	c.Status(http.StatusOK)
 }
+
+// planJobs renders the SQL plans of the jobs in the request body, or of the JobHub jobs when the body is empty.
+// Pass explain=true to validate the plans through the SQL gateway
+func (s *JobHandler) planJobs(c *gin.Context) {
+	explain := c.Query("explain") == "true"
+	var req view.PlanRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(req.BehaviorJobs) == 0 && len(req.RuleJobs) == 0 && len(req.CorrelationJobs) == 0 && len(req.AggregationJobs) == 0 &&
+		len(req.RiskJobs) == 0 {
+		plans, err := s.JobManager.PlanHubJobs(explain)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, plans)
+		return
+	}
+	c.JSON(http.StatusOK, s.JobManager.PlanJobs(req.BehaviorJobs, req.RuleJobs, req.CorrelationJobs, req.AggregationJobs, req.RiskJobs, explain))
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"flink_ueba_manager/manager"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+type RuleHandler struct {
+	JobManager *manager.JobManager
+}
+
+func NewRuleHandler(jobManager *manager.JobManager) *RuleHandler {
+	return &RuleHandler{
+		JobManager: jobManager,
+	}
+}
+
+func (s *RuleHandler) MakeHandler(g *gin.RouterGroup) {
+	group := g.Group("/rules")
+	group.GET("/coverage", s.getCoverage)
+	group.GET("/groups", s.getGroups)
+	group.GET("/:id/group", s.getGroup)
+	group.POST("/backtest", s.backtest)
+}
+
+// getCoverage lists the rules of the JobHub by the ATT&CK tactic and technique they detect, for detection coverage
+// reviews. Rules without a technique of the catalog are listed as unmapped
+func (s *RuleHandler) getCoverage(c *gin.Context) {
+	coverage, err := s.JobManager.RuleCoverage()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, coverage)
+}
+
+// getGroups lists the deployed rule groups, each running the rules of a predictor source in one Flink job
+func (s *RuleHandler) getGroups(c *gin.Context) {
+	c.JSON(http.StatusOK, s.JobManager.RuleGroups())
+}
+
+// getGroup returns the deployed group of a rule
+func (s *RuleHandler) getGroup(c *gin.Context) {
+	status, ok := s.JobManager.RuleGroupOf(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("rule %v is not in a deployed group", c.Param("id"))})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// backtest replays a rule over a bounded range of its predictor topic in batch mode and reports how many alerts it
+// would have emitted with samples of them, before the rule is enabled. Nothing is written to the rule sink
+func (s *RuleHandler) backtest(c *gin.Context) {
+	var req view.RuleBacktestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, err := s.JobManager.BacktestRule(&req)
+	var validationErr *view.ValidationError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, result)
+	case errors.Is(err, manager.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"flink_ueba_manager/manager"
+	"flink_ueba_manager/view"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+type WatchlistHandler struct {
+	WatchlistManager *manager.WatchlistManager
+}
+
+func NewWatchlistHandler(watchlistManager *manager.WatchlistManager) *WatchlistHandler {
+	return &WatchlistHandler{
+		WatchlistManager: watchlistManager,
+	}
+}
+
+func (s *WatchlistHandler) MakeHandler(g *gin.RouterGroup) {
+	group := g.Group("/watchlists")
+	group.GET("", s.listWatchlists)
+	group.GET("/:name", s.getWatchlist)
+	group.POST("", s.createWatchlist)
+	group.PUT("/:name", s.updateWatchlist)
+	group.PATCH("/:name", s.patchWatchlist)
+	group.DELETE("/:name", s.deleteWatchlist)
+}
+
+func (s *WatchlistHandler) listWatchlists(c *gin.Context) {
+	c.JSON(http.StatusOK, s.WatchlistManager.List())
+}
+
+func (s *WatchlistHandler) getWatchlist(c *gin.Context) {
+	w, err := s.WatchlistManager.Get(c.Param("name"))
+	if err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (s *WatchlistHandler) createWatchlist(c *gin.Context) {
+	var req view.WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := s.WatchlistManager.Create(&req)
+	if err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, w)
+}
+
+// updateWatchlist replaces the values of a watchlist. Pass the version read to reject the update when the
+// watchlist has changed since
+func (s *WatchlistHandler) updateWatchlist(c *gin.Context) {
+	var req view.WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := s.WatchlistManager.Update(c.Param("name"), &req)
+	if err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+// patchWatchlist adds values to a watchlist and removes values from it
+func (s *WatchlistHandler) patchWatchlist(c *gin.Context) {
+	var patch view.WatchlistPatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := s.WatchlistManager.Patch(c.Param("name"), &patch)
+	if err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (s *WatchlistHandler) deleteWatchlist(c *gin.Context) {
+	if err := s.WatchlistManager.Delete(c.Param("name")); err != nil {
+		watchlistError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// watchlistError responds with the status of the error, invalid requests are rejected by validation errors
+func watchlistError(c *gin.Context, err error) {
+	var validationErr *view.ValidationError
+	switch {
+	case errors.Is(err, manager.ErrWatchlistNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, manager.ErrWatchlistExists), errors.Is(err, manager.ErrWatchlistVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
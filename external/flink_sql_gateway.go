@@ -10,6 +10,9 @@ import (
 	"github.com/sirupsen/logrus"
 	"io"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,6 +34,8 @@ type (
 		ResultType string
 		Result     interface{}
 		JobID      string
+		// NextToken is the token of the next page of the result, -1 when the result has no next page
+		NextToken int
 	}
 )
 
@@ -136,17 +141,114 @@ func (f *FlinkSQLGatewayStatement) getOperationResult(index int) (*OperationResu
 	if ok {
 		op.JobID = util.ParseString(jobID)
 	}
+	op.NextToken = -1
+	if uri, ok := responseMap["nextResultUri"].(string); ok && uri != "" {
+		uri, _, _ = strings.Cut(uri, "?")
+		if token, err := strconv.Atoi(path.Base(uri)); err == nil {
+			op.NextToken = token
+		}
+	}
 	return op, nil
 }
 
+// FetchRows pages through the result of a query until its end, waiting for the pages which are not ready yet.
+// It returns the names of the columns and the rows of the result, or an error once the timeout elapses
+func (f *FlinkSQLGatewayStatement) FetchRows(timeout time.Duration) ([]string, [][]interface{}, error) {
+	var (
+		retryIdle = 1 * time.Second
+		deadline  = time.Now().Add(timeout)
+		columns   []string
+		rows      [][]interface{}
+	)
+	for token := 0; token >= 0; {
+		op, err := f.getOperationResult(token)
+		if err != nil {
+			return nil, nil, err
+		}
+		if op.IsReady() {
+			if columns == nil {
+				columns = op.Columns()
+			}
+			page := op.Rows()
+			rows = append(rows, page...)
+			if op.ResultType == "EOS" || op.NextToken < 0 {
+				break
+			}
+			token = op.NextToken
+			if len(page) > 0 {
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, nil, errors.Errorf("timeout after %v while fetching the result. SessionID: %v,OperationID: %v",
+				timeout, f.session.ID, f.ID)
+		}
+		time.Sleep(retryIdle)
+	}
+	return columns, rows, nil
+}
+
 func (f *FlinkSQLGatewaySession) CloseOperation() error {
 	return nil
 }
 
+// Close closes the session and releases its resources in the gateway
+func (f *FlinkSQLGatewaySession) Close() error {
+	endpoint := fmt.Sprintf("%v/v1/sessions/%v", f.gateway.url, f.ID)
+	_, err := ExternalRequest(endpoint, http.MethodDelete, nil)
+	return err
+}
+
 func (f *FlinkSQLGatewaySession) Heartbeat() {
 
 }
 
+// Rows returns the field values of the rows in the result
+func (f *OperationResult) Rows() [][]interface{} {
+	results, ok := f.Result.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	data, ok := results["data"].([]interface{})
+	if !ok {
+		return nil
+	}
+	rows := make([][]interface{}, 0, len(data))
+	for _, d := range data {
+		row, ok := d.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fields, ok := row["fields"].([]interface{})
+		if !ok {
+			continue
+		}
+		rows = append(rows, fields)
+	}
+	return rows
+}
+
+// Columns returns the names of the columns of the result
+func (f *OperationResult) Columns() []string {
+	results, ok := f.Result.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	cols, ok := results["columns"].([]interface{})
+	if !ok {
+		return nil
+	}
+	columns := make([]string, 0, len(cols))
+	for _, c := range cols {
+		col, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		columns = append(columns, util.ParseString(col["name"]))
+	}
+	return columns
+}
+
 func (f *OperationResult) IsReady() bool {
 	return f.ResultType != "NOT_READY"
 }
@@ -11,16 +11,22 @@ import (
 )
 
 type JobHub struct {
-	behaviorEndpoint string
-	ruleEndpoint     string
-	timeout          time.Duration
+	behaviorEndpoint    string
+	ruleEndpoint        string
+	correlationEndpoint string
+	aggregationEndpoint string
+	riskEndpoint        string
+	timeout             time.Duration
 }
 
 func NewJobHub() *JobHub {
 	return &JobHub{
-		behaviorEndpoint: config.AppConfig.Endpoint.BehaviorGetJob,
-		ruleEndpoint:     config.AppConfig.Endpoint.RuleGetJob,
-		timeout:          1 * time.Minute,
+		behaviorEndpoint:    config.AppConfig.Endpoint.BehaviorGetJob,
+		ruleEndpoint:        config.AppConfig.Endpoint.RuleGetJob,
+		correlationEndpoint: config.AppConfig.Endpoint.CorrelationGetJob,
+		aggregationEndpoint: config.AppConfig.Endpoint.AggregationGetJob,
+		riskEndpoint:        config.AppConfig.Endpoint.RiskGetJob,
+		timeout:             1 * time.Minute,
 	}
 }
 
@@ -85,3 +91,90 @@ func (j *JobHub) GetRuleJobs() ([]*view.RuleJobConfig, error) {
 	}
 	return jobs, nil
 }
+
+func (j *JobHub) GetCorrelationJobs() ([]*view.CorrelationJobConfig, error) {
+	client := http.Client{
+		Timeout: time.Minute,
+	}
+	var jobs []*view.CorrelationJobConfig
+	req, err := http.NewRequest(http.MethodGet, j.correlationEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("in NewRequest at endpoint %s: %s", j.correlationEndpoint, err)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	// make requests
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run the request at endpoint %s: %s", j.correlationEndpoint, err)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the response at endpoint %s: %s", j.correlationEndpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d, response: '%s'\n", resp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, &jobs); err != nil {
+		return nil, fmt.Errorf("unexpected response data at endpoint %s: %s", j.correlationEndpoint, err)
+	}
+	return jobs, nil
+}
+
+func (j *JobHub) GetAggregationJobs() ([]*view.AggregationJobConfig, error) {
+	client := http.Client{
+		Timeout: time.Minute,
+	}
+	var jobs []*view.AggregationJobConfig
+	req, err := http.NewRequest(http.MethodGet, j.aggregationEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("in NewRequest at endpoint %s: %s", j.aggregationEndpoint, err)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	// make requests
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run the request at endpoint %s: %s", j.aggregationEndpoint, err)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the response at endpoint %s: %s", j.aggregationEndpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d, response: '%s'\n", resp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, &jobs); err != nil {
+		return nil, fmt.Errorf("unexpected response data at endpoint %s: %s", j.aggregationEndpoint, err)
+	}
+	return jobs, nil
+}
+
+func (j *JobHub) GetRiskJobs() ([]*view.RiskJobConfig, error) {
+	client := http.Client{
+		Timeout: time.Minute,
+	}
+	var jobs []*view.RiskJobConfig
+	req, err := http.NewRequest(http.MethodGet, j.riskEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("in NewRequest at endpoint %s: %s", j.riskEndpoint, err)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	// make requests
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot run the request at endpoint %s: %s", j.riskEndpoint, err)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the response at endpoint %s: %s", j.riskEndpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code: %d, response: '%s'\n", resp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, &jobs); err != nil {
+		return nil, fmt.Errorf("unexpected response data at endpoint %s: %s", j.riskEndpoint, err)
+	}
+	return jobs, nil
+}
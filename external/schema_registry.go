@@ -0,0 +1,68 @@
+package external
+
+import (
+	"encoding/json"
+	"flink_ueba_manager/util"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type SchemaRegistry struct {
+	timeout time.Duration
+}
+
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		timeout: 1 * time.Minute,
+	}
+}
+
+// GetLatestSchema returns the latest Avro schema of the subject. userInfo is an optional secret reference
+// to the user:password of the registry
+func (r *SchemaRegistry) GetLatestSchema(registryURL, subject, userInfo string) (string, error) {
+	client := http.Client{
+		Timeout: r.timeout,
+	}
+	endpoint := fmt.Sprintf("%v/subjects/%v/versions/latest", strings.TrimRight(registryURL, "/"), url.PathEscape(subject))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("in NewRequest at endpoint %s: %s", endpoint, err)
+	}
+	req.Header.Add("Accept", "application/vnd.schemaregistry.v1+json")
+	if userInfo != "" {
+		credentials, err := util.ResolveSecret(userInfo)
+		if err != nil {
+			return "", err
+		}
+		user, password, _ := strings.Cut(credentials, ":")
+		req.SetBasicAuth(user, password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot run the request at endpoint %s: %s", endpoint, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the response at endpoint %s: %s", endpoint, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code: %d, response: '%s'", resp.StatusCode, string(respBody))
+	}
+	var res struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType"`
+	}
+	if err := json.Unmarshal(respBody, &res); err != nil {
+		return "", fmt.Errorf("unexpected response data at endpoint %s: %s", endpoint, err)
+	}
+	if res.SchemaType != "" && res.SchemaType != "AVRO" {
+		return "", fmt.Errorf("subject %v has a %v schema, only AVRO is supported", subject, res.SchemaType)
+	}
+	return res.Schema, nil
+}
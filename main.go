@@ -1,22 +1,45 @@
 package main
 
 import (
+	"flag"
+	"flink_ueba_manager/attack"
 	"flink_ueba_manager/config"
 	"flink_ueba_manager/controller"
 	"flink_ueba_manager/external"
 	"flink_ueba_manager/manager"
+	"flink_ueba_manager/util"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
+	"strings"
+)
+
+var (
+	planOnly    = flag.Bool("plan", false, "print the SQL plans of the JobHub jobs without submitting them, then exit")
+	explainPlan = flag.Bool("explain", false, "with -plan, validate each plan by an EXPLAIN through the SQL gateway")
 )
 
 func main() {
+	flag.Parse()
 	log.Println("+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-")
 	appConfig, err := config.LoadFile(config.DefaultConfigFilePath)
 	if err != nil {
 		log.Fatalf("failed to parse configuration file %s: %v", config.DefaultConfigFilePath, err)
 	}
 	config.AppConfig = appConfig
+	util.SetSecretsDir(config.AppConfig.SecretsDir)
+	if config.AppConfig.AttackCatalog != "" {
+		catalog, err := attack.LoadFile(config.AppConfig.AttackCatalog)
+		if err != nil {
+			log.Fatalf("failed to load the ATT&CK catalog %s: %v", config.AppConfig.AttackCatalog, err)
+		}
+		attack.SetCatalog(catalog)
+	}
+
+	if *planOnly {
+		printPlans()
+		return
+	}
 
 	ss, err := external.NewFlinkSQLGatewaySession()
 	if err != nil {
@@ -31,9 +54,39 @@ func main() {
 	apiGroup := route.Group("/api/v1")
 	jobHandler := controller.NewJobHandler(jobManager)
 	jobHandler.MakeHandler(apiGroup)
+	ruleHandler := controller.NewRuleHandler(jobManager)
+	ruleHandler.MakeHandler(apiGroup)
+
+	watchlistManager, err := manager.NewWatchlistManager(config.AppConfig.Watchlist)
+	if err != nil {
+		log.Fatalf("error in loading watchlists: %v", err)
+	}
+	watchlistHandler := controller.NewWatchlistHandler(watchlistManager)
+	watchlistHandler.MakeHandler(apiGroup)
 
 	err = route.Run(fmt.Sprintf("%s:%d", config.AppConfig.Service.Host, config.AppConfig.Service.Port))
 	if err != nil {
 		log.Fatalf("failed to start the server: %v", err)
 	}
 }
+
+func printPlans() {
+	plans, err := manager.NewJobManager().PlanHubJobs(*explainPlan)
+	if err != nil {
+		log.Fatalf("failed to render plans: %v", err)
+	}
+	for _, plan := range plans {
+		fmt.Printf("-- %v job %v\n", plan.Kind, plan.ID)
+		if plan.Error != "" {
+			fmt.Printf("-- error: %v\n\n", plan.Error)
+			continue
+		}
+		for _, stm := range plan.Statements {
+			fmt.Printf("%v;\n", strings.TrimSuffix(stm, ";"))
+		}
+		if plan.Explain != "" {
+			fmt.Printf("-- explain:\n%v\n", plan.Explain)
+		}
+		fmt.Println()
+	}
+}
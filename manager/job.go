@@ -1,31 +1,70 @@
 package manager
 
 import (
+	"flink_ueba_manager/attack"
+	"flink_ueba_manager/config"
 	"flink_ueba_manager/external"
 	"flink_ueba_manager/view"
 	"flink_ueba_manager/worker"
 	"fmt"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"sync"
 	"time"
 )
 
+// ErrRuleNotFound is returned for a rule ID the JobHub does not serve
+var ErrRuleNotFound = errors.New("rule not found in JobHub")
+
 type (
 	JobManager struct {
 		RunningJobs map[string]*JobMetadata
 		FailedJobs  map[string]*JobMetadata
-		logger      *logrus.Entry
+		// RejectedJobs holds jobs whose config failed validation, no statement was submitted for them
+		RejectedJobs map[string]*JobMetadata
+		// ruleGroups are the deployed rule groups by group ID when rules are grouped, see syncRuleGroups
+		ruleGroups map[string]*ruleGroupMetadata
+		// ruleGroupStatuses and ruleGroupOf snapshot the deployed groups and the group of each rule for readers
+		ruleGroupStatuses []*view.RuleGroupStatus
+		ruleGroupOf       map[string]*view.RuleGroupStatus
+		groupsMu          sync.RWMutex
+		// flinkJobs are the jobs of the cluster, ruleJobsMigrated is set once the rule jobs of the other rule
+		// grouping mode are stopped, see migrateRuleJobs
+		flinkJobs        flinkJobs
+		ruleJobsMigrated bool
+		logger           *logrus.Entry
 	}
 	JobMetadata struct {
 		worker worker.IFlinkSQLWorker
 		err    error
 	}
+	// flinkJobs lists and stops the jobs of the cluster, the jobs started by earlier manager runs included
+	flinkJobs interface {
+		List() ([]*worker.FlinkJob, error)
+		Stop(jobID string) error
+	}
+	// gatewayFlinkJobs are the jobs of the cluster behind the SQL gateway
+	gatewayFlinkJobs struct{}
 )
 
+func (gatewayFlinkJobs) List() ([]*worker.FlinkJob, error) {
+	return worker.ListFlinkJobs()
+}
+
+func (gatewayFlinkJobs) Stop(jobID string) error {
+	return worker.StopFlinkJob(jobID)
+}
+
 func NewJobManager() *JobManager {
 	return &JobManager{
-		RunningJobs: make(map[string]*JobMetadata),
-		FailedJobs:  make(map[string]*JobMetadata),
-		logger:      logrus.WithField("manager", "job"),
+		RunningJobs:       make(map[string]*JobMetadata),
+		FailedJobs:        make(map[string]*JobMetadata),
+		RejectedJobs:      make(map[string]*JobMetadata),
+		ruleGroups:        make(map[string]*ruleGroupMetadata),
+		ruleGroupStatuses: []*view.RuleGroupStatus{},
+		ruleGroupOf:       make(map[string]*view.RuleGroupStatus),
+		flinkJobs:         gatewayFlinkJobs{},
+		logger:            logrus.WithField("manager", "job"),
 	}
 }
 
@@ -49,11 +88,12 @@ func (m *JobManager) pullJobs() {
 		m.logger.Errorf("error in pulling jobs from JobHub: %v", err)
 	}
 	for _, job := range bhvJobs {
-		id := fmt.Sprintf("behavior_%v", job.ID)
+		id := behaviorJobKey(job.ID)
 		if _, ok := m.RunningJobs[id]; ok {
 			continue
 		}
 		delete(m.FailedJobs, id)
+		delete(m.RejectedJobs, id)
 		err := m.CreateBehaviorJob(job)
 		if err != nil {
 			m.logger.Errorf("error in create behavior jobs: %v", err)
@@ -61,18 +101,77 @@ func (m *JobManager) pullJobs() {
 	}
 
 	ruleJobs, err := jobHub.GetRuleJobs()
+	if err != nil {
+		m.logger.Errorf("error in pulling jobs from JobHub: %v", err)
+	} else if err := m.migrateRuleJobs(ruleJobs, config.AppConfig.RuleGrouping); err != nil {
+		// the rules are deployed by the next pull, rather than next to the jobs of the other mode
+		m.logger.Errorf("error in stopping the rule jobs of the other rule grouping mode: %v", err)
+	} else if config.AppConfig.RuleGrouping {
+		// the groups are only synced with a successful pull, so a JobHub outage does not stop them
+		m.syncRuleGroups(ruleJobs)
+	} else {
+		for _, job := range ruleJobs {
+			id := ruleJobKey(job.ID)
+			if _, ok := m.RunningJobs[id]; ok {
+				continue
+			}
+			delete(m.FailedJobs, id)
+			delete(m.RejectedJobs, id)
+			err := m.CreateRuleJob(job)
+			if err != nil {
+				m.logger.Errorf("error in create rule jobs: %v", err)
+			}
+		}
+	}
+
+	correlationJobs, err := jobHub.GetCorrelationJobs()
 	if err != nil {
 		m.logger.Errorf("error in pulling jobs from JobHub: %v", err)
 	}
-	for _, job := range ruleJobs {
-		id := fmt.Sprintf("rule_%v", job.ID)
+	for _, job := range correlationJobs {
+		id := correlationJobKey(job.ID)
+		if _, ok := m.RunningJobs[id]; ok {
+			continue
+		}
+		delete(m.FailedJobs, id)
+		delete(m.RejectedJobs, id)
+		err := m.CreateCorrelationJob(job)
+		if err != nil {
+			m.logger.Errorf("error in create correlation jobs: %v", err)
+		}
+	}
+
+	aggregationJobs, err := jobHub.GetAggregationJobs()
+	if err != nil {
+		m.logger.Errorf("error in pulling jobs from JobHub: %v", err)
+	}
+	for _, job := range aggregationJobs {
+		id := aggregationJobKey(job.ID)
+		if _, ok := m.RunningJobs[id]; ok {
+			continue
+		}
+		delete(m.FailedJobs, id)
+		delete(m.RejectedJobs, id)
+		err := m.CreateAggregationJob(job)
+		if err != nil {
+			m.logger.Errorf("error in create aggregation jobs: %v", err)
+		}
+	}
+
+	riskJobs, err := jobHub.GetRiskJobs()
+	if err != nil {
+		m.logger.Errorf("error in pulling jobs from JobHub: %v", err)
+	}
+	for _, job := range riskJobs {
+		id := riskJobKey(job.ID)
 		if _, ok := m.RunningJobs[id]; ok {
 			continue
 		}
 		delete(m.FailedJobs, id)
-		err := m.CreateRuleJob(job)
+		delete(m.RejectedJobs, id)
+		err := m.CreateRiskJob(job)
 		if err != nil {
-			m.logger.Errorf("error in create rule jobs: %v", err)
+			m.logger.Errorf("error in create risk jobs: %v", err)
 		}
 	}
 }
@@ -81,16 +180,20 @@ func (m *JobManager) CreateBehaviorJob(jobConfig *view.BehaviorJobConfig) error
 	//if _, ok := m.RunningJobs[jobConfig.ID]; ok {
 	//	return fmt.Errorf("job with id %v already running", jobConfig.ID)
 	//}
+	if err := prepareJob(jobConfig); err != nil {
+		m.RejectedJobs[behaviorJobKey(jobConfig.ID)] = &JobMetadata{err: err}
+		return errors.Wrapf(err, "behavior job %v rejected", jobConfig.ID)
+	}
 	bhvWorker := worker.NewBehaviorJobWorker(jobConfig.ID, jobConfig)
 	err := bhvWorker.Run()
 	if err != nil {
-		m.FailedJobs[jobConfig.ID] = &JobMetadata{
+		m.FailedJobs[behaviorJobKey(jobConfig.ID)] = &JobMetadata{
 			worker: bhvWorker,
 			err:    err,
 		}
 		return err
 	}
-	m.RunningJobs[jobConfig.ID] = &JobMetadata{
+	m.RunningJobs[behaviorJobKey(jobConfig.ID)] = &JobMetadata{
 		worker: bhvWorker,
 	}
 	return nil
@@ -100,17 +203,245 @@ func (m *JobManager) CreateRuleJob(jobConfig *view.RuleJobConfig) error {
 	//if _, ok := m.RunningJobs[jobConfig.ID]; ok {
 	//	return fmt.Errorf("job with id %v already running", jobConfig.ID)
 	//}
+	if err := prepareJob(jobConfig); err != nil {
+		m.RejectedJobs[ruleJobKey(jobConfig.ID)] = &JobMetadata{err: err}
+		return errors.Wrapf(err, "rule job %v rejected", jobConfig.ID)
+	}
 	ruleWorker := worker.NewRuleJobWorker(jobConfig.ID, jobConfig)
 	err := ruleWorker.Run()
 	if err != nil {
-		m.FailedJobs[jobConfig.ID] = &JobMetadata{
+		m.FailedJobs[ruleJobKey(jobConfig.ID)] = &JobMetadata{
 			worker: ruleWorker,
 			err:    err,
 		}
 		return err
 	}
-	m.RunningJobs[jobConfig.ID] = &JobMetadata{
+	m.RunningJobs[ruleJobKey(jobConfig.ID)] = &JobMetadata{
 		worker: ruleWorker,
 	}
 	return nil
 }
+
+func (m *JobManager) CreateCorrelationJob(jobConfig *view.CorrelationJobConfig) error {
+	if err := prepareJob(jobConfig); err != nil {
+		m.RejectedJobs[correlationJobKey(jobConfig.ID)] = &JobMetadata{err: err}
+		return errors.Wrapf(err, "correlation job %v rejected", jobConfig.ID)
+	}
+	correlationWorker := worker.NewCorrelationJobWorker(jobConfig.ID, jobConfig)
+	err := correlationWorker.Run()
+	if err != nil {
+		m.FailedJobs[correlationJobKey(jobConfig.ID)] = &JobMetadata{
+			worker: correlationWorker,
+			err:    err,
+		}
+		return err
+	}
+	m.RunningJobs[correlationJobKey(jobConfig.ID)] = &JobMetadata{
+		worker: correlationWorker,
+	}
+	return nil
+}
+
+func (m *JobManager) CreateAggregationJob(jobConfig *view.AggregationJobConfig) error {
+	if err := prepareJob(jobConfig); err != nil {
+		m.RejectedJobs[aggregationJobKey(jobConfig.ID)] = &JobMetadata{err: err}
+		return errors.Wrapf(err, "aggregation job %v rejected", jobConfig.ID)
+	}
+	aggregationWorker := worker.NewAggregationJobWorker(jobConfig.ID, jobConfig)
+	err := aggregationWorker.Run()
+	if err != nil {
+		m.FailedJobs[aggregationJobKey(jobConfig.ID)] = &JobMetadata{
+			worker: aggregationWorker,
+			err:    err,
+		}
+		return err
+	}
+	m.RunningJobs[aggregationJobKey(jobConfig.ID)] = &JobMetadata{
+		worker: aggregationWorker,
+	}
+	return nil
+}
+
+func (m *JobManager) CreateRiskJob(jobConfig *view.RiskJobConfig) error {
+	if err := prepareJob(jobConfig); err != nil {
+		m.RejectedJobs[riskJobKey(jobConfig.ID)] = &JobMetadata{err: err}
+		return errors.Wrapf(err, "risk job %v rejected", jobConfig.ID)
+	}
+	riskWorker := worker.NewRiskJobWorker(jobConfig.ID, jobConfig)
+	err := riskWorker.Run()
+	if err != nil {
+		m.FailedJobs[riskJobKey(jobConfig.ID)] = &JobMetadata{
+			worker: riskWorker,
+			err:    err,
+		}
+		return err
+	}
+	m.RunningJobs[riskJobKey(jobConfig.ID)] = &JobMetadata{
+		worker: riskWorker,
+	}
+	return nil
+}
+
+// prepareJob derives the schemas the job takes from the schema registry, then validates the job
+func prepareJob(job view.JobConfig) error {
+	if err := job.DeriveSchemas(external.NewSchemaRegistry().GetLatestSchema); err != nil {
+		return err
+	}
+	return job.Validate()
+}
+
+// behaviorJobKey, ruleJobKey, correlationJobKey, aggregationJobKey and riskJobKey key the job maps, so jobs of different kinds sharing an ID do not collide
+// and a job pulled again while running is not redeployed
+func behaviorJobKey(id string) string {
+	return fmt.Sprintf("behavior_%v", id)
+}
+
+func ruleJobKey(id string) string {
+	return fmt.Sprintf("rule_%v", id)
+}
+
+func correlationJobKey(id string) string {
+	return fmt.Sprintf("correlation_%v", id)
+}
+
+func aggregationJobKey(id string) string {
+	return fmt.Sprintf("aggregation_%v", id)
+}
+
+func riskJobKey(id string) string {
+	return fmt.Sprintf("risk_%v", id)
+}
+
+// PlanJobs renders the plans of the jobs without submitting them. When explain is set, each plan is
+// also validated by an EXPLAIN round-trip through the SQL gateway in a dedicated session
+func (m *JobManager) PlanJobs(bhvJobs []*view.BehaviorJobConfig, ruleJobs []*view.RuleJobConfig, correlationJobs []*view.CorrelationJobConfig,
+	aggregationJobs []*view.AggregationJobConfig, riskJobs []*view.RiskJobConfig, explain bool) []*view.JobPlan {
+	plans := make([]*view.JobPlan, 0, len(bhvJobs)+len(ruleJobs)+len(correlationJobs)+len(aggregationJobs)+len(riskJobs))
+	for _, job := range bhvJobs {
+		plans = append(plans, planJob(job.ID, "behavior", func() error { return prepareJob(job) }, worker.NewBehaviorJobWorker(job.ID, job), explain))
+	}
+	if config.AppConfig.RuleGrouping {
+		plans = append(plans, planRuleGroups(ruleJobs, explain)...)
+	} else {
+		for _, job := range ruleJobs {
+			plans = append(plans, planJob(job.ID, "rule", func() error { return prepareJob(job) }, worker.NewRuleJobWorker(job.ID, job), explain))
+		}
+	}
+	for _, job := range correlationJobs {
+		plans = append(plans, planJob(job.ID, "correlation", func() error { return prepareJob(job) }, worker.NewCorrelationJobWorker(job.ID, job), explain))
+	}
+	for _, job := range aggregationJobs {
+		plans = append(plans, planJob(job.ID, "aggregation", func() error { return prepareJob(job) }, worker.NewAggregationJobWorker(job.ID, job), explain))
+	}
+	for _, job := range riskJobs {
+		plans = append(plans, planJob(job.ID, "risk", func() error { return prepareJob(job) }, worker.NewRiskJobWorker(job.ID, job), explain))
+	}
+	return plans
+}
+
+// PlanHubJobs renders the plans of the jobs currently served by the JobHub
+func (m *JobManager) PlanHubJobs(explain bool) ([]*view.JobPlan, error) {
+	jobHub := external.NewJobHub()
+	bhvJobs, err := jobHub.GetBehaviorJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling behavior jobs from JobHub")
+	}
+	ruleJobs, err := jobHub.GetRuleJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling rule jobs from JobHub")
+	}
+	correlationJobs, err := jobHub.GetCorrelationJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling correlation jobs from JobHub")
+	}
+	aggregationJobs, err := jobHub.GetAggregationJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling aggregation jobs from JobHub")
+	}
+	riskJobs, err := jobHub.GetRiskJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling risk jobs from JobHub")
+	}
+	return m.PlanJobs(bhvJobs, ruleJobs, correlationJobs, aggregationJobs, riskJobs, explain), nil
+}
+
+// RuleCoverage lists the rules of the JobHub by the ATT&CK tactic and technique they detect
+func (m *JobManager) RuleCoverage() (*attack.Coverage, error) {
+	jobHub := external.NewJobHub()
+	ruleJobs, err := jobHub.GetRuleJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling rule jobs from JobHub")
+	}
+	correlationJobs, err := jobHub.GetCorrelationJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling correlation jobs from JobHub")
+	}
+	aggregationJobs, err := jobHub.GetAggregationJobs()
+	if err != nil {
+		return nil, errors.Wrap(err, "pulling aggregation jobs from JobHub")
+	}
+	var detections []*attack.Detection
+	for _, job := range ruleJobs {
+		detections = append(detections, &attack.Detection{Kind: "rule", ID: job.ID, Name: job.Name, Technique: job.Technique, Tactic: job.Tactic})
+	}
+	for _, job := range correlationJobs {
+		detections = append(detections, &attack.Detection{Kind: "correlation", ID: job.ID, Name: job.Name, Technique: job.Technique, Tactic: job.Tactic})
+	}
+	for _, job := range aggregationJobs {
+		detections = append(detections, &attack.Detection{Kind: "aggregation", ID: job.ID, Name: job.Name, Technique: job.Technique, Tactic: job.Tactic})
+	}
+	return attack.Current().Coverage(detections), nil
+}
+
+// BacktestRule replays a rule over a bounded range of its predictor topic and reports the alerts it would have
+// emitted. A rule given by ID is pulled from the JobHub
+func (m *JobManager) BacktestRule(req *view.RuleBacktestRequest) (*view.RuleBacktestResult, error) {
+	if req.Rule == nil && req.RuleID != "" {
+		ruleJobs, err := external.NewJobHub().GetRuleJobs()
+		if err != nil {
+			return nil, errors.Wrap(err, "pulling rule jobs from JobHub")
+		}
+		for _, job := range ruleJobs {
+			if job.ID == req.RuleID {
+				req.Rule = job
+			}
+		}
+		if req.Rule == nil {
+			return nil, ErrRuleNotFound
+		}
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := prepareJob(req.Rule); err != nil {
+		return nil, err
+	}
+	backtestWorker, err := worker.NewRuleBacktestWorker(req)
+	if err != nil {
+		return nil, err
+	}
+	return backtestWorker.Backtest()
+}
+
+func planJob(id, kind string, prepare func() error, w worker.IFlinkSQLWorker, explain bool) *view.JobPlan {
+	plan := &view.JobPlan{ID: id, Kind: kind}
+	if err := prepare(); err != nil {
+		plan.Error = err.Error()
+		return plan
+	}
+	statements, err := w.Plan()
+	if err != nil {
+		plan.Error = err.Error()
+		return plan
+	}
+	plan.Statements = statements
+	if explain {
+		res, err := worker.ExplainPlan(w)
+		if err != nil {
+			plan.Error = err.Error()
+			return plan
+		}
+		plan.Explain = res
+	}
+	return plan
+}
@@ -0,0 +1,212 @@
+package manager
+
+import (
+	"flink_ueba_manager/view"
+	"flink_ueba_manager/worker"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+const (
+	// restoredStateWarning warns that a group restored from a savepoint lost part of its state: Flink derives the
+	// IDs of the operators of a SQL job from its job graph, which changes with the rules of the group
+	restoredStateWarning = "restored from a savepoint, the suppression and watermark state of the operators " +
+		"whose place in the job graph changed with the rules of the group was reset"
+	// resetStateWarning warns that a group was deployed again without the state of its previous job
+	resetStateWarning = "redeployed without a savepoint, the suppression and watermark state of the rules was reset"
+)
+
+type (
+	// ruleGroupMetadata is a deployed rule group with the fingerprint of the rules it was deployed from
+	ruleGroupMetadata struct {
+		group       *view.RuleGroup
+		fingerprint string
+		worker      *worker.RuleGroupJobWorker
+		// savepoint is the savepoint the job was restored from, stateWarning tells what state the job lost
+		savepoint    string
+		stateWarning string
+		err          error
+	}
+)
+
+// syncRuleGroups deploys the rules grouped by predictor source. A group is redeployed when a rule joins or leaves it
+// or one of its rules changes, restoring a savepoint of its previous job, and stopped when no rule is left. Rejected
+// rules are left out of their group, so an invalid rule does not hold back the other rules of its source
+func (m *JobManager) syncRuleGroups(jobs []*view.RuleJobConfig) {
+	var accepted []*view.RuleJobConfig
+	for _, job := range jobs {
+		delete(m.RejectedJobs, ruleJobKey(job.ID))
+		if err := prepareJob(job); err != nil {
+			m.RejectedJobs[ruleJobKey(job.ID)] = &JobMetadata{err: err}
+			m.logger.Errorf("error in create rule jobs: %v", errors.Wrapf(err, "rule job %v rejected", job.ID))
+			continue
+		}
+		accepted = append(accepted, job)
+	}
+	deployed := make(map[string]*ruleGroupMetadata)
+	for _, group := range view.GroupRules(accepted) {
+		fingerprint := group.Fingerprint()
+		current, redeployed := m.ruleGroups[group.ID]
+		savepoint := ""
+		if redeployed {
+			if current.err == nil && current.fingerprint == fingerprint {
+				deployed[group.ID] = current
+				continue
+			}
+			savepoint = m.stopRuleGroupWithSavepoint(current)
+		}
+		deployed[group.ID] = m.createRuleGroupJob(group, fingerprint, redeployed, savepoint)
+	}
+	for id, current := range m.ruleGroups {
+		if _, ok := deployed[id]; !ok {
+			m.stopRuleGroup(current)
+		}
+	}
+	m.ruleGroups = deployed
+	m.publishRuleGroups()
+}
+
+// migrateRuleJobs stops the rule jobs which a deployment in the other rule grouping mode left running, before the
+// rules are first deployed: the per-rule jobs of the rules when rules are grouped, the rule group jobs otherwise.
+// They would keep writing the alerts of the rules next to the new jobs. The new jobs read the predictor sources
+// under consumer groups of their own, so they start from the startup mode of the sources
+func (m *JobManager) migrateRuleJobs(jobs []*view.RuleJobConfig, grouping bool) error {
+	if m.ruleJobsMigrated {
+		return nil
+	}
+	ruleJobNames := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		// the pipeline.name of a per-rule job is its job key
+		ruleJobNames[ruleJobKey(job.ID)] = true
+	}
+	flinkJobs, err := m.flinkJobs.List()
+	if err != nil {
+		return errors.Wrap(err, "listing the flink jobs")
+	}
+	for _, job := range flinkJobs {
+		stale := ruleJobNames[job.Name]
+		if !grouping {
+			stale = strings.HasPrefix(job.Name, worker.RuleGroupJobNamePrefix) && !ruleJobNames[job.Name]
+		}
+		if !stale || !job.IsActive() {
+			continue
+		}
+		if err := m.flinkJobs.Stop(job.ID); err != nil {
+			return errors.Wrapf(err, "stopping flink job %v of %v", job.ID, job.Name)
+		}
+		m.logger.Infof("stopped flink job %v of %v, left by the other rule grouping mode", job.ID, job.Name)
+	}
+	m.ruleJobsMigrated = true
+	return nil
+}
+
+// createRuleGroupJob starts the job of a group, restoring the savepoint of its previous job if any. A job which
+// fails to restore the savepoint is started without it, a failed group is started again by the next sync
+func (m *JobManager) createRuleGroupJob(group *view.RuleGroup, fingerprint string, redeployed bool, savepoint string) *ruleGroupMetadata {
+	metadata := &ruleGroupMetadata{
+		group:       group,
+		fingerprint: fingerprint,
+		worker:      worker.NewRuleGroupJobWorker(group),
+	}
+	if savepoint != "" {
+		metadata.worker.RestoreFrom(savepoint)
+		err := metadata.worker.Run()
+		if err == nil {
+			metadata.savepoint = savepoint
+			metadata.stateWarning = restoredStateWarning
+			return metadata
+		}
+		m.logger.Warnf("error in restoring rule group %v from savepoint %v, starting it without: %v", group.ID, savepoint, err)
+		if err := metadata.worker.Stop(); err != nil {
+			m.logger.Errorf("error in stopping rule group %v: %v", group.ID, err)
+		}
+		metadata.worker = worker.NewRuleGroupJobWorker(group)
+	}
+	if redeployed {
+		metadata.stateWarning = resetStateWarning
+	}
+	if err := metadata.worker.Run(); err != nil {
+		metadata.err = err
+		m.logger.Errorf("error in create rule group %v of rules %v: %v", group.ID, group.RuleIDs(), err)
+	}
+	return metadata
+}
+
+func (m *JobManager) stopRuleGroup(metadata *ruleGroupMetadata) {
+	if err := metadata.worker.Stop(); err != nil {
+		m.logger.Errorf("error in stopping rule group %v: %v", metadata.group.ID, err)
+	}
+}
+
+// stopRuleGroupWithSavepoint stops a group which is deployed again and returns the savepoint of its job, empty
+// when it was stopped without one
+func (m *JobManager) stopRuleGroupWithSavepoint(metadata *ruleGroupMetadata) string {
+	savepoint, err := metadata.worker.StopWithSavepoint()
+	if err != nil {
+		m.logger.Errorf("error in stopping rule group %v: %v", metadata.group.ID, err)
+	}
+	return savepoint
+}
+
+// publishRuleGroups snapshots the status of the deployed groups for RuleGroups and RuleGroupOf
+func (m *JobManager) publishRuleGroups() {
+	statuses := make([]*view.RuleGroupStatus, 0, len(m.ruleGroups))
+	membership := make(map[string]*view.RuleGroupStatus)
+	for _, metadata := range m.ruleGroups {
+		status := &view.RuleGroupStatus{
+			ID:           metadata.group.ID,
+			Topic:        metadata.group.Topic,
+			Rules:        metadata.group.RuleIDs(),
+			FlinkJobID:   metadata.worker.FlinkJobID(),
+			Savepoint:    metadata.savepoint,
+			StateWarning: metadata.stateWarning,
+		}
+		if metadata.err != nil {
+			status.Error = metadata.err.Error()
+		}
+		statuses = append(statuses, status)
+		for _, id := range status.Rules {
+			membership[id] = status
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	m.groupsMu.Lock()
+	defer m.groupsMu.Unlock()
+	m.ruleGroupStatuses = statuses
+	m.ruleGroupOf = membership
+}
+
+// RuleGroups returns the deployed rule groups sorted by ID
+func (m *JobManager) RuleGroups() []*view.RuleGroupStatus {
+	m.groupsMu.RLock()
+	defer m.groupsMu.RUnlock()
+	return m.ruleGroupStatuses
+}
+
+// RuleGroupOf returns the deployed group of a rule
+func (m *JobManager) RuleGroupOf(ruleID string) (*view.RuleGroupStatus, bool) {
+	m.groupsMu.RLock()
+	defer m.groupsMu.RUnlock()
+	status, ok := m.ruleGroupOf[ruleID]
+	return status, ok
+}
+
+// planRuleGroups renders the plans of the groups of the rules, rejected rules get a plan with their error
+func planRuleGroups(jobs []*view.RuleJobConfig, explain bool) []*view.JobPlan {
+	var plans []*view.JobPlan
+	var accepted []*view.RuleJobConfig
+	for _, job := range jobs {
+		if err := prepareJob(job); err != nil {
+			plans = append(plans, &view.JobPlan{ID: job.ID, Kind: "rule", Error: err.Error()})
+			continue
+		}
+		accepted = append(accepted, job)
+	}
+	for _, group := range view.GroupRules(accepted) {
+		plan := planJob(group.ID, "rule_group", func() error { return nil }, worker.NewRuleGroupJobWorker(group), explain)
+		plan.Rules = group.RuleIDs()
+		plans = append(plans, plan)
+	}
+	return plans
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"errors"
+	"flink_ueba_manager/view"
+	"flink_ueba_manager/worker"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeFlinkJobs are the jobs of a cluster in memory, err fails the listing
+type fakeFlinkJobs struct {
+	jobs    []*worker.FlinkJob
+	stopped []string
+	err     error
+}
+
+func (f *fakeFlinkJobs) List() ([]*worker.FlinkJob, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.jobs, nil
+}
+
+func (f *fakeFlinkJobs) Stop(jobID string) error {
+	f.stopped = append(f.stopped, jobID)
+	return nil
+}
+
+func newClusterJobs() *fakeFlinkJobs {
+	return &fakeFlinkJobs{jobs: []*worker.FlinkJob{
+		{ID: "1", Name: "rule_a", Status: "RUNNING"},
+		{ID: "2", Name: "rule_b", Status: "FINISHED"},
+		{ID: "3", Name: "rule_c", Status: "RESTARTING"},
+		{ID: "4", Name: "rule_group_0123456789ab", Status: "RUNNING"},
+		{ID: "5", Name: "rule_backtest_a", Status: "RUNNING"},
+		{ID: "6", Name: "behavior_a", Status: "RUNNING"},
+		{ID: "7", Name: "rule_other", Status: "RUNNING"},
+	}}
+}
+
+func TestMigrateRuleJobs(t *testing.T) {
+	rules := []*view.RuleJobConfig{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	tests := []struct {
+		name     string
+		grouping bool
+		want     []string
+	}{
+		{name: "to rule groups", grouping: true, want: []string{"1", "3"}},
+		{name: "to per-rule jobs", grouping: false, want: []string{"4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewJobManager()
+			cluster := newClusterJobs()
+			m.flinkJobs = cluster
+			if err := m.migrateRuleJobs(rules, tt.grouping); err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(cluster.stopped)
+			if !reflect.DeepEqual(cluster.stopped, tt.want) {
+				t.Errorf("stopped %v, want %v", cluster.stopped, tt.want)
+			}
+
+			// the jobs are only migrated once, the jobs of the manager run itself are left alone
+			cluster.stopped = nil
+			if err := m.migrateRuleJobs(rules, tt.grouping); err != nil {
+				t.Fatal(err)
+			}
+			if len(cluster.stopped) != 0 {
+				t.Errorf("a second migration stopped %v", cluster.stopped)
+			}
+		})
+	}
+}
+
+func TestMigrateRuleJobsRetried(t *testing.T) {
+	m := NewJobManager()
+	cluster := newClusterJobs()
+	cluster.err = errors.New("gateway is down")
+	m.flinkJobs = cluster
+	rules := []*view.RuleJobConfig{{ID: "a"}}
+	if err := m.migrateRuleJobs(rules, true); err == nil {
+		t.Fatal("expected the migration to fail with the gateway")
+	}
+	cluster.err = nil
+	if err := m.migrateRuleJobs(rules, true); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cluster.stopped, []string{"1"}) {
+		t.Errorf("stopped %v after the retry, want [1]", cluster.stopped)
+	}
+}
@@ -0,0 +1,312 @@
+package manager
+
+import (
+	"encoding/json"
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWatchlistNotFound        = errors.New("watchlist not found")
+	ErrWatchlistExists          = errors.New("watchlist already exists")
+	ErrWatchlistVersionConflict = errors.New("watchlist has changed since the given version")
+)
+
+// WatchlistManager stores the watchlists in a json file and materializes them to the watchlist table, the database
+// table rule jobs look the values of WATCHLIST filters up in. A change rewrites the rows of its watchlist in one
+// transaction before it is stored, the rule jobs see it once their lookup cache expires
+type WatchlistManager struct {
+	mu          sync.RWMutex
+	watchlists  map[string]*view.Watchlist
+	storagePath string
+	// table is the watchlist table, nil when it is not configured
+	table watchlistTable
+	// synced reports whether the table holds the stored watchlists. Until it does, the next change syncs it first
+	synced bool
+	logger *logrus.Entry
+}
+
+// NewWatchlistManager loads the stored watchlists and materializes them all, so the watchlist table matches the
+// storage after a restart. An unavailable table does not stop the manager, it is synced again on the next change
+func NewWatchlistManager(cfg *config.Watchlist) (*WatchlistManager, error) {
+	var table watchlistTable
+	if cfg.HasTable() {
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		table = newJDBCWatchlistTable(cfg)
+	}
+	return newWatchlistManager(cfg.StoragePath, table)
+}
+
+func newWatchlistManager(storagePath string, table watchlistTable) (*WatchlistManager, error) {
+	m := &WatchlistManager{
+		watchlists:  make(map[string]*view.Watchlist),
+		storagePath: storagePath,
+		table:       table,
+		logger:      logrus.WithField("manager", "watchlist"),
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	if table == nil {
+		m.logger.Warnf("the watchlist table is not configured, rules cannot use WATCHLIST filters")
+		return m, nil
+	}
+	if err := m.sync(); err != nil {
+		m.logger.Warnf("the watchlist table is unavailable, it is synced on the next change: %v", err)
+	}
+	return m, nil
+}
+
+// List returns the watchlists sorted by name
+func (m *WatchlistManager) List() []*view.Watchlist {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	watchlists := make([]*view.Watchlist, 0, len(m.watchlists))
+	for _, w := range m.watchlists {
+		watchlists = append(watchlists, w)
+	}
+	sort.Slice(watchlists, func(i, j int) bool { return watchlists[i].Name < watchlists[j].Name })
+	return watchlists
+}
+
+func (m *WatchlistManager) Get(name string) (*view.Watchlist, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	w, ok := m.watchlists[name]
+	if !ok {
+		return nil, ErrWatchlistNotFound
+	}
+	return w, nil
+}
+
+func (m *WatchlistManager) Create(req *view.WatchlistRequest) (*view.Watchlist, error) {
+	if err := view.ValidateWatchlistName(req.Name); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.watchlists[req.Name]; ok {
+		return nil, ErrWatchlistExists
+	}
+	w := &view.Watchlist{
+		Name:        req.Name,
+		Description: req.Description,
+		Kind:        req.Kind,
+		Values:      view.NormalizeWatchlistValues(req.Values),
+		Version:     1,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	return w, m.save(w)
+}
+
+// Update replaces the description, kind and values of a watchlist
+func (m *WatchlistManager) Update(name string, req *view.WatchlistRequest) (*view.Watchlist, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.current(name, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	w := &view.Watchlist{
+		Name:        name,
+		Description: req.Description,
+		Kind:        req.Kind,
+		Values:      view.NormalizeWatchlistValues(req.Values),
+		Version:     current.Version + 1,
+		UpdatedAt:   time.Now().UTC(),
+	}
+	return w, m.save(w)
+}
+
+// Patch adds values to a watchlist and removes values from it, a value both added and removed is removed
+func (m *WatchlistManager) Patch(name string, patch *view.WatchlistPatch) (*view.Watchlist, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := m.current(name, patch.Version)
+	if err != nil {
+		return nil, err
+	}
+	removed := make(map[string]struct{}, len(patch.Remove))
+	for _, value := range patch.Remove {
+		removed[value] = struct{}{}
+	}
+	var values []string
+	for _, value := range append(append([]string{}, current.Values...), patch.Add...) {
+		if _, ok := removed[value]; !ok {
+			values = append(values, value)
+		}
+	}
+	w := *current
+	w.Values = view.NormalizeWatchlistValues(values)
+	w.Version = current.Version + 1
+	w.UpdatedAt = time.Now().UTC()
+	return &w, m.save(&w)
+}
+
+func (m *WatchlistManager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.watchlists[name]
+	if !ok {
+		return ErrWatchlistNotFound
+	}
+	// the watchlist without values has no rows
+	if err := m.materialize(&view.Watchlist{Name: name}); err != nil {
+		return err
+	}
+	delete(m.watchlists, name)
+	if err := m.persist(); err != nil {
+		m.watchlists[name] = w
+		m.restore(w)
+		return err
+	}
+	m.logger.Infof("deleted watchlist %v", name)
+	return nil
+}
+
+// current returns the watchlist a change is based on, version zero skips the version check
+func (m *WatchlistManager) current(name string, version int64) (*view.Watchlist, error) {
+	w, ok := m.watchlists[name]
+	if !ok {
+		return nil, ErrWatchlistNotFound
+	}
+	if version != 0 && version != w.Version {
+		return nil, ErrWatchlistVersionConflict
+	}
+	return w, nil
+}
+
+// save writes the watchlist to the table, then stores it. The change is rejected when either fails and the table
+// is restored when the storage fails, so the stored watchlists, the API and the rule lookups always agree
+func (m *WatchlistManager) save(w *view.Watchlist) error {
+	if err := m.materialize(w); err != nil {
+		return err
+	}
+	previous, existed := m.watchlists[w.Name]
+	m.watchlists[w.Name] = w
+	if err := m.persist(); err != nil {
+		if existed {
+			m.watchlists[w.Name] = previous
+			m.restore(previous)
+		} else {
+			delete(m.watchlists, w.Name)
+			m.restore(&view.Watchlist{Name: w.Name})
+		}
+		return err
+	}
+	m.logger.Infof("saved watchlist %v version %v with %v values", w.Name, w.Version, len(w.Values))
+	return nil
+}
+
+// materialize replaces the rows of the watchlist in the table, after syncing the table when it is behind
+func (m *WatchlistManager) materialize(w *view.Watchlist) error {
+	if m.table == nil {
+		return nil
+	}
+	if err := m.sync(); err != nil {
+		return err
+	}
+	return m.table.Write(w)
+}
+
+// restore writes back the rows of a watchlist after a change failed to be stored. When that fails too, the whole
+// table is synced on the next change
+func (m *WatchlistManager) restore(w *view.Watchlist) {
+	if err := m.table.Write(w); err != nil {
+		m.synced = false
+		m.logger.Errorf("error in restoring watchlist %v in the watchlist table, it is synced on the next change: %v", w.Name, err)
+	}
+}
+
+// sync replaces the rows of the table by the stored watchlists, unless it already holds them
+func (m *WatchlistManager) sync() error {
+	if m.synced {
+		return nil
+	}
+	watchlists := make([]*view.Watchlist, 0, len(m.watchlists))
+	for _, w := range m.watchlists {
+		watchlists = append(watchlists, w)
+	}
+	if err := m.table.Sync(watchlists); err != nil {
+		return err
+	}
+	m.synced = true
+	return nil
+}
+
+func (m *WatchlistManager) load() error {
+	data, err := os.ReadFile(m.storagePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error in reading the watchlist storage")
+	}
+	var watchlists []*view.Watchlist
+	if err := json.Unmarshal(data, &watchlists); err != nil {
+		return errors.Wrap(err, "error in parsing the watchlist storage")
+	}
+	for _, w := range watchlists {
+		m.watchlists[w.Name] = w
+	}
+	return nil
+}
+
+func (m *WatchlistManager) persist() error {
+	watchlists := make([]*view.Watchlist, 0, len(m.watchlists))
+	for _, w := range m.watchlists {
+		watchlists = append(watchlists, w)
+	}
+	sort.Slice(watchlists, func(i, j int) bool { return watchlists[i].Name < watchlists[j].Name })
+	data, err := json.MarshalIndent(watchlists, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.storagePath), 0755); err != nil {
+		return errors.Wrap(err, "error in creating the watchlist storage directory")
+	}
+	return errors.Wrap(writeFileAtomic(m.storagePath, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	}), "error in writing the watchlist storage")
+}
+
+// writeFileAtomic writes a file through a hidden temporary file renamed over it, so readers never see a partial
+// file
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), fmt.Sprintf(".%v.*", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/util"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// watchlistTableTimeout bounds every write of the watchlist table
+const watchlistTableTimeout = 30 * time.Second
+
+type (
+	// watchlistTable is the table the watchlists are materialized to, which rule jobs look WATCHLIST filters up in
+	watchlistTable interface {
+		// Sync creates the table when it does not exist and replaces its rows by the rows of the watchlists
+		Sync(watchlists []*view.Watchlist) error
+		// Write replaces the rows of the watchlist, one row of name and value per value
+		Write(w *view.Watchlist) error
+	}
+	// jdbcWatchlistTable is the watchlist table in the PostgreSQL database the rule jobs look up with the jdbc
+	// connector. The database is connected on the first write, so the manager starts while it is unavailable
+	jdbcWatchlistTable struct {
+		cfg *config.Watchlist
+		db  *sql.DB
+	}
+)
+
+func newJDBCWatchlistTable(cfg *config.Watchlist) *jdbcWatchlistTable {
+	return &jdbcWatchlistTable{cfg: cfg}
+}
+
+func (t *jdbcWatchlistTable) Sync(watchlists []*view.Watchlist) error {
+	if err := t.open(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), watchlistTableTimeout)
+	defer cancel()
+	err := t.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (%v VARCHAR(128) NOT NULL, %v TEXT NOT NULL, PRIMARY KEY (%v, %v))",
+			t.cfg.Table, view.WatchlistNameField, view.WatchlistValueField, view.WatchlistNameField, view.WatchlistValueField)); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(watchlists))
+		for _, w := range watchlists {
+			if err := t.writeRows(ctx, tx, w); err != nil {
+				return err
+			}
+			names = append(names, w.Name)
+		}
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v WHERE NOT (%v = ANY($1))", t.cfg.Table, view.WatchlistNameField),
+			pq.Array(names))
+		return err
+	})
+	return errors.Wrap(err, "error in syncing the watchlist table")
+}
+
+func (t *jdbcWatchlistTable) Write(w *view.Watchlist) error {
+	if err := t.open(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), watchlistTableTimeout)
+	defer cancel()
+	err := t.inTx(ctx, func(tx *sql.Tx) error {
+		return t.writeRows(ctx, tx, w)
+	})
+	return errors.Wrapf(err, "error in writing watchlist %v to the watchlist table", w.Name)
+}
+
+// open connects to the database of the table, the jdbc URL of the rule jobs without its jdbc: prefix
+func (t *jdbcWatchlistTable) open() error {
+	if t.db != nil {
+		return nil
+	}
+	dsn, err := url.Parse(strings.TrimPrefix(t.cfg.URL, "jdbc:"))
+	if err != nil {
+		return errors.Wrap(err, "error in parsing the watchlist table url")
+	}
+	if t.cfg.Username != "" {
+		password, err := util.ResolveSecret(t.cfg.Password)
+		if err != nil {
+			return errors.Wrap(err, "error in resolving the watchlist table password")
+		}
+		dsn.User = url.UserPassword(t.cfg.Username, password)
+	}
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return errors.Wrap(err, "error in opening the watchlist table database")
+	}
+	t.db = db
+	return nil
+}
+
+// writeRows replaces the rows of the watchlist in the transaction
+func (t *jdbcWatchlistTable) writeRows(ctx context.Context, tx *sql.Tx, w *view.Watchlist) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v WHERE %v = $1", t.cfg.Table, view.WatchlistNameField), w.Name); err != nil {
+		return err
+	}
+	if len(w.Values) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %v (%v, %v) VALUES ($1, $2)",
+		t.cfg.Table, view.WatchlistNameField, view.WatchlistValueField))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, value := range w.Values {
+		if _, err := stmt.ExecContext(ctx, w.Name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inTx runs write in a transaction, committed when it succeeds
+func (t *jdbcWatchlistTable) inTx(ctx context.Context, write func(tx *sql.Tx) error) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := write(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"errors"
+	"flink_ueba_manager/view"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeWatchlistTable keeps the rows of the watchlist table in memory, err fails every write
+type fakeWatchlistTable struct {
+	rows  map[string][]string
+	syncs int
+	err   error
+}
+
+func newFakeWatchlistTable() *fakeWatchlistTable {
+	return &fakeWatchlistTable{rows: make(map[string][]string)}
+}
+
+func (t *fakeWatchlistTable) Sync(watchlists []*view.Watchlist) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.syncs++
+	t.rows = make(map[string][]string)
+	for _, w := range watchlists {
+		t.rows[w.Name] = w.Values
+	}
+	return nil
+}
+
+func (t *fakeWatchlistTable) Write(w *view.Watchlist) error {
+	if t.err != nil {
+		return t.err
+	}
+	if len(w.Values) == 0 {
+		delete(t.rows, w.Name)
+	} else {
+		t.rows[w.Name] = w.Values
+	}
+	return nil
+}
+
+func newTestWatchlistManager(t *testing.T, table watchlistTable) *WatchlistManager {
+	m, err := newWatchlistManager(filepath.Join(t.TempDir(), "watchlists.json"), table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestWatchlistTableWriteFails(t *testing.T) {
+	table := newFakeWatchlistTable()
+	m := newTestWatchlistManager(t, table)
+	if _, err := m.Create(&view.WatchlistRequest{Name: "admins", Values: []string{"root"}}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := os.ReadFile(m.storagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table.err = errors.New("database is down")
+	if _, err := m.Update("admins", &view.WatchlistRequest{Values: []string{"root", "alice"}, Version: 1}); err == nil {
+		t.Fatal("expected the update to fail with the table")
+	}
+	if w, _ := m.Get("admins"); w.Version != 1 || !reflect.DeepEqual(w.Values, []string{"root"}) {
+		t.Errorf("the failed update changed the watchlist: %+v", w)
+	}
+	if after, _ := os.ReadFile(m.storagePath); string(after) != string(stored) {
+		t.Errorf("the failed update changed the storage")
+	}
+
+	table.err = nil
+	if _, err := m.Update("admins", &view.WatchlistRequest{Values: []string{"root", "alice"}, Version: 1}); err != nil {
+		t.Fatalf("the retry of the failed update was rejected: %v", err)
+	}
+	if !reflect.DeepEqual(table.rows["admins"], []string{"alice", "root"}) {
+		t.Errorf("unexpected rows %v", table.rows["admins"])
+	}
+}
+
+func TestWatchlistStorageFails(t *testing.T) {
+	table := newFakeWatchlistTable()
+	m := newTestWatchlistManager(t, table)
+	if _, err := m.Create(&view.WatchlistRequest{Name: "admins", Values: []string{"root"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the storage directory is a file, so storing fails
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.storagePath = filepath.Join(blocker, "watchlists.json")
+	if _, err := m.Update("admins", &view.WatchlistRequest{Values: []string{"alice"}}); err == nil {
+		t.Fatal("expected the update to fail with the storage")
+	}
+	if _, err := m.Create(&view.WatchlistRequest{Name: "hosts", Values: []string{"db1"}}); err == nil {
+		t.Fatal("expected the creation to fail with the storage")
+	}
+	if err := m.Delete("admins"); err == nil {
+		t.Fatal("expected the deletion to fail with the storage")
+	}
+	want := map[string][]string{"admins": {"root"}}
+	if !reflect.DeepEqual(table.rows, want) {
+		t.Errorf("the table was not restored: got %v, want %v", table.rows, want)
+	}
+	if w, _ := m.Get("admins"); w.Version != 1 {
+		t.Errorf("the failed changes changed the watchlist: %+v", w)
+	}
+}
+
+func TestWatchlistTableUnavailableAtStartup(t *testing.T) {
+	table := newFakeWatchlistTable()
+	table.err = errors.New("database is down")
+	m := newTestWatchlistManager(t, table)
+
+	if _, err := m.Create(&view.WatchlistRequest{Name: "admins", Values: []string{"root"}}); err == nil {
+		t.Fatal("expected the creation to fail with the table")
+	}
+	table.err = nil
+	if _, err := m.Create(&view.WatchlistRequest{Name: "admins", Values: []string{"root"}}); err != nil {
+		t.Fatal(err)
+	}
+	if table.syncs != 1 {
+		t.Errorf("the table was synced %v times before the first change, want once", table.syncs)
+	}
+	if !reflect.DeepEqual(table.rows, map[string][]string{"admins": {"root"}}) {
+		t.Errorf("unexpected rows %v", table.rows)
+	}
+}
+
+func TestWatchlistDelete(t *testing.T) {
+	table := newFakeWatchlistTable()
+	m := newTestWatchlistManager(t, table)
+	for _, name := range []string{"admins", "hosts"} {
+		if _, err := m.Create(&view.WatchlistRequest{Name: name, Values: []string{"x"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Delete("admins"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(table.rows, map[string][]string{"hosts": {"x"}}) {
+		t.Errorf("unexpected rows %v", table.rows)
+	}
+
+	// a restart syncs the table with the storage
+	restarted, err := newWatchlistManager(m.storagePath, newFakeWatchlistTable())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := restarted.table.(*fakeWatchlistTable).rows; !reflect.DeepEqual(rows, map[string][]string{"hosts": {"x"}}) {
+		t.Errorf("unexpected rows after a restart %v", rows)
+	}
+}
+
+func TestWatchlistWithoutTable(t *testing.T) {
+	m := newTestWatchlistManager(t, nil)
+	if _, err := m.Create(&view.WatchlistRequest{Name: "admins", Values: []string{"root"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete("admins"); err != nil {
+		t.Fatal(err)
+	}
+}
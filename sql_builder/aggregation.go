@@ -0,0 +1,68 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AggregationExpSQLBuilder
+
+type (
+	// AggregationExpSQLBuilder aggregates the rows of each group and window of a window table and keeps the windows
+	// whose aggregate passes the condition. The rows get the columns window_start, window_end, the group fields and
+	// the aggregate
+	AggregationExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithWindowTable(window string) AggregationExpSQLBuilder
+		WithGroupBy(fields ...string) AggregationExpSQLBuilder
+		WithAggregate(name string, expression string) AggregationExpSQLBuilder
+		WithCondition(operator string, threshold float64) AggregationExpSQLBuilder
+	}
+	aggregationExpSQLBuilderImpl struct {
+		window     string
+		groupBy    []string
+		name       string
+		expression string
+		operator   string
+		threshold  float64
+	}
+)
+
+func NewAggregationExpSQLBuilder() AggregationExpSQLBuilder {
+	return &aggregationExpSQLBuilderImpl{}
+}
+
+// WithWindowTable sets the window table the rows are read from, see WindowTableSQLBuilder
+func (v *aggregationExpSQLBuilderImpl) WithWindowTable(window string) AggregationExpSQLBuilder {
+	v.window = window
+	return v
+}
+
+func (v *aggregationExpSQLBuilderImpl) WithGroupBy(fields ...string) AggregationExpSQLBuilder {
+	v.groupBy = append(v.groupBy, fields...)
+	return v
+}
+
+func (v *aggregationExpSQLBuilderImpl) WithAggregate(name string, expression string) AggregationExpSQLBuilder {
+	v.name = name
+	v.expression = expression
+	return v
+}
+
+// WithCondition sets the comparison of the aggregate to the threshold, such as > 100
+func (v *aggregationExpSQLBuilderImpl) WithCondition(operator string, threshold float64) AggregationExpSQLBuilder {
+	v.operator = operator
+	v.threshold = threshold
+	return v
+}
+
+func (v *aggregationExpSQLBuilderImpl) Build() string {
+	groupBy := []string{"window_start", "window_end"}
+	for _, f := range v.groupBy {
+		groupBy = append(groupBy, QuoteIdentifier(f))
+	}
+	group := strings.Join(groupBy, ",")
+	return fmt.Sprintf("SELECT %[1]v,%[2]v AS %[3]v FROM %[4]v GROUP BY %[1]v HAVING %[2]v %[5]v %[6]v",
+		group, v.expression, QuoteIdentifier(v.name), v.window, v.operator, strconv.FormatFloat(v.threshold, 'f', -1, 64))
+}
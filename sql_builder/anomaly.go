@@ -0,0 +1,149 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnomalyExpSQLBuilder
+
+type (
+	// AnomalyExpSQLBuilder renders the windows of a profile whose metric is anomalous. Besides the selected fields
+	// the anomalies have the columns value, expected, baseline_mean, baseline_stddev and baseline_windows,
+	// the baseline columns being null for thresholds
+	AnomalyExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithQueryTable(name string) AnomalyExpSQLBuilder
+		WithFields(fields ...string) AnomalyExpSQLBuilder
+		WithMetric(metric string) AnomalyExpSQLBuilder
+	}
+	// BaselineAnomalyExpSQLBuilder compares each window to the mean plus k standard deviations of the previous
+	// windows of its partition, computed with OVER aggregations ordered by the time attribute
+	BaselineAnomalyExpSQLBuilder interface {
+		AnomalyExpSQLBuilder
+		WithPartition(fields ...string) BaselineAnomalyExpSQLBuilder
+		WithTimeField(ts string) BaselineAnomalyExpSQLBuilder
+		WithMinWindows(minWindows int) BaselineAnomalyExpSQLBuilder
+	}
+	anomalyExpSQLBuilderImpl struct {
+		srcTableName string
+		fields       []string
+		metric       string
+	}
+	thresholdAnomalyExpSQLBuilderImpl struct {
+		*anomalyExpSQLBuilderImpl
+		threshold float64
+	}
+	baselineAnomalyExpSQLBuilderImpl struct {
+		*anomalyExpSQLBuilderImpl
+		partition  []string
+		tsField    string
+		windows    int
+		minWindows int
+		deviations float64
+	}
+)
+
+// NewThresholdAnomalyExpSQLBuilder returns the windows whose metric is above the threshold
+func NewThresholdAnomalyExpSQLBuilder(threshold float64) AnomalyExpSQLBuilder {
+	return &thresholdAnomalyExpSQLBuilderImpl{anomalyExpSQLBuilderImpl: &anomalyExpSQLBuilderImpl{}, threshold: threshold}
+}
+
+// NewBaselineAnomalyExpSQLBuilder returns the windows whose metric is above the mean plus deviations standard
+// deviations of the previous windows of its partition, of which at most windows are considered
+func NewBaselineAnomalyExpSQLBuilder(windows int, deviations float64) BaselineAnomalyExpSQLBuilder {
+	return &baselineAnomalyExpSQLBuilderImpl{
+		anomalyExpSQLBuilderImpl: &anomalyExpSQLBuilderImpl{},
+		windows:                  windows,
+		minWindows:               1,
+		deviations:               deviations,
+	}
+}
+
+func (v *anomalyExpSQLBuilderImpl) fieldList() string {
+	quoted := make([]string, len(v.fields))
+	for i, f := range v.fields {
+		quoted[i] = QuoteIdentifier(f)
+	}
+	return strings.Join(quoted, ",")
+}
+
+func (v *anomalyExpSQLBuilderImpl) value() string {
+	return fmt.Sprintf("CAST(%v AS DOUBLE)", QuoteIdentifier(v.metric))
+}
+
+func (v *thresholdAnomalyExpSQLBuilderImpl) WithQueryTable(name string) AnomalyExpSQLBuilder {
+	v.srcTableName = name
+	return v
+}
+
+func (v *thresholdAnomalyExpSQLBuilderImpl) WithFields(fields ...string) AnomalyExpSQLBuilder {
+	v.fields = fields
+	return v
+}
+
+func (v *thresholdAnomalyExpSQLBuilderImpl) WithMetric(metric string) AnomalyExpSQLBuilder {
+	v.metric = metric
+	return v
+}
+
+func (v *thresholdAnomalyExpSQLBuilderImpl) Build() string {
+	return fmt.Sprintf("SELECT %v,%v AS `value`,CAST(%v AS DOUBLE) AS `expected`,"+
+		"CAST(NULL AS DOUBLE) AS `baseline_mean`,CAST(NULL AS DOUBLE) AS `baseline_stddev`,CAST(NULL AS BIGINT) AS `baseline_windows` "+
+		"FROM %v WHERE %v > %v", v.fieldList(), v.value(), v.threshold, v.srcTableName, v.value(), v.threshold)
+}
+
+func (v *baselineAnomalyExpSQLBuilderImpl) WithQueryTable(name string) AnomalyExpSQLBuilder {
+	v.srcTableName = name
+	return v
+}
+
+func (v *baselineAnomalyExpSQLBuilderImpl) WithFields(fields ...string) AnomalyExpSQLBuilder {
+	v.fields = fields
+	return v
+}
+
+func (v *baselineAnomalyExpSQLBuilderImpl) WithMetric(metric string) AnomalyExpSQLBuilder {
+	v.metric = metric
+	return v
+}
+
+func (v *baselineAnomalyExpSQLBuilderImpl) WithPartition(fields ...string) BaselineAnomalyExpSQLBuilder {
+	v.partition = fields
+	return v
+}
+
+func (v *baselineAnomalyExpSQLBuilderImpl) WithTimeField(ts string) BaselineAnomalyExpSQLBuilder {
+	v.tsField = ts
+	return v
+}
+
+// WithMinWindows sets how many previous windows a partition needs before its windows are compared, 1 by default
+func (v *baselineAnomalyExpSQLBuilderImpl) WithMinWindows(minWindows int) BaselineAnomalyExpSQLBuilder {
+	v.minWindows = minWindows
+	return v
+}
+
+// Build renders the baseline in three steps. Flink OVER windows end at the current row, so the sums are taken
+// over the current and previous windows and the current window is subtracted from them. The standard deviation
+// is the population one, derived from the sum of squares
+func (v *baselineAnomalyExpSQLBuilderImpl) Build() string {
+	partition := make([]string, len(v.partition))
+	for i, f := range v.partition {
+		partition[i] = QuoteIdentifier(f)
+	}
+	fields := v.fieldList()
+	value := v.value()
+	sums := fmt.Sprintf("SELECT %[1]v,%[2]v AS `value`,"+
+		"SUM(%[2]v) OVER w - %[2]v AS `baseline_sum`,"+
+		"SUM(%[2]v * %[2]v) OVER w - %[2]v * %[2]v AS `baseline_sum_squares`,"+
+		"COUNT(*) OVER w - 1 AS `baseline_windows` "+
+		"FROM %[3]v WINDOW w AS (PARTITION BY %[4]v ORDER BY %[5]v ROWS BETWEEN %[6]v PRECEDING AND CURRENT ROW)",
+		fields, value, v.srcTableName, strings.Join(partition, ","), QuoteIdentifier(v.tsField), v.windows)
+	baseline := fmt.Sprintf("SELECT %v,`value`,`baseline_sum` / `baseline_windows` AS `baseline_mean`,"+
+		"SQRT(GREATEST(`baseline_sum_squares` / `baseline_windows` - POWER(`baseline_sum` / `baseline_windows`, 2), 0)) AS `baseline_stddev`,"+
+		"`baseline_windows` FROM (%v) WHERE `baseline_windows` >= %v", fields, sums, v.minWindows)
+	expected := fmt.Sprintf("`baseline_mean` + %v * `baseline_stddev`", v.deviations)
+	return fmt.Sprintf("SELECT %v,`value`,%v AS `expected`,`baseline_mean`,`baseline_stddev`,`baseline_windows` "+
+		"FROM (%v) WHERE `value` > %v", fields, expected, baseline, expected)
+}
@@ -2,6 +2,9 @@ package sql_builder
 
 import (
 	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
 )
 
 type FlinkSQLBuilder interface {
@@ -52,11 +55,16 @@ type (
 		FlinkSQLBuilder
 		WithColumn(columnName string, columnType string) SchemaSQLBuilder
 		WithTimestampField(originalTsColumn string, convertedTsColumn string) SchemaSQLBuilder
+		WithEventTimeField(convertedTsColumn string, expression string, watermarkDelay time.Duration) SchemaSQLBuilder
+		WithMetadataColumn(columnName string, columnType string, key string, virtual bool) SchemaSQLBuilder
+		WithComputedColumn(columnName string, expression string) SchemaSQLBuilder
+		WithPrimaryKey(columns ...string) SchemaSQLBuilder
 	}
 
 	schemaSQLBuilderImpl struct {
-		schemas      [][]string
-		watermarkStr string
+		schemas       [][]string
+		watermarkStr  string
+		primaryKeyStr string
 	}
 )
 
@@ -72,10 +80,41 @@ func (s *schemaSQLBuilderImpl) WithColumn(columnName string, columnType string)
 	return s
 }
 
+// WithMetadataColumn adds a column read from the metadata of the connector, such as the Kafka offset.
+// Virtual columns are only read, they are skipped when the table is written to
+func (s *schemaSQLBuilderImpl) WithMetadataColumn(columnName string, columnType string, key string, virtual bool) SchemaSQLBuilder {
+	definition := columnType + " METADATA"
+	if key != columnName {
+		definition += fmt.Sprintf(" FROM '%v'", escapeLiteral(key))
+	}
+	if virtual {
+		definition += " VIRTUAL"
+	}
+	s.schemas = append(s.schemas, []string{columnName, definition})
+	return s
+}
+
+// WithComputedColumn adds a virtual column computed by an expression over the other columns
+func (s *schemaSQLBuilderImpl) WithComputedColumn(columnName string, expression string) SchemaSQLBuilder {
+	s.schemas = append(s.schemas, []string{columnName, fmt.Sprintf("AS %v", expression)})
+	return s
+}
+
 func (s *schemaSQLBuilderImpl) WithTimestampField(originalTsColumn string, convertedTsColumn string) SchemaSQLBuilder {
-	col := []string{convertedTsColumn, fmt.Sprintf("AS CAST(%v as TIMESTAMP(3))", originalTsColumn)}
-	s.schemas = append(s.schemas, col)
-	s.watermarkStr = fmt.Sprintf("WATERMARK for %v AS %v - INTERVAL '1' minute", convertedTsColumn, convertedTsColumn)
+	return s.WithEventTimeField(convertedTsColumn, NewTimestampExpSQLBuilder(originalTsColumn).Build(), DefaultWatermarkDelay)
+}
+
+// WithEventTimeField adds a column computed by the expression and declares it as the event time,
+// with a watermark lagging behind by the delay
+func (s *schemaSQLBuilderImpl) WithEventTimeField(convertedTsColumn string, expression string, watermarkDelay time.Duration) SchemaSQLBuilder {
+	s.WithComputedColumn(convertedTsColumn, expression)
+	s.watermarkStr = fmt.Sprintf("WATERMARK for %v AS %v - %v", convertedTsColumn, convertedTsColumn, buildInterval(watermarkDelay))
+	return s
+}
+
+// WithPrimaryKey declares the primary key of the table. Flink does not enforce it, upsert sinks use it as the key
+func (s *schemaSQLBuilderImpl) WithPrimaryKey(columns ...string) SchemaSQLBuilder {
+	s.primaryKeyStr = fmt.Sprintf("PRIMARY KEY (%v) NOT ENFORCED", strings.Join(columns, ", "))
 	return s
 }
 
@@ -85,11 +124,12 @@ func (s *schemaSQLBuilderImpl) Build() string {
 		commonStr = commonStr + col[0] + " " + col[1] + ","
 	}
 	if s.watermarkStr != "" {
-		commonStr += s.watermarkStr
-	} else {
-		commonStr = commonStr[:len(commonStr)-1]
+		commonStr += s.watermarkStr + ","
 	}
-	return commonStr
+	if s.primaryKeyStr != "" {
+		commonStr += s.primaryKeyStr + ","
+	}
+	return commonStr[:len(commonStr)-1]
 }
 
 // ConnectorBuilder
@@ -98,31 +138,78 @@ type (
 	ConnectorBuilder interface {
 		FlinkSQLBuilder
 		WithOption(key, value string) ConnectorBuilder
+		Err() error
 	}
 	connectorBuilderImpl struct {
+		keys    []string
 		options map[string]string
+		err     error
 	}
 )
 
 func NewConnectorBuilder() ConnectorBuilder {
-	return &connectorBuilderImpl{options: make(map[string]string)}
+	return newConnectorBuilderImpl()
 }
 
+func newConnectorBuilderImpl() *connectorBuilderImpl {
+	return &connectorBuilderImpl{
+		keys:    make([]string, 0),
+		options: make(map[string]string),
+	}
+}
+
+// Build renders the options in insertion order, with the `connector` option always first
 func (c *connectorBuilderImpl) Build() string {
-	commonStr := ""
-	for column, cType := range c.options {
-		commonStr = commonStr + fmt.Sprintf("'%v' = '%v'", column, cType)
-		commonStr = commonStr + ","
+	keys := make([]string, 0, len(c.keys))
+	if _, ok := c.options["connector"]; ok {
+		keys = append(keys, "connector")
 	}
-	commonStr = commonStr[:len(commonStr)-1] // delete ',' in last string
-	return commonStr
+	for _, k := range c.keys {
+		if k != "connector" {
+			keys = append(keys, k)
+		}
+	}
+	opts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		opts = append(opts, fmt.Sprintf("'%v' = '%v'", escapeLiteral(k), escapeLiteral(c.options[k])))
+	}
+	return strings.Join(opts, ",")
 }
 
+// WithOption adds an option to the connector. Setting the same key twice keeps the first value
+// and records an error, which is reported by Err
 func (c *connectorBuilderImpl) WithOption(key, value string) ConnectorBuilder {
-	c.options[key] = value
+	c.withOption(key, value)
 	return c
 }
 
+func (c *connectorBuilderImpl) withOption(key, value string) {
+	if _, ok := c.options[key]; ok {
+		if c.err == nil {
+			c.err = errors.Errorf("duplicate connector option '%v'", key)
+		}
+		return
+	}
+	c.keys = append(c.keys, key)
+	c.options[key] = value
+}
+
+// withFormat adds the format and its options, under the key or value prefix when set
+func (c *connectorBuilderImpl) withFormat(prefix string, format FormatBuilder) {
+	if prefix != "" {
+		prefix += "."
+	}
+	c.withOption(prefix+"format", format.Name())
+	for _, opt := range format.Options() {
+		c.withOption(prefix+format.Name()+"."+opt.Key, opt.Value)
+	}
+}
+
+// Err returns the first error encountered while adding options
+func (c *connectorBuilderImpl) Err() error {
+	return c.err
+}
+
 // ViewSQLBuilder
 
 type (
@@ -179,6 +266,32 @@ func (v *selectExpSQLBuilderImpl) Build() string {
 	return fmt.Sprintf("SELECT %v FROM %v", v.fields, v.srcTableName)
 }
 
+// ProjectionSQLBuilder
+
+type (
+	// ProjectionSQLBuilder renders the fields of a SELECT as expressions aliased to named columns
+	ProjectionSQLBuilder interface {
+		FlinkSQLBuilder
+		WithField(name string, expression string) ProjectionSQLBuilder
+	}
+	projectionSQLBuilderImpl struct {
+		fields []string
+	}
+)
+
+func NewProjectionSQLBuilder() ProjectionSQLBuilder {
+	return &projectionSQLBuilderImpl{}
+}
+
+func (p *projectionSQLBuilderImpl) WithField(name string, expression string) ProjectionSQLBuilder {
+	p.fields = append(p.fields, fmt.Sprintf("%v AS %v", expression, QuoteIdentifier(name)))
+	return p
+}
+
+func (p *projectionSQLBuilderImpl) Build() string {
+	return strings.Join(p.fields, ",")
+}
+
 // FilterExpSQLBuilder
 
 type (
@@ -213,6 +326,7 @@ type (
 		WithAttributes(field []string) TumblingCntWindowExpSQLBuilder
 		WithTimestampField(ts string) TumblingCntWindowExpSQLBuilder
 		WithMinuteInterval(interval int64) TumblingCntWindowExpSQLBuilder
+		WithAggregate(name string, expression string) TumblingCntWindowExpSQLBuilder
 	}
 	tumblingCntWindowExpSQLBuilderImpl struct {
 		srcTableName   string
@@ -220,6 +334,7 @@ type (
 		attributes     []string
 		tsField        string
 		minuteInterval int64
+		aggregates     []string
 	}
 )
 
@@ -228,9 +343,24 @@ func NewTumblingCntWindowExpSQLBuilder() TumblingCntWindowExpSQLBuilder {
 }
 
 func (v *tumblingCntWindowExpSQLBuilderImpl) Build() string {
-	return fmt.Sprintf("SELECT window_start,window_end,COUNT(*) AS `cnt`,%v "+
+	return fmt.Sprintf("SELECT window_start,window_end,window_time,COUNT(*) AS `cnt`,%v%v "+
 		"FROM TABLE(TUMBLE(TABLE %v, DESCRIPTOR(%v),INTERVAL '%v' MINUTES)) "+
-		"GROUP BY window_start,window_end, %v", v.buildQueryField(), v.srcTableName, v.tsField, v.minuteInterval, v.buildGroupByField())
+		"GROUP BY window_start,window_end,window_time, %v", v.buildQueryField(), v.buildAggregateFields(), v.srcTableName, v.tsField,
+		v.minuteInterval, v.buildGroupByField())
+}
+
+// WithAggregate adds an aggregate of the windows besides cnt, such as COUNT(DISTINCT `port`) named distinct_ports
+func (v *tumblingCntWindowExpSQLBuilderImpl) WithAggregate(name string, expression string) TumblingCntWindowExpSQLBuilder {
+	v.aggregates = append(v.aggregates, fmt.Sprintf("%v AS %v", expression, QuoteIdentifier(name)))
+	return v
+}
+
+func (v *tumblingCntWindowExpSQLBuilderImpl) buildAggregateFields() string {
+	fields := ""
+	for _, agg := range v.aggregates {
+		fields = fields + "," + agg
+	}
+	return fields
 }
 
 func (v *tumblingCntWindowExpSQLBuilderImpl) WithQueryTable(name string) TumblingCntWindowExpSQLBuilder {
@@ -290,10 +420,12 @@ type (
 		FlinkSQLBuilder
 		WithDestinationTable(name string) InsertSQLBuilder
 		WithExpression(exp string) InsertSQLBuilder
+		WithColumns(columns ...string) InsertSQLBuilder
 	}
 	insertSQLBuilderImpl struct {
 		destinationTableName string
 		expression           string
+		columns              []string
 	}
 )
 
@@ -311,8 +443,21 @@ func (v *insertSQLBuilderImpl) WithExpression(exp string) InsertSQLBuilder {
 	return v
 }
 
+// WithColumns names the columns of the destination table the expression is inserted into, in order
+func (v *insertSQLBuilderImpl) WithColumns(columns ...string) InsertSQLBuilder {
+	v.columns = columns
+	return v
+}
+
 func (v *insertSQLBuilderImpl) Build() string {
-	return fmt.Sprintf("INSERT INTO %v %v", v.destinationTableName, v.expression)
+	if len(v.columns) == 0 {
+		return fmt.Sprintf("INSERT INTO %v %v", v.destinationTableName, v.expression)
+	}
+	quoted := make([]string, len(v.columns))
+	for i, col := range v.columns {
+		quoted[i] = QuoteIdentifier(col)
+	}
+	return fmt.Sprintf("INSERT INTO %v (%v) %v", v.destinationTableName, strings.Join(quoted, ","), v.expression)
 }
 
 type (
@@ -346,6 +491,44 @@ func (v *statementSetSQLBuilderImpl) Build() string {
 	return executeStr + beginStr + stms + endStr
 }
 
+// ExplainStatement turns an EXECUTE statement, e.g. an EXECUTE STATEMENT SET, into its EXPLAIN counterpart
+func ExplainStatement(stm string) string {
+	trimmed := strings.TrimSpace(stm)
+	if len(trimmed) >= len("EXECUTE") && strings.EqualFold(trimmed[:len("EXECUTE")], "EXECUTE") {
+		return "EXPLAIN" + trimmed[len("EXECUTE"):]
+	}
+	return "EXPLAIN " + trimmed
+}
+
+// ShowJobsStatement lists the jobs of the cluster with their ID, name, status and start time
+const ShowJobsStatement = "SHOW JOBS"
+
+// StopJobStatement stops a job, without a savepoint
+func StopJobStatement(jobID string) string {
+	return fmt.Sprintf("STOP JOB %v", QuoteLiteral(jobID))
+}
+
+// StopJobWithSavepointStatement stops a job after taking a savepoint into the state.savepoints.dir of the cluster,
+// its result is the path of the savepoint
+func StopJobWithSavepointStatement(jobID string) string {
+	return fmt.Sprintf("STOP JOB %v WITH SAVEPOINT", QuoteLiteral(jobID))
+}
+
+// ResetConfigStatement resets a setting of the session to its default
+func ResetConfigStatement(key string) string {
+	return fmt.Sprintf("RESET %v", QuoteLiteral(key))
+}
+
+// DropTableStatement drops a table declared by the session, if it exists
+func DropTableStatement(name string) string {
+	return fmt.Sprintf("DROP TABLE IF EXISTS %v", name)
+}
+
+// DropViewStatement drops a view declared by the session, if it exists
+func DropViewStatement(name string) string {
+	return fmt.Sprintf("DROP VIEW IF EXISTS %v", name)
+}
+
 type (
 	SetConfigSQLBuilder interface {
 		FlinkSQLBuilder
@@ -370,3 +553,18 @@ func (v *setConfigSQLBuilderImpl) WithConfig(key string, value string) SetConfig
 func (v *setConfigSQLBuilderImpl) Build() string {
 	return fmt.Sprintf("SET '%v' = '%v'", v.key, v.value)
 }
+
+// QuoteIdentifier renders the name as a quoted identifier, so reserved words can be used as column names
+func QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// QuoteLiteral renders the value as a SQL string literal
+func QuoteLiteral(s string) string {
+	return "'" + escapeLiteral(s) + "'"
+}
+
+// escapeLiteral escapes single quotes so the value can be embedded in a SQL string literal
+func escapeLiteral(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
@@ -0,0 +1,174 @@
+package sql_builder
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// assertGolden compares the rendered statements, one per line, with testdata/<name>.golden
+func assertGolden(t *testing.T, name string, statements ...string) {
+	t.Helper()
+	got := strings.Join(statements, "\n") + "\n"
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%v differs from %v\ngot:\n%vwant:\n%v", name, path, got, want)
+	}
+}
+
+func TestConnectorOptionOrder(t *testing.T) {
+	generic := NewConnectorBuilder().
+		WithOption("path", "/data/watchlist").
+		WithOption("connector", "filesystem").
+		WithOption("format", "csv")
+	if err := generic.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	kafka := NewKafkaConnectorBuilder().
+		WithTopic("logs").
+		WithBootstrapServers("kafka-1:9092,kafka-2:9092").
+		WithGroupID("behavior_b1").
+		WithStartupMode(KafkaStartupModeTimestamp).
+		WithStartupTimestamp(1700000000000).
+		WithFormat(NewJSONFormatBuilder().WithTimestampFormat(JSONTimestampFormatISO8601).WithIgnoreParseErrors(true))
+	if err := kafka.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	upsert := NewUpsertKafkaConnectorBuilder().
+		WithTopic("assets").
+		WithBootstrapServers("kafka-1:9092").
+		WithKeyFormat(NewJSONFormatBuilder()).
+		WithValueFormat(NewAvroConfluentFormatBuilder("http://registry:8081").WithSubject("assets-value")).
+		WithValueFieldsInclude("EXCEPT_KEY")
+	if err := upsert.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertGolden(t, "connector_option_order", generic.Build(), kafka.Build(), upsert.Build())
+}
+
+func TestConnectorOptionEscaping(t *testing.T) {
+	connector := NewKafkaConnectorBuilder().
+		WithTopic("it's").
+		WithBootstrapServers("kafka:9092").
+		WithSecurityProtocol(KafkaSecurityProtocolSASLPlaintext).
+		WithSASLMechanism(KafkaSASLMechanismPlain).
+		WithSASLJAASConfig(PlainJAASConfig(`o"brien\`, "${secret:env:KAFKA_PASSWORD|jaas}"))
+	if err := connector.Err(); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "connector_option_escaping", connector.Build())
+}
+
+func TestConnectorDuplicateOption(t *testing.T) {
+	cases := []struct {
+		name      string
+		connector ConnectorBuilder
+		want      string
+	}{
+		{
+			name:      "generic",
+			connector: NewConnectorBuilder().WithOption("path", "/a").WithOption("path", "/b").WithOption("format", "csv").WithOption("format", "json"),
+			want:      "duplicate connector option 'path'",
+		},
+		{
+			name:      "connector",
+			connector: NewKafkaConnectorBuilder().WithOption("connector", "upsert-kafka"),
+			want:      "duplicate connector option 'connector'",
+		},
+		{
+			name:      "format",
+			connector: NewKafkaConnectorBuilder().WithFormat(NewJSONFormatBuilder()).WithFormat(NewAvroFormatBuilder()),
+			want:      "duplicate connector option 'format'",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.connector.Err()
+			if err == nil {
+				t.Fatalf("expected error %q, got none", c.want)
+			}
+			if err.Error() != c.want {
+				t.Errorf("expected error %q, got %q", c.want, err.Error())
+			}
+		})
+	}
+}
+
+func TestConnectorDuplicateOptionKeepsFirstValue(t *testing.T) {
+	connector := NewConnectorBuilder().WithOption("connector", "filesystem").WithOption("path", "/a").WithOption("path", "/b")
+	if got, want := connector.Build(), "'connector' = 'filesystem','path' = '/a'"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTable(t *testing.T) {
+	schema := NewSchemaSQLBuilder().
+		WithColumn("user_name", "STRING").
+		WithColumn("bytes", "BIGINT").
+		WithMetadataColumn("kafka_ts", "TIMESTAMP_LTZ(3)", "timestamp", true).
+		WithEventTimeField("converted_ts", "TO_TIMESTAMP_LTZ(`ts`, 3)", 5*time.Second).
+		Build()
+	connector := NewKafkaConnectorBuilder().
+		WithTopic("logs").
+		WithBootstrapServers("kafka:9092").
+		WithFormat(NewJSONFormatBuilder()).
+		Build()
+	source := NewTableSQLBuilder("log_source_b1").WithSchema(schema).WithConnector(connector).Build()
+
+	keyed := NewTableSQLBuilder("assets").
+		WithSchema(NewSchemaSQLBuilder().WithColumn("host", "STRING").WithColumn("owner", "STRING").WithPrimaryKey("host").Build()).
+		WithConnector(NewConnectorBuilder().WithOption("connector", "blackhole").Build()).
+		Build()
+
+	assertGolden(t, "table", source, keyed)
+}
+
+func TestView(t *testing.T) {
+	projection := NewProjectionSQLBuilder().
+		WithField("object", "`user_name`").
+		WithField("total", "SUM(`bytes`)").
+		Build()
+	expression := NewSelectSQLBuilder().
+		WithFields(projection).
+		WithQueryTable("log_source_b1").
+		Build()
+	assertGolden(t, "view", NewViewSQLBuilder("behavior_b1").WithExpression(expression).Build())
+}
+
+func TestStatementSet(t *testing.T) {
+	first := NewInsertSQLBuilder().
+		WithDestinationTable("rule_sink_r1").
+		WithColumns("object", "score").
+		WithExpression("SELECT `object`, `score` FROM rule_r1").
+		Build()
+	second := NewInsertSQLBuilder().
+		WithDestinationTable("rule_sink_r2").
+		WithExpression("SELECT * FROM rule_r2").
+		Build()
+	stmSet := NewStatementSetSQLBuilder().WithInsertStatement(first).WithInsertStatement(second).Build()
+	assertGolden(t, "statement_set", stmSet, ExplainStatement(stmSet))
+}
+
+func TestTimestampISO8601(t *testing.T) {
+	utc := NewTimestampExpSQLBuilder("`ts`").WithFormat(TimestampFormatISO8601)
+	zoned := NewTimestampExpSQLBuilder("`ts`").WithFormat(TimestampFormatISO8601).WithTimezone("Asia/Ho_Chi_Minh")
+	assertGolden(t, "timestamp_iso8601", utc.Build(), zoned.Build())
+}
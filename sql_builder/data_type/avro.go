@@ -0,0 +1,157 @@
+package data_type
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// FromAvroSchema converts the fields of an Avro record schema into Flink data types, following the mapping
+// of the Flink avro formats. Unions of null and one type are nullable, every other type is kept nullable too
+// so the table schema stays a compatible reader schema when producers make fields optional
+func FromAvroSchema(schema string) ([]*RowField, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(schema), &raw); err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	t, err := fromAvro(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %v", err)
+	}
+	if t.Kind != KindRow {
+		return nil, fmt.Errorf("invalid avro schema: top-level type must be a record but is %v", t)
+	}
+	return t.Fields, nil
+}
+
+func fromAvro(schema interface{}, path string) (*DataType, error) {
+	switch s := schema.(type) {
+	case string:
+		return fromAvroPrimitive(s, path)
+	case []interface{}:
+		return fromAvroUnion(s, path)
+	case map[string]interface{}:
+		return fromAvroComplex(s, path)
+	default:
+		return nil, fmt.Errorf("%v: unexpected schema %v", pathName(path), schema)
+	}
+}
+
+func fromAvroPrimitive(name string, path string) (*DataType, error) {
+	switch name {
+	case "boolean":
+		return &DataType{Kind: KindBoolean}, nil
+	case "int":
+		return &DataType{Kind: KindInt}, nil
+	case "long":
+		return &DataType{Kind: KindBigInt}, nil
+	case "float":
+		return &DataType{Kind: KindFloat}, nil
+	case "double":
+		return &DataType{Kind: KindDouble}, nil
+	case "bytes":
+		return &DataType{Kind: KindBytes}, nil
+	case "string":
+		return &DataType{Kind: KindString}, nil
+	default:
+		return nil, fmt.Errorf("%v: unsupported type '%v'", pathName(path), name)
+	}
+}
+
+// fromAvroUnion supports the optional types, unions of null and exactly one other type
+func fromAvroUnion(types []interface{}, path string) (*DataType, error) {
+	var nonNull []interface{}
+	for _, t := range types {
+		if t != "null" {
+			nonNull = append(nonNull, t)
+		}
+	}
+	if len(nonNull) != 1 {
+		return nil, fmt.Errorf("%v: only unions of null and one type are supported", pathName(path))
+	}
+	return fromAvro(nonNull[0], path)
+}
+
+func fromAvroComplex(schema map[string]interface{}, path string) (*DataType, error) {
+	typeName, _ := schema["type"].(string)
+	if logical, ok := schema["logicalType"].(string); ok {
+		if t, ok := fromAvroLogical(logical, typeName, schema); ok {
+			return t, nil
+		}
+	}
+	switch typeName {
+	case "record":
+		fields, _ := schema["fields"].([]interface{})
+		t := &DataType{Kind: KindRow}
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+			name, _ := field["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("%v: record field without name", pathName(path))
+			}
+			fieldType, err := fromAvro(field["type"], joinPath(path, name))
+			if err != nil {
+				return nil, err
+			}
+			t.Fields = append(t.Fields, &RowField{Name: name, Type: fieldType})
+		}
+		if len(t.Fields) == 0 {
+			return nil, fmt.Errorf("%v: record has no fields", pathName(path))
+		}
+		return t, nil
+	case "enum":
+		return &DataType{Kind: KindString}, nil
+	case "fixed":
+		return &DataType{Kind: KindBytes}, nil
+	case "array":
+		element, err := fromAvro(schema["items"], joinPath(path, "[]"))
+		if err != nil {
+			return nil, err
+		}
+		return &DataType{Kind: KindArray, Element: element}, nil
+	case "map":
+		value, err := fromAvro(schema["values"], joinPath(path, "{}"))
+		if err != nil {
+			return nil, err
+		}
+		return &DataType{Kind: KindMap, Key: &DataType{Kind: KindString}, Value: value}, nil
+	default:
+		// a primitive type written as {"type": "long"}
+		return fromAvro(schema["type"], path)
+	}
+}
+
+func fromAvroLogical(logical string, typeName string, schema map[string]interface{}) (*DataType, bool) {
+	switch {
+	case logical == "date" && typeName == "int":
+		return &DataType{Kind: KindDate}, true
+	case logical == "time-millis" && typeName == "int":
+		return &DataType{Kind: KindTime, Precision: 3, HasPrecision: true}, true
+	case logical == "timestamp-millis" && typeName == "long":
+		return &DataType{Kind: KindTimestamp, Precision: 3, HasPrecision: true}, true
+	case logical == "timestamp-micros" && typeName == "long":
+		return &DataType{Kind: KindTimestamp, Precision: 6, HasPrecision: true}, true
+	case logical == "decimal" && (typeName == "bytes" || typeName == "fixed"):
+		precision, _ := schema["precision"].(float64)
+		scale, _ := schema["scale"].(float64)
+		if precision < 1 || precision > 38 || scale < 0 || scale > precision {
+			return nil, false
+		}
+		return &DataType{Kind: KindDecimal, Precision: int(precision), Scale: int(scale), HasPrecision: true}, true
+	}
+	// unknown logical types fall back to their underlying type, as Avro readers do
+	return nil, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return fmt.Sprintf("field '%v'", path)
+}
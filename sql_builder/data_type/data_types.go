@@ -1,6 +1,9 @@
 package data_type
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 func STRING() string {
 	return "STRING"
@@ -29,3 +32,66 @@ func TIMESTAMP() string {
 func TIMESTAMP_PRECISION(prec int) string {
 	return fmt.Sprintf("TIMESTAMP(%v)", prec)
 }
+
+func INT() string {
+	return "INT"
+}
+
+func SMALLINT() string {
+	return "SMALLINT"
+}
+
+func TINYINT() string {
+	return "TINYINT"
+}
+
+func DOUBLE() string {
+	return "DOUBLE"
+}
+
+func DECIMAL(precision, scale int) string {
+	return fmt.Sprintf("DECIMAL(%v, %v)", precision, scale)
+}
+
+func DATE() string {
+	return "DATE"
+}
+
+func TIME() string {
+	return "TIME"
+}
+
+func BYTES() string {
+	return "BYTES"
+}
+
+func TIMESTAMP_LTZ_PRECISION(prec int) string {
+	return fmt.Sprintf("TIMESTAMP_LTZ(%v)", prec)
+}
+
+func ARRAY(element string) string {
+	return fmt.Sprintf("ARRAY<%v>", element)
+}
+
+func MAP(key string, value string) string {
+	return fmt.Sprintf("MAP<%v, %v>", key, value)
+}
+
+// ROW builds a row type from fields rendered by FIELD
+func ROW(fields ...string) string {
+	return fmt.Sprintf("ROW<%v>", strings.Join(fields, ", "))
+}
+
+func FIELD(name string, dataType string) string {
+	return fmt.Sprintf("%v %v", quoteFieldName(name), dataType)
+}
+
+func NOT_NULL(dataType string) string {
+	return dataType + " NOT NULL"
+}
+
+// Validate checks that the string is a Flink SQL data type supported in job schemas
+func Validate(typeStr string) error {
+	_, err := Parse(typeStr)
+	return err
+}
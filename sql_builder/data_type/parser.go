@@ -0,0 +1,441 @@
+package data_type
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Kind string
+
+const (
+	KindString       Kind = "STRING"
+	KindVarchar      Kind = "VARCHAR"
+	KindChar         Kind = "CHAR"
+	KindBoolean      Kind = "BOOLEAN"
+	KindBytes        Kind = "BYTES"
+	KindBinary       Kind = "BINARY"
+	KindVarbinary    Kind = "VARBINARY"
+	KindTinyInt      Kind = "TINYINT"
+	KindSmallInt     Kind = "SMALLINT"
+	KindInt          Kind = "INT"
+	KindBigInt       Kind = "BIGINT"
+	KindFloat        Kind = "FLOAT"
+	KindDouble       Kind = "DOUBLE"
+	KindDecimal      Kind = "DECIMAL"
+	KindDate         Kind = "DATE"
+	KindTime         Kind = "TIME"
+	KindTimestamp    Kind = "TIMESTAMP"
+	KindTimestampLTZ Kind = "TIMESTAMP_LTZ"
+	KindArray        Kind = "ARRAY"
+	KindMap          Kind = "MAP"
+	KindRow          Kind = "ROW"
+)
+
+type (
+	// DataType is the typed representation of a Flink SQL data type
+	DataType struct {
+		Kind Kind
+		// Precision is the length of character and binary strings, or the precision of decimals and time types.
+		// It is only rendered when HasPrecision is set
+		Precision    int
+		Scale        int
+		HasPrecision bool
+		// Element is the element type of an ARRAY
+		Element *DataType
+		// Key and Value are the entry types of a MAP
+		Key   *DataType
+		Value *DataType
+		// Fields are the fields of a ROW
+		Fields  []*RowField
+		NotNull bool
+	}
+	RowField struct {
+		Name string
+		Type *DataType
+	}
+)
+
+var kindAliases = map[string]Kind{
+	"INTEGER": KindInt,
+	"DEC":     KindDecimal,
+	"NUMERIC": KindDecimal,
+}
+
+var precisionLimits = map[Kind][2]int{
+	KindVarchar:      {1, 2147483647},
+	KindChar:         {1, 2147483647},
+	KindBinary:       {1, 2147483647},
+	KindVarbinary:    {1, 2147483647},
+	KindDecimal:      {1, 38},
+	KindTime:         {0, 9},
+	KindTimestamp:    {0, 9},
+	KindTimestampLTZ: {0, 9},
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsNumeric reports whether values of the type are numbers
+func (t *DataType) IsNumeric() bool {
+	switch t.Kind {
+	case KindTinyInt, KindSmallInt, KindInt, KindBigInt, KindFloat, KindDouble, KindDecimal:
+		return true
+	}
+	return false
+}
+
+// IsIntegral reports whether values of the type are exact integers
+func (t *DataType) IsIntegral() bool {
+	switch t.Kind {
+	case KindTinyInt, KindSmallInt, KindInt, KindBigInt:
+		return true
+	}
+	return false
+}
+
+// IsCharacterString reports whether values of the type are character strings
+func (t *DataType) IsCharacterString() bool {
+	switch t.Kind {
+	case KindString, KindVarchar, KindChar:
+		return true
+	}
+	return false
+}
+
+// IsTemporal reports whether values of the type are dates, times or timestamps
+func (t *DataType) IsTemporal() bool {
+	switch t.Kind {
+	case KindDate, KindTime, KindTimestamp, KindTimestampLTZ:
+		return true
+	}
+	return false
+}
+
+// IsComplex reports whether the type is an ARRAY, MAP or ROW
+func (t *DataType) IsComplex() bool {
+	switch t.Kind {
+	case KindArray, KindMap, KindRow:
+		return true
+	}
+	return false
+}
+
+// Nullable reports whether the type accepts NULL values
+func (t *DataType) Nullable() bool {
+	return !t.NotNull
+}
+
+// AsNullable returns a copy of the type without its NOT NULL constraint
+func (t *DataType) AsNullable() *DataType {
+	c := *t
+	c.NotNull = false
+	return &c
+}
+
+// Field returns the type of the named field of a ROW
+func (t *DataType) Field(name string) (*DataType, bool) {
+	if t.Kind != KindRow {
+		return nil, false
+	}
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
+
+func (t *DataType) String() string {
+	var s string
+	switch t.Kind {
+	case KindArray:
+		s = ARRAY(t.Element.String())
+	case KindMap:
+		s = MAP(t.Key.String(), t.Value.String())
+	case KindRow:
+		fields := make([]string, 0, len(t.Fields))
+		for _, f := range t.Fields {
+			fields = append(fields, FIELD(f.Name, f.Type.String()))
+		}
+		s = ROW(fields...)
+	case KindDecimal:
+		s = string(t.Kind)
+		if t.HasPrecision {
+			s = DECIMAL(t.Precision, t.Scale)
+		}
+	default:
+		s = string(t.Kind)
+		if t.HasPrecision {
+			s = fmt.Sprintf("%v(%v)", t.Kind, t.Precision)
+		}
+	}
+	if t.NotNull {
+		s = NOT_NULL(s)
+	}
+	return s
+}
+
+// Parse parses a Flink SQL data type string such as `BIGINT NOT NULL`, `DECIMAL(10, 2)`
+// or `ROW<name STRING, pid BIGINT>`
+func Parse(typeStr string) (*DataType, error) {
+	tokens, err := tokenizeType(typeStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data type '%v': %v", typeStr, err)
+	}
+	p := &typeParser{tokens: tokens}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, fmt.Errorf("invalid data type '%v': %v", typeStr, err)
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("invalid data type '%v': unexpected '%v'", typeStr, p.peek())
+	}
+	return t, nil
+}
+
+type typeParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *typeParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *typeParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *typeParser) next() string {
+	tok := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *typeParser) accept(tok string) bool {
+	if strings.EqualFold(p.peek(), tok) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *typeParser) expect(tok string) error {
+	if !p.accept(tok) {
+		if p.done() {
+			return fmt.Errorf("expected '%v' but reached the end", tok)
+		}
+		return fmt.Errorf("expected '%v' but got '%v'", tok, p.peek())
+	}
+	return nil
+}
+
+func (p *typeParser) parseType() (*DataType, error) {
+	name := strings.ToUpper(p.next())
+	if name == "" {
+		return nil, fmt.Errorf("missing type name")
+	}
+	kind := Kind(name)
+	if alias, ok := kindAliases[name]; ok {
+		kind = alias
+	}
+	t := &DataType{Kind: kind}
+	var err error
+	switch kind {
+	case KindString, KindBoolean, KindBytes, KindTinyInt, KindSmallInt, KindInt, KindBigInt, KindFloat, KindDate:
+	case KindDouble:
+		p.accept("PRECISION")
+	case KindVarchar, KindChar, KindBinary, KindVarbinary, KindTime, KindTimestampLTZ:
+		err = p.parsePrecision(t, false)
+	case KindDecimal:
+		err = p.parsePrecision(t, true)
+	case KindTimestamp:
+		if err = p.parsePrecision(t, false); err == nil && p.accept("WITH") {
+			if err = p.expectAll("LOCAL", "TIME", "ZONE"); err == nil {
+				t.Kind = KindTimestampLTZ
+			}
+		} else if err == nil && p.accept("WITHOUT") {
+			err = p.expectAll("TIME", "ZONE")
+		}
+	case KindArray:
+		err = p.parseArray(t)
+	case KindMap:
+		err = p.parseMap(t)
+	case KindRow:
+		err = p.parseRow(t)
+	default:
+		return nil, fmt.Errorf("unknown type '%v'", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.accept("NOT") {
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		t.NotNull = true
+	} else {
+		p.accept("NULL")
+	}
+	return t, nil
+}
+
+func (p *typeParser) expectAll(tokens ...string) error {
+	for _, tok := range tokens {
+		if err := p.expect(tok); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *typeParser) parsePrecision(t *DataType, withScale bool) error {
+	if !p.accept("(") {
+		return nil
+	}
+	prec, err := p.parseInt()
+	if err != nil {
+		return err
+	}
+	t.Precision = prec
+	t.HasPrecision = true
+	if withScale && p.accept(",") {
+		if t.Scale, err = p.parseInt(); err != nil {
+			return err
+		}
+		if t.Scale > t.Precision {
+			return fmt.Errorf("scale %v is greater than precision %v", t.Scale, t.Precision)
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return err
+	}
+	if limits, ok := precisionLimits[t.Kind]; ok && (prec < limits[0] || prec > limits[1]) {
+		return fmt.Errorf("precision of %v must be between %v and %v", t.Kind, limits[0], limits[1])
+	}
+	return nil
+}
+
+func (p *typeParser) parseInt() (int, error) {
+	tok := p.next()
+	v, err := strconv.Atoi(tok)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("expected a non-negative integer but got '%v'", tok)
+	}
+	return v, nil
+}
+
+func (p *typeParser) parseArray(t *DataType) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	element, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	t.Element = element
+	return p.expect(">")
+}
+
+func (p *typeParser) parseMap(t *DataType) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	key, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	if err := p.expect(","); err != nil {
+		return err
+	}
+	value, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	t.Key, t.Value = key, value
+	return p.expect(">")
+}
+
+// parseRow parses ROW<name type, ...> or ROW(name type, ...)
+func (p *typeParser) parseRow(t *DataType) error {
+	closing := ">"
+	if p.accept("(") {
+		closing = ")"
+	} else if err := p.expect("<"); err != nil {
+		return err
+	}
+	names := make(map[string]struct{})
+	for {
+		name := p.next()
+		if name == "" {
+			return fmt.Errorf("expected a field name but reached the end")
+		}
+		name = strings.Trim(name, "`")
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("duplicate row field '%v'", name)
+		}
+		names[name] = struct{}{}
+		fieldType, err := p.parseType()
+		if err != nil {
+			return err
+		}
+		t.Fields = append(t.Fields, &RowField{Name: name, Type: fieldType})
+		if p.accept(closing) {
+			return nil
+		}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+	}
+}
+
+func tokenizeType(s string) ([]string, error) {
+	var (
+		tokens []string
+		runes  = []rune(s)
+	)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("<>(),", r):
+			tokens = append(tokens, string(r))
+			i++
+		case r == '`':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '`' {
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted field name")
+			}
+			i++
+			tokens = append(tokens, string(runes[start:i]))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected character '%c'", r)
+		}
+	}
+	return tokens, nil
+}
+
+func quoteFieldName(name string) string {
+	if identifierRegexp.MatchString(name) {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
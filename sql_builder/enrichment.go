@@ -0,0 +1,108 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CaseExpSQLBuilder
+
+type (
+	// CaseExpSQLBuilder renders a simple CASE expression mapping the values of an expression to results, the values
+	// without a result get the else result or NULL
+	CaseExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithWhen(value string, result string) CaseExpSQLBuilder
+		WithElse(result string) CaseExpSQLBuilder
+	}
+	caseExpSQLBuilderImpl struct {
+		expression string
+		whens      [][]string
+		elseResult string
+	}
+)
+
+func NewCaseExpSQLBuilder(expression string) CaseExpSQLBuilder {
+	return &caseExpSQLBuilderImpl{expression: expression}
+}
+
+func (v *caseExpSQLBuilderImpl) WithWhen(value string, result string) CaseExpSQLBuilder {
+	v.whens = append(v.whens, []string{value, result})
+	return v
+}
+
+func (v *caseExpSQLBuilderImpl) WithElse(result string) CaseExpSQLBuilder {
+	v.elseResult = result
+	return v
+}
+
+func (v *caseExpSQLBuilderImpl) Build() string {
+	if len(v.whens) == 0 {
+		if v.elseResult == "" {
+			return "NULL"
+		}
+		return v.elseResult
+	}
+	var sb strings.Builder
+	sb.WriteString("CASE " + v.expression)
+	for _, when := range v.whens {
+		sb.WriteString(fmt.Sprintf(" WHEN %v THEN %v", when[0], when[1]))
+	}
+	if v.elseResult != "" {
+		sb.WriteString(" ELSE " + v.elseResult)
+	}
+	sb.WriteString(" END")
+	return sb.String()
+}
+
+// LookupJoinExpSQLBuilder
+
+type (
+	// LookupJoinExpSQLBuilder joins the rows of a table to the versions of reference tables valid at their time
+	// attribute, a processing time for lookup tables such as jdbc or an event time for versioned tables, and adds
+	// fields computed from both. Rows without a matching version are kept with NULL reference columns
+	LookupJoinExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithQueryTable(name string, alias string) LookupJoinExpSQLBuilder
+		WithField(name string, expression string) LookupJoinExpSQLBuilder
+		WithLookupJoin(table string, alias string, timeField string, condition string) LookupJoinExpSQLBuilder
+	}
+	lookupJoinExpSQLBuilderImpl struct {
+		srcTableName string
+		srcAlias     string
+		fields       []string
+		joins        []string
+	}
+)
+
+func NewLookupJoinExpSQLBuilder() LookupJoinExpSQLBuilder {
+	return &lookupJoinExpSQLBuilderImpl{}
+}
+
+func (v *lookupJoinExpSQLBuilderImpl) WithQueryTable(name string, alias string) LookupJoinExpSQLBuilder {
+	v.srcTableName = name
+	v.srcAlias = alias
+	return v
+}
+
+func (v *lookupJoinExpSQLBuilderImpl) WithField(name string, expression string) LookupJoinExpSQLBuilder {
+	v.fields = append(v.fields, fmt.Sprintf("%v AS %v", expression, QuoteIdentifier(name)))
+	return v
+}
+
+// WithLookupJoin joins the reference table as of the time field of the query table, the condition references
+// the query table and the reference table by their aliases
+func (v *lookupJoinExpSQLBuilderImpl) WithLookupJoin(table string, alias string, timeField string, condition string) LookupJoinExpSQLBuilder {
+	v.joins = append(v.joins, fmt.Sprintf("LEFT JOIN %v FOR SYSTEM_TIME AS OF %v.%v AS %v ON %v",
+		table, v.srcAlias, QuoteIdentifier(timeField), alias, condition))
+	return v
+}
+
+func (v *lookupJoinExpSQLBuilderImpl) Build() string {
+	fields := append([]string{v.srcAlias + ".*"}, v.fields...)
+	stm := fmt.Sprintf("SELECT %v FROM %v AS %v", strings.Join(fields, ","), v.srcTableName, v.srcAlias)
+	for _, join := range v.joins {
+		stm += " " + join
+	}
+	return stm
+}
@@ -0,0 +1,347 @@
+package filter
+
+import (
+	"flink_ueba_manager/sql_builder/data_type"
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+	"strings"
+)
+
+type typeCategory int
+
+const (
+	categoryOther typeCategory = iota
+	categoryString
+	categoryNumeric
+	categoryBoolean
+	categoryTemporal
+)
+
+func (c typeCategory) String() string {
+	switch c {
+	case categoryString:
+		return "string"
+	case categoryNumeric:
+		return "numeric"
+	case categoryBoolean:
+		return "boolean"
+	case categoryTemporal:
+		return "temporal"
+	default:
+		return "complex"
+	}
+}
+
+// Compiler type-checks filter expressions against a table schema and compiles them to Flink SQL.
+//
+// The filter language supports:
+//
+//	comparisons      field = 'x', field != 3, field >= 1.5 (also ==, <>, <, <=, >)
+//	sets             field IN ('a', 'b'), field NOT IN (1, 2)
+//	watchlists       field IN WATCHLIST('admins'), field NOT IN WATCHLIST('bad_ips'), see WithWatchlists
+//	patterns         field LIKE 'adm%', field MATCHES '^ssh.*$' (REGEXP is an alias of MATCHES, the patterns
+//	                 are Java regular expressions, see checkRegex for the syntax accepted)
+//	networks         CIDR_MATCH(src_ip, '10.0.0.0/8')
+//	null checks      field IS NULL, field IS NOT NULL
+//	boolean fields   is_admin, NOT is_admin
+//	logic            AND, OR, NOT and parentheses
+//
+// Nested fields of ROW columns are referenced with dots, e.g. process.name.
+type Compiler struct {
+	schema         map[string]string
+	withWatchlists bool
+	watchlists     []*WatchlistLookup
+}
+
+// WatchlistLookup is a watchlist membership test of a filter. The compiled filter reads it from the boolean
+// column Column, which the caller computes by looking the field up in the watchlist
+type WatchlistLookup struct {
+	Watchlist string
+	// Field is the SQL reference of the field tested, such as `process`.`name`
+	Field  string
+	Column string
+}
+
+// WatchlistColumnPrefix prefixes the columns of the watchlist lookups of a filter
+const WatchlistColumnPrefix = "watchlist_match_"
+
+func NewCompiler(schema map[string]string) *Compiler {
+	return &Compiler{schema: schema}
+}
+
+// WithWatchlists enables the WATCHLIST membership tests, the filters of the other compilers reject them
+func (c *Compiler) WithWatchlists() *Compiler {
+	c.withWatchlists = true
+	return c
+}
+
+// Watchlists returns the watchlist lookups of the last compiled expression, a lookup per watchlist and field
+func (c *Compiler) Watchlists() []*WatchlistLookup {
+	return c.watchlists
+}
+
+// Compile parses and type-checks the expression and renders it as a Flink SQL boolean expression.
+// An empty expression matches every record
+func (c *Compiler) Compile(expression string) (string, error) {
+	c.watchlists = nil
+	if strings.TrimSpace(expression) == "" {
+		return "TRUE", nil
+	}
+	n, err := parse(expression)
+	if err != nil {
+		return "", errors.Errorf("invalid filter: %v", err)
+	}
+	sql, err := c.render(n)
+	if err != nil {
+		return "", errors.Errorf("invalid filter: %v", err)
+	}
+	return sql, nil
+}
+
+func (c *Compiler) render(n node) (string, error) {
+	switch v := n.(type) {
+	case *logicalNode:
+		left, err := c.render(v.left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.render(v.right)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%v %v %v)", left, v.op, right), nil
+	case *notNode:
+		operand, err := c.render(v.operand)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("NOT (%v)", operand), nil
+	case *constNode:
+		if v.value {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case *comparisonNode:
+		return c.renderComparison(v)
+	case *inNode:
+		return c.renderIn(v)
+	case *watchlistNode:
+		return c.renderWatchlist(v)
+	case *likeNode:
+		f, err := c.resolveString(v.field, "LIKE")
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v %vLIKE %v", f, not(v.negated), quote(v.pattern.value)), nil
+	case *regexNode:
+		f, err := c.resolveString(v.field, "MATCHES")
+		if err != nil {
+			return "", err
+		}
+		if err := checkRegex(v.pattern.value, v.pattern.pos); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%vREGEXP(%v, %v)", not(v.negated), f, quote(v.pattern.value)), nil
+	case *cidrNode:
+		return c.renderCIDR(v)
+	case *nullNode:
+		f, _, err := c.resolve(v.field)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v IS %vNULL", f, not(v.negated)), nil
+	case *boolFieldNode:
+		f, t, err := c.resolve(v.field)
+		if err != nil {
+			return "", err
+		}
+		if t.Kind != data_type.KindBoolean {
+			return "", fmt.Errorf("field '%v' at position %v is %v, a bare field must be boolean", v.field.name, v.field.pos, t)
+		}
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported expression at position %v", n.position())
+	}
+}
+
+func (c *Compiler) renderComparison(n *comparisonNode) (string, error) {
+	f, t, err := c.resolve(n.field)
+	if err != nil {
+		return "", err
+	}
+	category := categoryOf(t)
+	if category == categoryBoolean && n.op != "=" && n.op != "<>" {
+		return "", fmt.Errorf("operator %v at position %v is not supported on boolean field '%v'", n.op, n.field.pos, n.field.name)
+	}
+	value, err := c.renderLiteral(n.field, t, n.value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v %v %v", f, n.op, value), nil
+}
+
+func (c *Compiler) renderIn(n *inNode) (string, error) {
+	f, t, err := c.resolve(n.field)
+	if err != nil {
+		return "", err
+	}
+	values := make([]string, 0, len(n.values))
+	for _, v := range n.values {
+		value, err := c.renderLiteral(n.field, t, v)
+		if err != nil {
+			return "", err
+		}
+		values = append(values, value)
+	}
+	return fmt.Sprintf("%v %vIN (%v)", f, not(n.negated), strings.Join(values, ", ")), nil
+}
+
+// renderWatchlist compiles a watchlist membership test into the column of its lookup
+func (c *Compiler) renderWatchlist(n *watchlistNode) (string, error) {
+	if !c.withWatchlists {
+		return "", fmt.Errorf("WATCHLIST at position %v is not supported in this filter", n.field.pos)
+	}
+	f, t, err := c.resolve(n.field)
+	if err != nil {
+		return "", err
+	}
+	if category := categoryOf(t); category != categoryString && category != categoryNumeric {
+		return "", fmt.Errorf("WATCHLIST at position %v requires a string or numeric field but '%v' is %v", n.field.pos, n.field.name, t)
+	}
+	if n.watchlist.value == "" {
+		return "", fmt.Errorf("empty watchlist name at position %v", n.watchlist.pos)
+	}
+	var lookup *WatchlistLookup
+	for _, l := range c.watchlists {
+		if l.Watchlist == n.watchlist.value && l.Field == f {
+			lookup = l
+		}
+	}
+	if lookup == nil {
+		lookup = &WatchlistLookup{Watchlist: n.watchlist.value, Field: f, Column: fmt.Sprintf("%v%v", WatchlistColumnPrefix, len(c.watchlists))}
+		c.watchlists = append(c.watchlists, lookup)
+	}
+	if n.negated {
+		return fmt.Sprintf("NOT `%v`", lookup.Column), nil
+	}
+	return fmt.Sprintf("`%v`", lookup.Column), nil
+}
+
+// renderCIDR compiles an IPv4 CIDR match into a range check over the numeric value of the address
+func (c *Compiler) renderCIDR(n *cidrNode) (string, error) {
+	f, err := c.resolveString(n.field, "CIDR_MATCH")
+	if err != nil {
+		return "", err
+	}
+	_, network, err := net.ParseCIDR(n.cidr.value)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR '%v' at position %v", n.cidr.value, n.cidr.pos)
+	}
+	ip := network.IP.To4()
+	if ip == nil {
+		return "", fmt.Errorf("only IPv4 CIDR is supported, got '%v' at position %v", n.cidr.value, n.cidr.pos)
+	}
+	ones, bits := network.Mask.Size()
+	low := int64(ip[0])<<24 | int64(ip[1])<<16 | int64(ip[2])<<8 | int64(ip[3])
+	high := low + (int64(1) << uint(bits-ones)) - 1
+	octets := make([]string, 0, 4)
+	for i, weight := range []int64{16777216, 65536, 256, 1} {
+		octets = append(octets, fmt.Sprintf("TRY_CAST(SPLIT_INDEX(%v, '.', %v) AS BIGINT) * %v", f, i, weight))
+	}
+	return fmt.Sprintf("((%v) BETWEEN %v AND %v)", strings.Join(octets, " + "), low, high), nil
+}
+
+func (c *Compiler) renderLiteral(f *field, t *data_type.DataType, l *literal) (string, error) {
+	category := categoryOf(t)
+	mismatch := func() error {
+		return fmt.Errorf("cannot compare %v field '%v' with value '%v' at position %v", category, f.name, l.value, l.pos)
+	}
+	switch category {
+	case categoryString:
+		if l.kind != literalString {
+			return "", mismatch()
+		}
+		return quote(l.value), nil
+	case categoryNumeric:
+		if l.kind != literalNumber {
+			return "", mismatch()
+		}
+		return l.value, nil
+	case categoryBoolean:
+		if l.kind != literalBool {
+			return "", mismatch()
+		}
+		return l.value, nil
+	case categoryTemporal:
+		if l.kind != literalString {
+			return "", mismatch()
+		}
+		return fmt.Sprintf("CAST(%v AS %v)", quote(l.value), t.AsNullable()), nil
+	default:
+		return "", fmt.Errorf("field '%v' at position %v has type %v which cannot be compared", f.name, f.pos, t)
+	}
+}
+
+func (c *Compiler) resolveString(f *field, op string) (string, error) {
+	sql, t, err := c.resolve(f)
+	if err != nil {
+		return "", err
+	}
+	if !t.IsCharacterString() {
+		return "", fmt.Errorf("%v at position %v requires a string field but '%v' is %v", op, f.pos, f.name, t)
+	}
+	return sql, nil
+}
+
+// resolve looks the field up in the schema, descending into ROW fields for dotted names,
+// and returns its SQL reference and type
+func (c *Compiler) resolve(f *field) (string, *data_type.DataType, error) {
+	segments := splitIdentifier(f.name)
+	typeStr, ok := c.schema[segments[0]]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown field '%v' at position %v", f.name, f.pos)
+	}
+	t, err := data_type.Parse(typeStr)
+	if err != nil {
+		return "", nil, fmt.Errorf("field '%v' at position %v: %v", f.name, f.pos, err)
+	}
+	for i, seg := range segments[1:] {
+		nested, ok := t.Field(seg)
+		if !ok {
+			return "", nil, fmt.Errorf("field '%v' at position %v: %v has no field '%v'", f.name, f.pos, strings.Join(segments[:i+1], "."), seg)
+		}
+		t = nested
+	}
+	quoted := make([]string, 0, len(segments))
+	for _, s := range segments {
+		quoted = append(quoted, "`"+s+"`")
+	}
+	return strings.Join(quoted, "."), t, nil
+}
+
+func categoryOf(t *data_type.DataType) typeCategory {
+	switch {
+	case t.IsCharacterString():
+		return categoryString
+	case t.IsNumeric():
+		return categoryNumeric
+	case t.Kind == data_type.KindBoolean:
+		return categoryBoolean
+	case t.IsTemporal():
+		return categoryTemporal
+	default:
+		return categoryOther
+	}
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func not(negated bool) string {
+	if negated {
+		return "NOT "
+	}
+	return ""
+}
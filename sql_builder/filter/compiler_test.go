@@ -0,0 +1,165 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+)
+
+var testSchema = map[string]string{
+	"user":     "STRING",
+	"cmd":      "VARCHAR(256)",
+	"src_ip":   "STRING",
+	"port":     "INT",
+	"bytes":    "BIGINT",
+	"score":    "DOUBLE",
+	"is_admin": "BOOLEAN",
+	"ts":       "TIMESTAMP(3)",
+	"tags":     "ARRAY<STRING>",
+	"process":  "ROW<name STRING, pid INT>",
+}
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"empty", "", "TRUE"},
+		{"blank", "  \t ", "TRUE"},
+		{"string", "user = 'root'", "`user` = 'root'"},
+		{"escaped string", "user = 'o''brien'", "`user` = 'o''brien'"},
+		{"numeric", "port >= 1024", "`port` >= 1024"},
+		{"boolean", "is_admin = TRUE", "`is_admin` = TRUE"},
+		{"bare boolean", "is_admin", "`is_admin`"},
+		{"temporal", "ts > '2024-01-01 00:00:00'", "`ts` > CAST('2024-01-01 00:00:00' AS TIMESTAMP(3))"},
+		{"nested", "process.name = 'bash'", "`process`.`name` = 'bash'"},
+		{"and binds tighter than or", "port = 22 OR port = 23 AND is_admin", "(`port` = 22 OR (`port` = 23 AND `is_admin`))"},
+		{"parentheses", "(port = 22 OR port = 23) AND is_admin", "((`port` = 22 OR `port` = 23) AND `is_admin`)"},
+		{"not", "NOT is_admin AND port = 22", "(NOT (`is_admin`) AND `port` = 22)"},
+		{"in", "user IN ('root', 'admin')", "`user` IN ('root', 'admin')"},
+		{"not in", "port NOT IN (22, 3389)", "`port` NOT IN (22, 3389)"},
+		{"like", "cmd NOT LIKE 'ssh%'", "`cmd` NOT LIKE 'ssh%'"},
+		{"matches", "cmd MATCHES '^ssh\\s+-i .*$'", "REGEXP(`cmd`, '^ssh\\s+-i .*$')"},
+		{"not regexp", "cmd NOT REGEXP '(?i)powershell'", "NOT REGEXP(`cmd`, '(?i)powershell')"},
+		{"matches quoted", "cmd MATCHES '\\Q[x]\\E'", "REGEXP(`cmd`, '\\Q[x]\\E')"},
+		{"matches class", "cmd MATCHES '[^a-z0-9_]+'", "REGEXP(`cmd`, '[^a-z0-9_]+')"},
+		{"cidr /8", "CIDR_MATCH(src_ip, '10.0.0.0/8')", "((TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 0) AS BIGINT) * 16777216 + TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 1) AS BIGINT) * 65536 + TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 2) AS BIGINT) * 256 + TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 3) AS BIGINT) * 1) BETWEEN 167772160 AND 184549375)"},
+		{"cidr host", "CIDR_MATCH(src_ip, '192.168.1.7/32')", "((TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 0) AS BIGINT) * 16777216 + TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 1) AS BIGINT) * 65536 + TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 2) AS BIGINT) * 256 + TRY_CAST(SPLIT_INDEX(`src_ip`, '.', 3) AS BIGINT) * 1) BETWEEN 3232235783 AND 3232235783)"},
+		{"null", "user IS NOT NULL", "`user` IS NOT NULL"},
+		{"null on complex", "tags IS NULL", "`tags` IS NULL"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := NewCompiler(testSchema).Compile(c.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("got  %v\nwant %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"unknown field", "host = 'x'", "unknown field 'host' at position 0"},
+		{"unknown nested field", "process.user = 'x'", "process has no field 'user'"},
+		{"string against number", "port = 'ssh'", "cannot compare numeric field 'port' with value 'ssh'"},
+		{"number against string", "user = 1", "cannot compare string field 'user' with value '1'"},
+		{"boolean against number", "is_admin = 1", "cannot compare boolean field 'is_admin'"},
+		{"ordering a boolean", "is_admin > TRUE", "operator > at position 0 is not supported on boolean field 'is_admin'"},
+		{"complex comparison", "tags = 'x'", "has type ARRAY<STRING> which cannot be compared"},
+		{"in mixed types", "port IN (22, 'ssh')", "cannot compare numeric field 'port' with value 'ssh'"},
+		{"bare non boolean", "user", "a bare field must be boolean"},
+		{"like on number", "port LIKE '2%'", "LIKE at position 0 requires a string field"},
+		{"matches on number", "port MATCHES '2.*'", "MATCHES at position 0 requires a string field"},
+		{"cidr on number", "CIDR_MATCH(port, '10.0.0.0/8')", "CIDR_MATCH at position 11 requires a string field"},
+		{"invalid cidr", "CIDR_MATCH(src_ip, '10.0.0.0/33')", "invalid CIDR '10.0.0.0/33'"},
+		{"ipv6 cidr", "CIDR_MATCH(src_ip, 'fe80::/10')", "only IPv4 CIDR is supported"},
+		{"watchlist without lookups", "user IN WATCHLIST('admins')", "WATCHLIST at position 0 is not supported in this filter"},
+		{"syntax", "user = ", "invalid filter:"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewCompiler(testSchema).Compile(c.expression)
+			if err == nil {
+				t.Fatalf("expected an error containing %q", c.want)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Errorf("got %q, want an error containing %q", err.Error(), c.want)
+			}
+		})
+	}
+}
+
+func TestCompileRegex(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    string
+	}{
+		{"(", "invalid regular expression at position 12"},
+		{"(?=x)", "invalid regular expression"},
+		{"(?<!x)y", "invalid regular expression"},
+		{`(a)\1`, "invalid regular expression"},
+		{"a*+", "invalid regular expression"},
+		{`a\Z`, "invalid regular expression"},
+		{"(?P<name>x)", "uses a (?P<name>...) group"},
+		{"(?U)a+", "uses the U flag"},
+		{"(?iU:a+)", "uses the U flag"},
+		{"[[:alpha:]]", "uses a nested character class"},
+		{"[a[bc]]", "uses a nested character class"},
+		{"[a-z&&[^aeiou]]", "uses a character class intersection &&"},
+		{"[a-z&&b]", "uses a character class intersection &&"},
+		{"[]a]", "uses a leading ] in a character class"},
+		{"[^]a]", "uses a leading ] in a character class"},
+		{`\C`, "invalid regular expression"},
+		{`a\vb`, `uses \v`},
+		{`\p{Greek}`, `uses \p{...}`},
+		{`\P{Latin}`, `uses \P{...}`},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern, func(t *testing.T) {
+			_, err := NewCompiler(testSchema).Compile("cmd MATCHES " + quote(c.pattern))
+			if err == nil {
+				t.Fatalf("expected an error containing %q", c.want)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Errorf("got %q, want an error containing %q", err.Error(), c.want)
+			}
+		})
+	}
+
+	for _, pattern := range []string{`\pL+`, `[\]a]`, `[a\[b]`, `\Q[[:x:]]\E`, `(?i)x`, `(?s:.+)`, `\d{1,3}(\.\d{1,3}){3}`, `^a|b$`, `[&]`} {
+		t.Run(pattern, func(t *testing.T) {
+			if _, err := NewCompiler(testSchema).Compile("cmd MATCHES " + quote(pattern)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestCompileWatchlists(t *testing.T) {
+	c := NewCompiler(testSchema).WithWatchlists()
+	got, err := c.Compile("user IN WATCHLIST('admins') AND src_ip NOT IN WATCHLIST('bad_ips') OR user IN WATCHLIST('admins')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "((`watchlist_match_0` AND NOT `watchlist_match_1`) OR `watchlist_match_0`)"; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	lookups := c.Watchlists()
+	if len(lookups) != 2 {
+		t.Fatalf("expected 2 lookups, got %v", len(lookups))
+	}
+	if l := lookups[1]; l.Watchlist != "bad_ips" || l.Field != "`src_ip`" || l.Column != "watchlist_match_1" {
+		t.Errorf("unexpected lookup %+v", *l)
+	}
+	if _, err := c.Compile(""); err != nil || len(c.Watchlists()) != 0 {
+		t.Errorf("the lookups of the previous expression are kept: %v", c.Watchlists())
+	}
+}
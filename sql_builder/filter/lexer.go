@@ -0,0 +1,179 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("'%v'", t.value)
+}
+
+// isKeyword reports whether the token is the given keyword, case-insensitively
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdent && !strings.HasPrefix(t.value, "`") && strings.EqualFold(t.value, keyword)
+}
+
+func tokenize(input string) ([]token, error) {
+	var (
+		tokens []token
+		runes  = []rune(input)
+		i      = 0
+	)
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", pos: i})
+			i++
+		case r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					// '' is an escaped quote inside a string literal
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string literal at position %v", start)
+			}
+			tokens = append(tokens, token{kind: tokenString, value: sb.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && expectsOperand(tokens)):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_' || r == '`':
+			start := i
+			end, err := scanIdentifier(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			tokens = append(tokens, token{kind: tokenIdent, value: string(runes[start:i]), pos: start})
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			op := string(r)
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if two == "==" || two == "!=" || two == "<>" || two == "<=" || two == ">=" {
+					op = two
+				}
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected character '!' at position %v", start)
+			}
+			i += len([]rune(op))
+			tokens = append(tokens, token{kind: tokenOperator, value: op, pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %v", r, i)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// expectsOperand reports whether a '-' at the current position starts a negative number rather than an operator
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokenOperator || last.kind == tokenLParen || last.kind == tokenComma
+}
+
+// scanIdentifier consumes a possibly dotted identifier whose segments may be quoted with backticks,
+// e.g. process.name or `user`.`name`, and returns the position right after it
+func scanIdentifier(runes []rune, i int) (int, error) {
+	for {
+		if i < len(runes) && runes[i] == '`' {
+			start := i
+			i++
+			for i < len(runes) && runes[i] != '`' {
+				i++
+			}
+			if i >= len(runes) || i == start+1 {
+				return 0, fmt.Errorf("invalid quoted identifier at position %v", start)
+			}
+			i++
+		} else {
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			if i == start {
+				return 0, fmt.Errorf("invalid identifier at position %v", start)
+			}
+		}
+		if i >= len(runes) || runes[i] != '.' {
+			return i, nil
+		}
+		i++
+	}
+}
+
+// splitIdentifier splits a dotted identifier into its unquoted segments
+func splitIdentifier(ident string) []string {
+	var (
+		segments []string
+		current  strings.Builder
+		quoted   = false
+	)
+	for _, r := range ident {
+		switch {
+		case r == '`':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(segments, current.String())
+}
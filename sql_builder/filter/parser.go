@@ -0,0 +1,383 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	node interface {
+		position() int
+	}
+	literalKind int
+	literal     struct {
+		kind  literalKind
+		value string
+		pos   int
+	}
+	field struct {
+		name string
+		pos  int
+	}
+	logicalNode struct {
+		op          string
+		left, right node
+		pos         int
+	}
+	notNode struct {
+		operand node
+		pos     int
+	}
+	comparisonNode struct {
+		field *field
+		op    string
+		value *literal
+	}
+	inNode struct {
+		field   *field
+		values  []*literal
+		negated bool
+	}
+	watchlistNode struct {
+		field     *field
+		watchlist *literal
+		negated   bool
+	}
+	likeNode struct {
+		field   *field
+		pattern *literal
+		negated bool
+	}
+	regexNode struct {
+		field   *field
+		pattern *literal
+		negated bool
+	}
+	cidrNode struct {
+		field *field
+		cidr  *literal
+		pos   int
+	}
+	nullNode struct {
+		field   *field
+		negated bool
+	}
+	boolFieldNode struct {
+		field *field
+	}
+	constNode struct {
+		value bool
+		pos   int
+	}
+)
+
+const (
+	literalString literalKind = iota
+	literalNumber
+	literalBool
+)
+
+func (n *literal) position() int        { return n.pos }
+func (n *field) position() int          { return n.pos }
+func (n *logicalNode) position() int    { return n.pos }
+func (n *notNode) position() int        { return n.pos }
+func (n *comparisonNode) position() int { return n.field.pos }
+func (n *inNode) position() int         { return n.field.pos }
+func (n *watchlistNode) position() int  { return n.field.pos }
+func (n *likeNode) position() int       { return n.field.pos }
+func (n *regexNode) position() int      { return n.field.pos }
+func (n *cidrNode) position() int       { return n.pos }
+func (n *nullNode) position() int       { return n.field.pos }
+func (n *boolFieldNode) position() int  { return n.field.pos }
+func (n *constNode) position() int      { return n.pos }
+
+var comparisonOperators = map[string]string{
+	"=":  "=",
+	"==": "=",
+	"!=": "<>",
+	"<>": "<>",
+	"<":  "<",
+	"<=": "<=",
+	">":  ">",
+	">=": ">=",
+}
+
+var reservedWords = []string{"AND", "OR", "NOT", "IN", "LIKE", "MATCHES", "REGEXP", "IS", "NULL", "TRUE", "FALSE", "CIDR_MATCH", "WATCHLIST"}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses a filter expression into its syntax tree
+func parse(expression string) (node, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %v at position %v", tok, tok.pos)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.peek().isKeyword(keyword) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	tok := p.next()
+	if tok.kind != kind {
+		return tok, fmt.Errorf("expected %v but got %v at position %v", what, tok, tok.pos)
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.acceptKeyword("OR") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "OR", left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.acceptKeyword("AND") {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "AND", left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	tok := p.peek()
+	if p.acceptKeyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand, pos: tok.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenLParen:
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tok.isKeyword("TRUE"), tok.isKeyword("FALSE"):
+		p.next()
+		return &constNode{value: strings.EqualFold(tok.value, "TRUE"), pos: tok.pos}, nil
+	case tok.isKeyword("CIDR_MATCH"):
+		return p.parseCIDRMatch()
+	case tok.kind == tokenIdent:
+		return p.parsePredicate()
+	default:
+		return nil, fmt.Errorf("unexpected %v at position %v", tok, tok.pos)
+	}
+}
+
+// parseCIDRMatch parses CIDR_MATCH(field, 'a.b.c.d/n')
+func (p *parser) parseCIDRMatch() (node, error) {
+	tok := p.next()
+	if _, err := p.expect(tokenLParen, "'('"); err != nil {
+		return nil, err
+	}
+	f, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenComma, "','"); err != nil {
+		return nil, err
+	}
+	cidr, err := p.expect(tokenString, "a CIDR string")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenRParen, "')'"); err != nil {
+		return nil, err
+	}
+	return &cidrNode{field: f, cidr: &literal{kind: literalString, value: cidr.value, pos: cidr.pos}, pos: tok.pos}, nil
+}
+
+func (p *parser) parseField() (*field, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return nil, fmt.Errorf("expected a field name but got %v at position %v", tok, tok.pos)
+	}
+	for _, w := range reservedWords {
+		if tok.isKeyword(w) {
+			return nil, fmt.Errorf("expected a field name but got keyword %v at position %v", tok, tok.pos)
+		}
+	}
+	return &field{name: tok.value, pos: tok.pos}, nil
+}
+
+func (p *parser) parsePredicate() (node, error) {
+	f, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok.kind == tokenOperator {
+		p.next()
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &comparisonNode{field: f, op: comparisonOperators[tok.value], value: value}, nil
+	}
+	if p.acceptKeyword("IS") {
+		negated := p.acceptKeyword("NOT")
+		if !p.acceptKeyword("NULL") {
+			tok := p.peek()
+			return nil, fmt.Errorf("expected NULL but got %v at position %v", tok, tok.pos)
+		}
+		return &nullNode{field: f, negated: negated}, nil
+	}
+	negated := p.acceptKeyword("NOT")
+	switch {
+	case p.acceptKeyword("IN"):
+		if p.acceptKeyword("WATCHLIST") {
+			watchlist, err := p.parseWatchlist()
+			if err != nil {
+				return nil, err
+			}
+			return &watchlistNode{field: f, watchlist: watchlist, negated: negated}, nil
+		}
+		values, err := p.parseLiteralList()
+		if err != nil {
+			return nil, err
+		}
+		return &inNode{field: f, values: values, negated: negated}, nil
+	case p.acceptKeyword("LIKE"):
+		pattern, err := p.parseStringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &likeNode{field: f, pattern: pattern, negated: negated}, nil
+	case p.acceptKeyword("MATCHES"), p.acceptKeyword("REGEXP"):
+		pattern, err := p.parseStringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &regexNode{field: f, pattern: pattern, negated: negated}, nil
+	}
+	if negated {
+		tok := p.peek()
+		return nil, fmt.Errorf("expected IN, LIKE or MATCHES after NOT but got %v at position %v", tok, tok.pos)
+	}
+	// a bare field is a boolean predicate
+	return &boolFieldNode{field: f}, nil
+}
+
+// parseWatchlist parses the ('name') following WATCHLIST
+func (p *parser) parseWatchlist() (*literal, error) {
+	if _, err := p.expect(tokenLParen, "'('"); err != nil {
+		return nil, err
+	}
+	name, err := p.parseStringLiteral()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenRParen, "')'"); err != nil {
+		return nil, err
+	}
+	return name, nil
+}
+
+func (p *parser) parseLiteralList() ([]*literal, error) {
+	if _, err := p.expect(tokenLParen, "'('"); err != nil {
+		return nil, err
+	}
+	var values []*literal
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		tok := p.next()
+		if tok.kind == tokenRParen {
+			return values, nil
+		}
+		if tok.kind != tokenComma {
+			return nil, fmt.Errorf("expected ',' or ')' but got %v at position %v", tok, tok.pos)
+		}
+	}
+}
+
+func (p *parser) parseStringLiteral() (*literal, error) {
+	tok, err := p.expect(tokenString, "a string literal")
+	if err != nil {
+		return nil, err
+	}
+	return &literal{kind: literalString, value: tok.value, pos: tok.pos}, nil
+}
+
+func (p *parser) parseLiteral() (*literal, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenString:
+		return &literal{kind: literalString, value: tok.value, pos: tok.pos}, nil
+	case tok.kind == tokenNumber:
+		if strings.Count(tok.value, ".") > 1 || strings.HasSuffix(tok.value, ".") {
+			return nil, fmt.Errorf("invalid number '%v' at position %v", tok.value, tok.pos)
+		}
+		return &literal{kind: literalNumber, value: tok.value, pos: tok.pos}, nil
+	case tok.isKeyword("TRUE"), tok.isKeyword("FALSE"):
+		return &literal{kind: literalBool, value: strings.ToUpper(tok.value), pos: tok.pos}, nil
+	default:
+		return nil, fmt.Errorf("expected a literal value but got %v at position %v", tok, tok.pos)
+	}
+}
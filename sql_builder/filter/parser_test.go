@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dump renders a parsed expression as an s-expression, so the tests can check how it was grouped
+func dump(n node) string {
+	switch v := n.(type) {
+	case *logicalNode:
+		return fmt.Sprintf("(%v %v %v)", v.op, dump(v.left), dump(v.right))
+	case *notNode:
+		return fmt.Sprintf("(NOT %v)", dump(v.operand))
+	case *constNode:
+		return fmt.Sprintf("%v", v.value)
+	case *comparisonNode:
+		return fmt.Sprintf("(%v %v %v)", v.op, v.field.name, v.value.value)
+	case *inNode:
+		values := make([]string, 0, len(v.values))
+		for _, l := range v.values {
+			values = append(values, l.value)
+		}
+		return fmt.Sprintf("(%vIN %v [%v])", not(v.negated), v.field.name, strings.Join(values, " "))
+	case *watchlistNode:
+		return fmt.Sprintf("(%vWATCHLIST %v %v)", not(v.negated), v.field.name, v.watchlist.value)
+	case *likeNode:
+		return fmt.Sprintf("(%vLIKE %v %v)", not(v.negated), v.field.name, v.pattern.value)
+	case *regexNode:
+		return fmt.Sprintf("(%vMATCHES %v %v)", not(v.negated), v.field.name, v.pattern.value)
+	case *cidrNode:
+		return fmt.Sprintf("(CIDR %v %v)", v.field.name, v.cidr.value)
+	case *nullNode:
+		return fmt.Sprintf("(IS %vNULL %v)", not(v.negated), v.field.name)
+	case *boolFieldNode:
+		return v.field.name
+	default:
+		return fmt.Sprintf("%T", n)
+	}
+}
+
+func TestParsePrecedence(t *testing.T) {
+	cases := []struct {
+		expression string
+		want       string
+	}{
+		{"a = 1 OR b = 2 AND c = 3", "(OR (= a 1) (AND (= b 2) (= c 3)))"},
+		{"a = 1 AND b = 2 OR c = 3", "(OR (AND (= a 1) (= b 2)) (= c 3))"},
+		{"(a = 1 OR b = 2) AND c = 3", "(AND (OR (= a 1) (= b 2)) (= c 3))"},
+		{"NOT a = 1 AND b = 2", "(AND (NOT (= a 1)) (= b 2))"},
+		{"NOT (a = 1 AND b = 2)", "(NOT (AND (= a 1) (= b 2)))"},
+		{"NOT NOT flag", "(NOT (NOT flag))"},
+		{"a = 1 OR b = 2 OR c = 3", "(OR (OR (= a 1) (= b 2)) (= c 3))"},
+		{"a and b or not c", "(OR (AND a b) (NOT c))"},
+		{"a == 'x' AND b != 2 AND c <> 3", "(AND (AND (= a x) (<> b 2)) (<> c 3))"},
+		{"TRUE OR FALSE", "(OR true false)"},
+	}
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			n, err := parse(c.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dump(n); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParsePredicates(t *testing.T) {
+	cases := []struct {
+		expression string
+		want       string
+	}{
+		{"user IN ('a', 'b')", "(IN user [a b])"},
+		{"port NOT IN (22, 3389)", "(NOT IN port [22 3389])"},
+		{"user IN WATCHLIST('admins')", "(WATCHLIST user admins)"},
+		{"src_ip NOT IN WATCHLIST('bad_ips')", "(NOT WATCHLIST src_ip bad_ips)"},
+		{"cmd LIKE 'ssh%'", "(LIKE cmd ssh%)"},
+		{"cmd NOT LIKE 'ssh%'", "(NOT LIKE cmd ssh%)"},
+		{"cmd MATCHES '^ssh.*$'", "(MATCHES cmd ^ssh.*$)"},
+		{"cmd NOT REGEXP 'x+'", "(NOT MATCHES cmd x+)"},
+		{"CIDR_MATCH(src_ip, '10.0.0.0/8')", "(CIDR src_ip 10.0.0.0/8)"},
+		{"user IS NULL", "(IS NULL user)"},
+		{"user IS NOT NULL", "(IS NOT NULL user)"},
+		{"process.name = 'bash'", "(= process.name bash)"},
+	}
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			n, err := parse(c.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dump(n); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		expression string
+		want       string
+	}{
+		{"a = 1 AND", "unexpected end of expression at position 9"},
+		{"(a = 1", "expected ')'"},
+		{"a = 1)", "unexpected"},
+		{"a NOT = 1", "expected IN, LIKE or MATCHES after NOT"},
+		{"a IS 1", "expected NULL"},
+		{"a IN ('x' 'y')", "expected ',' or ')'"},
+		{"a IN ()", "expected a literal value"},
+		{"a LIKE 1", "expected a string literal"},
+		{"a = 1.2.3", "invalid number '1.2.3'"},
+		{"AND = 1", "got keyword 'AND' at position 0"},
+		{"CIDR_MATCH(ip '10.0.0.0/8')", "expected ','"},
+		{"a IN WATCHLIST 'x'", "expected '('"},
+	}
+	for _, c := range cases {
+		t.Run(c.expression, func(t *testing.T) {
+			_, err := parse(c.expression)
+			if err == nil {
+				t.Fatalf("expected an error containing %q", c.want)
+			}
+			if !strings.Contains(err.Error(), c.want) {
+				t.Errorf("got %q, want an error containing %q", err.Error(), c.want)
+			}
+		})
+	}
+}
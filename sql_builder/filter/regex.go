@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+)
+
+/*
+MATCHES patterns are run by Flink's REGEXP, which uses Java regular expressions, while the compiler checks them
+with Go's RE2 parser. A pattern is accepted when both read it the same way:
+
+	RE2 rejects     lookarounds, backreferences, possessive quantifiers, \Z and the other Java only syntax
+	checkRegex      rejects the syntax RE2 accepts but Java reads differently or not at all:
+	                (?P<name>...), the U flag, POSIX classes such as [[:alpha:]], nested classes, class
+	                intersections (&&), a leading ] in a class, \v and \p{Name} / \P{Name}
+
+The remaining differences are about character sets: Java's \s also matches \x0B and, without the m flag, Java's $
+also matches before a final line terminator.
+*/
+
+// checkRegex checks a MATCHES pattern, pos is the position of the pattern in the filter
+func checkRegex(pattern string, pos int) error {
+	if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+		return fmt.Errorf("invalid regular expression at position %v: %v", pos, err)
+	}
+	if construct := nonPortableRegexConstruct(pattern); construct != "" {
+		return fmt.Errorf("regular expression at position %v uses %v, which Flink's Java regular expressions do not read the same way", pos, construct)
+	}
+	return nil
+}
+
+// nonPortableRegexConstruct returns the first construct of an RE2 pattern Java reads differently, empty when none
+func nonPortableRegexConstruct(pattern string) string {
+	inClass := false
+	classStart := false
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		if ch == '\\' {
+			if i+1 >= len(pattern) {
+				return ""
+			}
+			switch next := pattern[i+1]; next {
+			case 'Q':
+				end := strings.Index(pattern[i+2:], `\E`)
+				if end < 0 {
+					return ""
+				}
+				i += 2 + end + 1
+				classStart = false
+				continue
+			case 'v':
+				return `\` + string(next)
+			case 'p', 'P':
+				if i+2 < len(pattern) && pattern[i+2] == '{' {
+					return `\` + string(next) + "{...}"
+				}
+			}
+			i++
+			classStart = false
+			continue
+		}
+		if inClass {
+			switch {
+			case ch == ']' && classStart:
+				return "a leading ] in a character class"
+			case ch == ']':
+				inClass = false
+			case ch == '[':
+				return "a nested character class"
+			case ch == '&' && i+1 < len(pattern) && pattern[i+1] == '&':
+				return "a character class intersection &&"
+			}
+			classStart = false
+			continue
+		}
+		switch {
+		case ch == '[':
+			inClass = true
+			classStart = true
+			if i+1 < len(pattern) && pattern[i+1] == '^' {
+				i++
+			}
+		case strings.HasPrefix(pattern[i:], "(?P<"):
+			return "a (?P<name>...) group"
+		case strings.HasPrefix(pattern[i:], "(?"):
+			end := strings.IndexAny(pattern[i+2:], ":)")
+			if end >= 0 && strings.Contains(pattern[i+2:i+2+end], "U") {
+				return "the U flag"
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,233 @@
+package sql_builder
+
+import (
+	"strconv"
+)
+
+const (
+	FormatJSON          = "json"
+	FormatAvro          = "avro"
+	FormatAvroConfluent = "avro-confluent"
+	FormatCSV           = "csv"
+	FormatRaw           = "raw"
+	FormatParquet       = "parquet"
+
+	JSONTimestampFormatISO8601 = "ISO-8601"
+	JSONTimestampFormatSQL     = "SQL"
+
+	RawEndiannessBigEndian    = "big-endian"
+	RawEndiannessLittleEndian = "little-endian"
+)
+
+// FormatBuilder
+
+type (
+	// FormatBuilder collects the options of a (de)serialization format. Option keys are relative to the format,
+	// connectors prefix them with the format name, e.g. ignore-parse-errors becomes json.ignore-parse-errors
+	FormatBuilder interface {
+		Name() string
+		Options() []FormatOption
+	}
+	FormatOption struct {
+		Key   string
+		Value string
+	}
+	formatBuilderImpl struct {
+		name    string
+		options []FormatOption
+	}
+)
+
+func newFormatBuilderImpl(name string) *formatBuilderImpl {
+	return &formatBuilderImpl{name: name}
+}
+
+func (f *formatBuilderImpl) Name() string {
+	return f.name
+}
+
+func (f *formatBuilderImpl) Options() []FormatOption {
+	return f.options
+}
+
+func (f *formatBuilderImpl) withOption(key, value string) {
+	f.options = append(f.options, FormatOption{Key: key, Value: value})
+}
+
+// JSONFormatBuilder
+
+type (
+	JSONFormatBuilder interface {
+		FormatBuilder
+		WithTimestampFormat(standard string) JSONFormatBuilder
+		WithIgnoreParseErrors(ignore bool) JSONFormatBuilder
+		WithFailOnMissingField(fail bool) JSONFormatBuilder
+	}
+	jsonFormatBuilderImpl struct {
+		*formatBuilderImpl
+	}
+)
+
+func NewJSONFormatBuilder() JSONFormatBuilder {
+	return &jsonFormatBuilderImpl{formatBuilderImpl: newFormatBuilderImpl(FormatJSON)}
+}
+
+func (f *jsonFormatBuilderImpl) WithTimestampFormat(standard string) JSONFormatBuilder {
+	f.withOption("timestamp-format.standard", standard)
+	return f
+}
+
+func (f *jsonFormatBuilderImpl) WithIgnoreParseErrors(ignore bool) JSONFormatBuilder {
+	f.withOption("ignore-parse-errors", strconv.FormatBool(ignore))
+	return f
+}
+
+func (f *jsonFormatBuilderImpl) WithFailOnMissingField(fail bool) JSONFormatBuilder {
+	f.withOption("fail-on-missing-field", strconv.FormatBool(fail))
+	return f
+}
+
+// NewAvroFormatBuilder returns the plain avro format, whose schema is derived from the table schema
+func NewAvroFormatBuilder() FormatBuilder {
+	return newFormatBuilderImpl(FormatAvro)
+}
+
+// AvroConfluentFormatBuilder
+
+type (
+	AvroConfluentFormatBuilder interface {
+		FormatBuilder
+		WithSubject(subject string) AvroConfluentFormatBuilder
+		WithBasicAuthUserInfo(userInfo string) AvroConfluentFormatBuilder
+	}
+	avroConfluentFormatBuilderImpl struct {
+		*formatBuilderImpl
+	}
+)
+
+func NewAvroConfluentFormatBuilder(registryURL string) AvroConfluentFormatBuilder {
+	f := &avroConfluentFormatBuilderImpl{formatBuilderImpl: newFormatBuilderImpl(FormatAvroConfluent)}
+	f.withOption("url", registryURL)
+	return f
+}
+
+// WithSubject sets the subject the schema is registered under, <topic>-value by default
+func (f *avroConfluentFormatBuilderImpl) WithSubject(subject string) AvroConfluentFormatBuilder {
+	f.withOption("subject", subject)
+	return f
+}
+
+// WithBasicAuthUserInfo authenticates to the registry with user:password
+func (f *avroConfluentFormatBuilderImpl) WithBasicAuthUserInfo(userInfo string) AvroConfluentFormatBuilder {
+	f.withOption("basic-auth.credentials-source", "USER_INFO")
+	f.withOption("basic-auth.user-info", userInfo)
+	return f
+}
+
+// CSVFormatBuilder
+
+type (
+	// CSVFormatBuilder maps the fields of a record to the table columns by position
+	CSVFormatBuilder interface {
+		FormatBuilder
+		WithFieldDelimiter(delimiter string) CSVFormatBuilder
+		WithQuoteCharacter(quote string) CSVFormatBuilder
+		WithDisableQuoteCharacter(disable bool) CSVFormatBuilder
+		WithAllowComments(allow bool) CSVFormatBuilder
+		WithIgnoreParseErrors(ignore bool) CSVFormatBuilder
+		WithArrayElementDelimiter(delimiter string) CSVFormatBuilder
+		WithNullLiteral(literal string) CSVFormatBuilder
+	}
+	csvFormatBuilderImpl struct {
+		*formatBuilderImpl
+	}
+)
+
+func NewCSVFormatBuilder() CSVFormatBuilder {
+	return &csvFormatBuilderImpl{formatBuilderImpl: newFormatBuilderImpl(FormatCSV)}
+}
+
+func (f *csvFormatBuilderImpl) WithFieldDelimiter(delimiter string) CSVFormatBuilder {
+	f.withOption("field-delimiter", delimiter)
+	return f
+}
+
+func (f *csvFormatBuilderImpl) WithQuoteCharacter(quote string) CSVFormatBuilder {
+	f.withOption("quote-character", quote)
+	return f
+}
+
+func (f *csvFormatBuilderImpl) WithDisableQuoteCharacter(disable bool) CSVFormatBuilder {
+	f.withOption("disable-quote-character", strconv.FormatBool(disable))
+	return f
+}
+
+func (f *csvFormatBuilderImpl) WithAllowComments(allow bool) CSVFormatBuilder {
+	f.withOption("allow-comments", strconv.FormatBool(allow))
+	return f
+}
+
+func (f *csvFormatBuilderImpl) WithIgnoreParseErrors(ignore bool) CSVFormatBuilder {
+	f.withOption("ignore-parse-errors", strconv.FormatBool(ignore))
+	return f
+}
+
+func (f *csvFormatBuilderImpl) WithArrayElementDelimiter(delimiter string) CSVFormatBuilder {
+	f.withOption("array-element-delimiter", delimiter)
+	return f
+}
+
+func (f *csvFormatBuilderImpl) WithNullLiteral(literal string) CSVFormatBuilder {
+	f.withOption("null-literal", literal)
+	return f
+}
+
+// RawFormatBuilder
+
+type (
+	// RawFormatBuilder reads and writes a record as a single column
+	RawFormatBuilder interface {
+		FormatBuilder
+		WithCharset(charset string) RawFormatBuilder
+		WithEndianness(endianness string) RawFormatBuilder
+	}
+	rawFormatBuilderImpl struct {
+		*formatBuilderImpl
+	}
+)
+
+func NewRawFormatBuilder() RawFormatBuilder {
+	return &rawFormatBuilderImpl{formatBuilderImpl: newFormatBuilderImpl(FormatRaw)}
+}
+
+func (f *rawFormatBuilderImpl) WithCharset(charset string) RawFormatBuilder {
+	f.withOption("charset", charset)
+	return f
+}
+
+func (f *rawFormatBuilderImpl) WithEndianness(endianness string) RawFormatBuilder {
+	f.withOption("endianness", endianness)
+	return f
+}
+
+// ParquetFormatBuilder
+
+type (
+	ParquetFormatBuilder interface {
+		FormatBuilder
+		WithCompression(codec string) ParquetFormatBuilder
+	}
+	parquetFormatBuilderImpl struct {
+		*formatBuilderImpl
+	}
+)
+
+func NewParquetFormatBuilder() ParquetFormatBuilder {
+	return &parquetFormatBuilderImpl{formatBuilderImpl: newFormatBuilderImpl(FormatParquet)}
+}
+
+// WithCompression sets the compression codec of the files, such as SNAPPY, GZIP or ZSTD
+func (f *parquetFormatBuilderImpl) WithCompression(codec string) ParquetFormatBuilder {
+	f.withOption("compression", codec)
+	return f
+}
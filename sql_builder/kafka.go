@@ -0,0 +1,230 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	KafkaStartupModeEarliest        = "earliest-offset"
+	KafkaStartupModeLatest          = "latest-offset"
+	KafkaStartupModeGroupOffsets    = "group-offsets"
+	KafkaStartupModeTimestamp       = "timestamp"
+	KafkaStartupModeSpecificOffsets = "specific-offsets"
+
+	// KafkaBoundedModeTimestamp ends a bounded source at the records of a timestamp
+	KafkaBoundedModeTimestamp = "timestamp"
+)
+
+// KafkaConnectorBuilder
+
+type (
+	KafkaConnectorBuilder interface {
+		ConnectorBuilder
+		WithTopic(topic string) KafkaConnectorBuilder
+		WithBootstrapServers(servers string) KafkaConnectorBuilder
+		WithGroupID(groupID string) KafkaConnectorBuilder
+		WithStartupMode(mode string) KafkaConnectorBuilder
+		WithStartupTimestamp(millis int64) KafkaConnectorBuilder
+		WithStartupSpecificOffsets(offsets string) KafkaConnectorBuilder
+		WithBoundedMode(mode string) KafkaConnectorBuilder
+		WithBoundedTimestamp(millis int64) KafkaConnectorBuilder
+		WithAutoOffsetReset(reset string) KafkaConnectorBuilder
+		WithFormat(format FormatBuilder) KafkaConnectorBuilder
+		WithKeyFormat(format FormatBuilder) KafkaConnectorBuilder
+		WithValueFormat(format FormatBuilder) KafkaConnectorBuilder
+		WithKeyFields(fields ...string) KafkaConnectorBuilder
+		WithValueFieldsInclude(include string) KafkaConnectorBuilder
+		WithSecurityProtocol(protocol string) KafkaConnectorBuilder
+		WithSASLMechanism(mechanism string) KafkaConnectorBuilder
+		WithSASLJAASConfig(jaasConfig string) KafkaConnectorBuilder
+		WithKerberosServiceName(name string) KafkaConnectorBuilder
+		WithSSLTruststore(location, password, storeType string) KafkaConnectorBuilder
+		WithSSLKeystore(location, password, keyPassword, storeType string) KafkaConnectorBuilder
+	}
+	kafkaConnectorBuilderImpl struct {
+		*connectorBuilderImpl
+	}
+)
+
+func NewKafkaConnectorBuilder() KafkaConnectorBuilder {
+	c := &kafkaConnectorBuilderImpl{connectorBuilderImpl: newConnectorBuilderImpl()}
+	c.withOption("connector", "kafka")
+	return c
+}
+
+// NewUpsertKafkaConnectorBuilder returns the upsert-kafka connector, which writes the primary key of the table
+// as the record key and takes key and value formats instead of a format. It has no consumer group or startup options
+func NewUpsertKafkaConnectorBuilder() KafkaConnectorBuilder {
+	c := &kafkaConnectorBuilderImpl{connectorBuilderImpl: newConnectorBuilderImpl()}
+	c.withOption("connector", "upsert-kafka")
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithTopic(topic string) KafkaConnectorBuilder {
+	c.withOption("topic", topic)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithBootstrapServers(servers string) KafkaConnectorBuilder {
+	c.withOption("properties.bootstrap.servers", servers)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithGroupID(groupID string) KafkaConnectorBuilder {
+	c.withOption("properties.group.id", groupID)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithStartupMode(mode string) KafkaConnectorBuilder {
+	c.withOption("scan.startup.mode", mode)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithStartupTimestamp(millis int64) KafkaConnectorBuilder {
+	c.withOption("scan.startup.timestamp-millis", strconv.FormatInt(millis, 10))
+	return c
+}
+
+// WithStartupSpecificOffsets sets the offsets of the specific-offsets startup mode,
+// e.g. partition:0,offset:42;partition:1,offset:300
+func (c *kafkaConnectorBuilderImpl) WithStartupSpecificOffsets(offsets string) KafkaConnectorBuilder {
+	c.withOption("scan.startup.specific-offsets", offsets)
+	return c
+}
+
+// WithBoundedMode makes the source bounded, it ends where the bounded mode says instead of reading forever
+func (c *kafkaConnectorBuilderImpl) WithBoundedMode(mode string) KafkaConnectorBuilder {
+	c.withOption("scan.bounded.mode", mode)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithBoundedTimestamp(millis int64) KafkaConnectorBuilder {
+	c.withOption("scan.bounded.timestamp-millis", strconv.FormatInt(millis, 10))
+	return c
+}
+
+// WithAutoOffsetReset sets where the group-offsets startup mode starts when the group has no committed offset
+func (c *kafkaConnectorBuilderImpl) WithAutoOffsetReset(reset string) KafkaConnectorBuilder {
+	c.withOption("properties.auto.offset.reset", reset)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithFormat(format FormatBuilder) KafkaConnectorBuilder {
+	c.withFormat("", format)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithKeyFormat(format FormatBuilder) KafkaConnectorBuilder {
+	c.withFormat("key", format)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithValueFormat(format FormatBuilder) KafkaConnectorBuilder {
+	c.withFormat("value", format)
+	return c
+}
+
+// WithKeyFields sets the columns read from or written to the record key
+func (c *kafkaConnectorBuilderImpl) WithKeyFields(fields ...string) KafkaConnectorBuilder {
+	c.withOption("key.fields", strings.Join(fields, ";"))
+	return c
+}
+
+// WithValueFieldsInclude sets whether the record value also holds the key fields, ALL or EXCEPT_KEY
+func (c *kafkaConnectorBuilderImpl) WithValueFieldsInclude(include string) KafkaConnectorBuilder {
+	c.withOption("value.fields-include", include)
+	return c
+}
+
+const (
+	KafkaValueFieldsIncludeAll       = "ALL"
+	KafkaValueFieldsIncludeExceptKey = "EXCEPT_KEY"
+)
+
+const (
+	KafkaSecurityProtocolPlaintext     = "PLAINTEXT"
+	KafkaSecurityProtocolSSL           = "SSL"
+	KafkaSecurityProtocolSASLPlaintext = "SASL_PLAINTEXT"
+	KafkaSecurityProtocolSASLSSL       = "SASL_SSL"
+
+	KafkaSASLMechanismGSSAPI      = "GSSAPI"
+	KafkaSASLMechanismPlain       = "PLAIN"
+	KafkaSASLMechanismScramSHA256 = "SCRAM-SHA-256"
+	KafkaSASLMechanismScramSHA512 = "SCRAM-SHA-512"
+
+	// kafkaShadedPackage is the package Kafka classes are relocated to in flink-sql-connector-kafka
+	kafkaShadedPackage = "org.apache.flink.kafka.shaded.org.apache.kafka"
+)
+
+func (c *kafkaConnectorBuilderImpl) WithSecurityProtocol(protocol string) KafkaConnectorBuilder {
+	c.withOption("properties.security.protocol", protocol)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithSASLMechanism(mechanism string) KafkaConnectorBuilder {
+	c.withOption("properties.sasl.mechanism", mechanism)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithSASLJAASConfig(jaasConfig string) KafkaConnectorBuilder {
+	c.withOption("properties.sasl.jaas.config", jaasConfig)
+	return c
+}
+
+func (c *kafkaConnectorBuilderImpl) WithKerberosServiceName(name string) KafkaConnectorBuilder {
+	c.withOption("properties.sasl.kerberos.service.name", name)
+	return c
+}
+
+// WithSSLTruststore sets the truststore, the password and type are optional
+func (c *kafkaConnectorBuilderImpl) WithSSLTruststore(location, password, storeType string) KafkaConnectorBuilder {
+	c.withOption("properties.ssl.truststore.location", location)
+	if password != "" {
+		c.withOption("properties.ssl.truststore.password", password)
+	}
+	if storeType != "" {
+		c.withOption("properties.ssl.truststore.type", storeType)
+	}
+	return c
+}
+
+// WithSSLKeystore sets the keystore for client authentication, the passwords and type are optional
+func (c *kafkaConnectorBuilderImpl) WithSSLKeystore(location, password, keyPassword, storeType string) KafkaConnectorBuilder {
+	c.withOption("properties.ssl.keystore.location", location)
+	if password != "" {
+		c.withOption("properties.ssl.keystore.password", password)
+	}
+	if keyPassword != "" {
+		c.withOption("properties.ssl.key.password", keyPassword)
+	}
+	if storeType != "" {
+		c.withOption("properties.ssl.keystore.type", storeType)
+	}
+	return c
+}
+
+// KerberosJAASConfig renders the JAAS configuration logging in with a keytab
+func KerberosJAASConfig(keytab, principal string) string {
+	return fmt.Sprintf(`com.sun.security.auth.module.Krb5LoginModule required useKeyTab=true storeKey=true keyTab="%v" principal="%v";`,
+		jaasQuote(keytab), jaasQuote(principal))
+}
+
+// PlainJAASConfig renders the JAAS configuration of the PLAIN mechanism
+func PlainJAASConfig(username, password string) string {
+	return fmt.Sprintf(`%v.common.security.plain.PlainLoginModule required username="%v" password="%v";`,
+		kafkaShadedPackage, jaasQuote(username), password)
+}
+
+// ScramJAASConfig renders the JAAS configuration of the SCRAM mechanisms
+func ScramJAASConfig(username, password string) string {
+	return fmt.Sprintf(`%v.common.security.scram.ScramLoginModule required username="%v" password="%v";`,
+		kafkaShadedPackage, jaasQuote(username), password)
+}
+
+// jaasQuote escapes a value put inside a quoted JAAS option value. Passwords are secret placeholders which are
+// escaped when they are resolved
+func jaasQuote(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,97 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MatchRecognizeExpSQLBuilder
+
+type (
+	// MatchRecognizeExpSQLBuilder renders a MATCH_RECOGNIZE query emitting one row per match: the partition
+	// columns followed by the measures. Pattern variables are matched in the order they are added
+	MatchRecognizeExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithQueryTable(name string) MatchRecognizeExpSQLBuilder
+		WithPartitionBy(fields ...string) MatchRecognizeExpSQLBuilder
+		WithOrderBy(ts string) MatchRecognizeExpSQLBuilder
+		WithMeasure(name string, expression string) MatchRecognizeExpSQLBuilder
+		WithVariable(variable string, quantifier string, condition string) MatchRecognizeExpSQLBuilder
+		WithWithin(within time.Duration) MatchRecognizeExpSQLBuilder
+	}
+	matchRecognizeExpSQLBuilderImpl struct {
+		srcTableName string
+		partition    []string
+		orderBy      string
+		measures     []string
+		pattern      []string
+		defines      []string
+		within       time.Duration
+	}
+)
+
+func NewMatchRecognizeExpSQLBuilder() MatchRecognizeExpSQLBuilder {
+	return &matchRecognizeExpSQLBuilderImpl{}
+}
+
+func (v *matchRecognizeExpSQLBuilderImpl) WithQueryTable(name string) MatchRecognizeExpSQLBuilder {
+	v.srcTableName = name
+	return v
+}
+
+func (v *matchRecognizeExpSQLBuilderImpl) WithPartitionBy(fields ...string) MatchRecognizeExpSQLBuilder {
+	for _, f := range fields {
+		v.partition = append(v.partition, QuoteIdentifier(f))
+	}
+	return v
+}
+
+// WithOrderBy sets the time attribute the rows are matched in the order of
+func (v *matchRecognizeExpSQLBuilderImpl) WithOrderBy(ts string) MatchRecognizeExpSQLBuilder {
+	v.orderBy = ts
+	return v
+}
+
+// WithMeasure adds a column computed over the rows of a match, such as COUNT(failed.ts)
+func (v *matchRecognizeExpSQLBuilderImpl) WithMeasure(name string, expression string) MatchRecognizeExpSQLBuilder {
+	v.measures = append(v.measures, fmt.Sprintf("%v AS %v", expression, QuoteIdentifier(name)))
+	return v
+}
+
+// WithVariable appends a pattern variable. The quantifier uses the MATCH_RECOGNIZE syntax, such as {5,} or +?,
+// and an empty condition matches any row
+func (v *matchRecognizeExpSQLBuilderImpl) WithVariable(variable string, quantifier string, condition string) MatchRecognizeExpSQLBuilder {
+	v.pattern = append(v.pattern, variable+quantifier)
+	if condition != "" {
+		v.defines = append(v.defines, fmt.Sprintf("%v AS %v", variable, condition))
+	}
+	return v
+}
+
+// WithWithin bounds the time between the first and the last row of a match
+func (v *matchRecognizeExpSQLBuilderImpl) WithWithin(within time.Duration) MatchRecognizeExpSQLBuilder {
+	v.within = within
+	return v
+}
+
+func (v *matchRecognizeExpSQLBuilderImpl) Build() string {
+	var clauses []string
+	if len(v.partition) > 0 {
+		clauses = append(clauses, "PARTITION BY "+strings.Join(v.partition, ","))
+	}
+	clauses = append(clauses,
+		"ORDER BY "+QuoteIdentifier(v.orderBy),
+		"MEASURES "+strings.Join(v.measures, ","),
+		"ONE ROW PER MATCH",
+		"AFTER MATCH SKIP PAST LAST ROW",
+		fmt.Sprintf("PATTERN (%v)", strings.Join(v.pattern, " ")),
+	)
+	if v.within > 0 {
+		clauses[len(clauses)-1] += " WITHIN " + buildInterval(v.within)
+	}
+	if len(v.defines) > 0 {
+		clauses = append(clauses, "DEFINE "+strings.Join(v.defines, ","))
+	}
+	return fmt.Sprintf("SELECT * FROM %v MATCH_RECOGNIZE (%v)", v.srcTableName, strings.Join(clauses, " "))
+}
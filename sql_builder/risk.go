@@ -0,0 +1,158 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RiskExpSQLBuilder
+
+type (
+	// RiskExpSQLBuilder sums the risk scores of the rows of each object and window of a window table. With a
+	// half-life, the score of a row is halved every half-life between its time and the end of its window. The rows
+	// get the columns window_start, window_end, window_time, the object as a STRING, the risk and the row count
+	RiskExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithWindowTable(window string) RiskExpSQLBuilder
+		WithObject(name string, field string) RiskExpSQLBuilder
+		WithScore(name string, field string) RiskExpSQLBuilder
+		WithCount(name string) RiskExpSQLBuilder
+		WithDecay(timeField string, halfLife time.Duration) RiskExpSQLBuilder
+	}
+	riskExpSQLBuilderImpl struct {
+		window      string
+		objectName  string
+		objectField string
+		scoreName   string
+		scoreField  string
+		countName   string
+		timeField   string
+		halfLife    time.Duration
+	}
+)
+
+func NewRiskExpSQLBuilder() RiskExpSQLBuilder {
+	return &riskExpSQLBuilderImpl{}
+}
+
+// WithWindowTable sets the window table the rows are read from, see WindowTableSQLBuilder
+func (v *riskExpSQLBuilderImpl) WithWindowTable(window string) RiskExpSQLBuilder {
+	v.window = window
+	return v
+}
+
+// WithObject sets the field the risk is summed by and the name of the object column, rows without object are skipped
+func (v *riskExpSQLBuilderImpl) WithObject(name string, field string) RiskExpSQLBuilder {
+	v.objectName = name
+	v.objectField = field
+	return v
+}
+
+func (v *riskExpSQLBuilderImpl) WithScore(name string, field string) RiskExpSQLBuilder {
+	v.scoreName = name
+	v.scoreField = field
+	return v
+}
+
+func (v *riskExpSQLBuilderImpl) WithCount(name string) RiskExpSQLBuilder {
+	v.countName = name
+	return v
+}
+
+// WithDecay decays the scores by the half-life from the time field of the rows, scores are not decayed when it is zero
+func (v *riskExpSQLBuilderImpl) WithDecay(timeField string, halfLife time.Duration) RiskExpSQLBuilder {
+	v.timeField = timeField
+	v.halfLife = halfLife
+	return v
+}
+
+func (v *riskExpSQLBuilderImpl) Build() string {
+	object := QuoteIdentifier(v.objectField)
+	score := fmt.Sprintf("CAST(%v AS DOUBLE)", QuoteIdentifier(v.scoreField))
+	if v.halfLife > 0 {
+		score = fmt.Sprintf("%v * POWER(0.5, CAST(TIMESTAMPDIFF(SECOND, %v, window_time) AS DOUBLE) / %v)",
+			score, QuoteIdentifier(v.timeField), strconv.FormatFloat(v.halfLife.Seconds(), 'f', -1, 64))
+	}
+	fields := []string{
+		"window_start", "window_end", "window_time",
+		fmt.Sprintf("CAST(%v AS STRING) AS %v", object, QuoteIdentifier(v.objectName)),
+		fmt.Sprintf("SUM(%v) AS %v", score, QuoteIdentifier(v.scoreName)),
+		fmt.Sprintf("COUNT(*) AS %v", QuoteIdentifier(v.countName)),
+	}
+	return fmt.Sprintf("SELECT %v FROM %v WHERE %v IS NOT NULL GROUP BY window_start,window_end,window_time,%v",
+		strings.Join(fields, ","), v.window, object, object)
+}
+
+// ThresholdCrossingExpSQLBuilder
+
+type (
+	// ThresholdCrossingExpSQLBuilder keeps the rows of a windowed table whose value reaches a threshold while the value
+	// of the previous window of their key was below it. The rows are ordered by window_time per key and get the value
+	// of the previous window, 0 when the key has no row for it
+	ThresholdCrossingExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithQueryTable(name string) ThresholdCrossingExpSQLBuilder
+		WithKey(field string) ThresholdCrossingExpSQLBuilder
+		WithValue(field string) ThresholdCrossingExpSQLBuilder
+		WithPrevious(name string) ThresholdCrossingExpSQLBuilder
+		WithThreshold(threshold float64) ThresholdCrossingExpSQLBuilder
+		WithSlide(slide time.Duration) ThresholdCrossingExpSQLBuilder
+	}
+	thresholdCrossingExpSQLBuilderImpl struct {
+		srcTableName string
+		key          string
+		value        string
+		previous     string
+		threshold    float64
+		slide        time.Duration
+	}
+)
+
+func NewThresholdCrossingExpSQLBuilder() ThresholdCrossingExpSQLBuilder {
+	return &thresholdCrossingExpSQLBuilderImpl{}
+}
+
+func (v *thresholdCrossingExpSQLBuilderImpl) WithQueryTable(name string) ThresholdCrossingExpSQLBuilder {
+	v.srcTableName = name
+	return v
+}
+
+func (v *thresholdCrossingExpSQLBuilderImpl) WithKey(field string) ThresholdCrossingExpSQLBuilder {
+	v.key = field
+	return v
+}
+
+func (v *thresholdCrossingExpSQLBuilderImpl) WithValue(field string) ThresholdCrossingExpSQLBuilder {
+	v.value = field
+	return v
+}
+
+// WithPrevious sets the name of the column holding the value of the previous window
+func (v *thresholdCrossingExpSQLBuilderImpl) WithPrevious(name string) ThresholdCrossingExpSQLBuilder {
+	v.previous = name
+	return v
+}
+
+func (v *thresholdCrossingExpSQLBuilderImpl) WithThreshold(threshold float64) ThresholdCrossingExpSQLBuilder {
+	v.threshold = threshold
+	return v
+}
+
+// WithSlide sets how far the windows move, the previous row of a key ending earlier than a slide before the row
+// belongs to an older window
+func (v *thresholdCrossingExpSQLBuilderImpl) WithSlide(slide time.Duration) ThresholdCrossingExpSQLBuilder {
+	v.slide = slide
+	return v
+}
+
+func (v *thresholdCrossingExpSQLBuilderImpl) Build() string {
+	over := fmt.Sprintf("OVER (PARTITION BY %v ORDER BY window_time)", QuoteIdentifier(v.key))
+	previous := fmt.Sprintf("CASE WHEN TIMESTAMPDIFF(SECOND, LAG(window_end) %[1]v, window_end) <= %[2]v THEN LAG(%[3]v) %[1]v ELSE 0 END",
+		over, int64(v.slide/time.Second), QuoteIdentifier(v.value))
+	threshold := strconv.FormatFloat(v.threshold, 'f', -1, 64)
+	return fmt.Sprintf("SELECT * FROM (SELECT *,%v AS %v FROM %v) WHERE %v >= %v AND %v < %v",
+		previous, QuoteIdentifier(v.previous), v.srcTableName,
+		QuoteIdentifier(v.value), threshold, QuoteIdentifier(v.previous), threshold)
+}
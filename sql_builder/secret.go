@@ -0,0 +1,132 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsSecretOption reports whether the connector option carries a credential, only the values of those options
+// may hold secret placeholders
+func IsSecretOption(key string) bool {
+	return key == "password" ||
+		strings.HasSuffix(key, ".password") ||
+		strings.HasSuffix(key, ".sasl.jaas.config") ||
+		strings.HasSuffix(key, ".basic-auth.user-info")
+}
+
+// ResolveSecretOptions resolves the values of the secret options in the WITH clause of a CREATE TABLE statement.
+// Other options and the rest of the statement are left untouched, so a placeholder in a name, a filter or any
+// other literal is never resolved
+func ResolveSecretOptions(stm string, resolve func(value string) (string, error)) (string, error) {
+	if !strings.HasPrefix(strings.ToUpper(stm), "CREATE TABLE ") {
+		return stm, nil
+	}
+	start := findConnectorOptions(stm)
+	if start < 0 {
+		return stm, nil
+	}
+	var sb strings.Builder
+	sb.WriteString(stm[:start])
+	pos := start
+	for {
+		pos = skipSpaces(stm, pos)
+		if pos < len(stm) && stm[pos] == ')' {
+			sb.WriteString(stm[pos:])
+			return sb.String(), nil
+		}
+		key, keyEnd, err := readLiteral(stm, pos)
+		if err != nil {
+			return "", err
+		}
+		valueStart := skipSpaces(stm, keyEnd)
+		if valueStart >= len(stm) || stm[valueStart] != '=' {
+			return "", fmt.Errorf("malformed connector option '%v'", key)
+		}
+		valueStart = skipSpaces(stm, valueStart+1)
+		value, valueEnd, err := readLiteral(stm, valueStart)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(stm[pos:valueStart])
+		if IsSecretOption(key) {
+			resolved, err := resolve(value)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(QuoteLiteral(resolved))
+		} else {
+			sb.WriteString(stm[valueStart:valueEnd])
+		}
+		pos = skipSpaces(stm, valueEnd)
+		sb.WriteString(stm[valueEnd:pos])
+		if pos < len(stm) && stm[pos] == ',' {
+			sb.WriteByte(',')
+			pos++
+		}
+	}
+}
+
+// findConnectorOptions returns the position right after the opening parenthesis of the WITH clause, which is the
+// first WITH outside literals, quoted identifiers and the parentheses of the schema
+func findConnectorOptions(stm string) int {
+	depth := 0
+	for i := 0; i < len(stm); i++ {
+		switch stm[i] {
+		case '\'', '`':
+			end := strings.IndexByte(stm[i+1:], stm[i])
+			for end >= 0 && i+1+end+1 < len(stm) && stm[i+1+end+1] == stm[i] {
+				// doubled quote escaping the quote
+				next := strings.IndexByte(stm[i+1+end+2:], stm[i])
+				if next < 0 {
+					return -1
+				}
+				end = end + 2 + next
+			}
+			if end < 0 {
+				return -1
+			}
+			i = i + 1 + end
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case 'W', 'w':
+			if depth != 0 || i == 0 || stm[i-1] != ' ' || !strings.HasPrefix(strings.ToUpper(stm[i:]), "WITH") {
+				continue
+			}
+			j := skipSpaces(stm, i+len("WITH"))
+			if j < len(stm) && stm[j] == '(' {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
+
+// readLiteral reads the SQL string literal at pos and returns its unescaped value and the position after it
+func readLiteral(stm string, pos int) (string, int, error) {
+	if pos >= len(stm) || stm[pos] != '\'' {
+		return "", 0, fmt.Errorf("malformed connector options at %v", pos)
+	}
+	var sb strings.Builder
+	for i := pos + 1; i < len(stm); i++ {
+		if stm[i] != '\'' {
+			sb.WriteByte(stm[i])
+			continue
+		}
+		if i+1 < len(stm) && stm[i+1] == '\'' {
+			sb.WriteByte('\'')
+			i++
+			continue
+		}
+		return sb.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated literal at %v", pos)
+}
+
+func skipSpaces(stm string, pos int) int {
+	for pos < len(stm) && (stm[pos] == ' ' || stm[pos] == '\n' || stm[pos] == '\t' || stm[pos] == '\r') {
+		pos++
+	}
+	return pos
+}
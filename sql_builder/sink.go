@@ -0,0 +1,196 @@
+package sql_builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationOption renders a duration as a Flink duration option
+func durationOption(d time.Duration) string {
+	return fmt.Sprintf("%v ms", d.Milliseconds())
+}
+
+// ElasticsearchConnectorBuilder
+
+type (
+	// ElasticsearchConnectorBuilder renders the elasticsearch-6, elasticsearch-7 and opensearch sinks, which share
+	// their options. Records are indexed with generated IDs unless the table declares a primary key
+	ElasticsearchConnectorBuilder interface {
+		ConnectorBuilder
+		WithHosts(hosts ...string) ElasticsearchConnectorBuilder
+		WithIndex(index string) ElasticsearchConnectorBuilder
+		WithUsername(username string) ElasticsearchConnectorBuilder
+		WithPassword(password string) ElasticsearchConnectorBuilder
+		WithBulkFlushMaxActions(maxActions int) ElasticsearchConnectorBuilder
+		WithBulkFlushInterval(interval time.Duration) ElasticsearchConnectorBuilder
+	}
+	elasticsearchConnectorBuilderImpl struct {
+		*connectorBuilderImpl
+	}
+)
+
+// NewElasticsearchConnectorBuilder returns the sink of the Elasticsearch major version, 6 or 7
+func NewElasticsearchConnectorBuilder(version int) ElasticsearchConnectorBuilder {
+	c := &elasticsearchConnectorBuilderImpl{connectorBuilderImpl: newConnectorBuilderImpl()}
+	c.withOption("connector", fmt.Sprintf("elasticsearch-%v", version))
+	return c
+}
+
+func NewOpenSearchConnectorBuilder() ElasticsearchConnectorBuilder {
+	c := &elasticsearchConnectorBuilderImpl{connectorBuilderImpl: newConnectorBuilderImpl()}
+	c.withOption("connector", "opensearch")
+	return c
+}
+
+func (c *elasticsearchConnectorBuilderImpl) WithHosts(hosts ...string) ElasticsearchConnectorBuilder {
+	c.withOption("hosts", strings.Join(hosts, ";"))
+	return c
+}
+
+// WithIndex sets the index, which may be dynamic such as alerts-{alert_time|yyyy-MM-dd}
+func (c *elasticsearchConnectorBuilderImpl) WithIndex(index string) ElasticsearchConnectorBuilder {
+	c.withOption("index", index)
+	return c
+}
+
+func (c *elasticsearchConnectorBuilderImpl) WithUsername(username string) ElasticsearchConnectorBuilder {
+	c.withOption("username", username)
+	return c
+}
+
+func (c *elasticsearchConnectorBuilderImpl) WithPassword(password string) ElasticsearchConnectorBuilder {
+	c.withOption("password", password)
+	return c
+}
+
+func (c *elasticsearchConnectorBuilderImpl) WithBulkFlushMaxActions(maxActions int) ElasticsearchConnectorBuilder {
+	c.withOption("sink.bulk-flush.max-actions", strconv.Itoa(maxActions))
+	return c
+}
+
+func (c *elasticsearchConnectorBuilderImpl) WithBulkFlushInterval(interval time.Duration) ElasticsearchConnectorBuilder {
+	c.withOption("sink.bulk-flush.interval", durationOption(interval))
+	return c
+}
+
+// JDBCConnectorBuilder
+
+type (
+	// JDBCConnectorBuilder renders the jdbc connector: a sink, which appends rows unless the table declares a primary
+	// key, or a lookup table joined at processing time
+	JDBCConnectorBuilder interface {
+		ConnectorBuilder
+		WithURL(url string) JDBCConnectorBuilder
+		WithTableName(table string) JDBCConnectorBuilder
+		WithDriver(driver string) JDBCConnectorBuilder
+		WithUsername(username string) JDBCConnectorBuilder
+		WithPassword(password string) JDBCConnectorBuilder
+		WithBufferFlushMaxRows(maxRows int) JDBCConnectorBuilder
+		WithBufferFlushInterval(interval time.Duration) JDBCConnectorBuilder
+		WithMaxRetries(maxRetries int) JDBCConnectorBuilder
+		WithLookupCache(maxRows int, ttl time.Duration) JDBCConnectorBuilder
+	}
+	jdbcConnectorBuilderImpl struct {
+		*connectorBuilderImpl
+	}
+)
+
+func NewJDBCConnectorBuilder() JDBCConnectorBuilder {
+	c := &jdbcConnectorBuilderImpl{connectorBuilderImpl: newConnectorBuilderImpl()}
+	c.withOption("connector", "jdbc")
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithURL(url string) JDBCConnectorBuilder {
+	c.withOption("url", url)
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithTableName(table string) JDBCConnectorBuilder {
+	c.withOption("table-name", table)
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithDriver(driver string) JDBCConnectorBuilder {
+	c.withOption("driver", driver)
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithUsername(username string) JDBCConnectorBuilder {
+	c.withOption("username", username)
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithPassword(password string) JDBCConnectorBuilder {
+	c.withOption("password", password)
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithBufferFlushMaxRows(maxRows int) JDBCConnectorBuilder {
+	c.withOption("sink.buffer-flush.max-rows", strconv.Itoa(maxRows))
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithBufferFlushInterval(interval time.Duration) JDBCConnectorBuilder {
+	c.withOption("sink.buffer-flush.interval", durationOption(interval))
+	return c
+}
+
+func (c *jdbcConnectorBuilderImpl) WithMaxRetries(maxRetries int) JDBCConnectorBuilder {
+	c.withOption("sink.max-retries", strconv.Itoa(maxRetries))
+	return c
+}
+
+// WithLookupCache caches up to maxRows looked up rows for the ttl, so lookups do not all reach the database
+func (c *jdbcConnectorBuilderImpl) WithLookupCache(maxRows int, ttl time.Duration) JDBCConnectorBuilder {
+	c.withOption("lookup.cache.max-rows", strconv.Itoa(maxRows))
+	c.withOption("lookup.cache.ttl", durationOption(ttl))
+	return c
+}
+
+// FilesystemConnectorBuilder
+
+type (
+	// FilesystemConnectorBuilder renders the filesystem sink. In streaming mode files are committed on checkpoints,
+	// so checkpointing must be enabled for them to become visible
+	FilesystemConnectorBuilder interface {
+		ConnectorBuilder
+		WithPath(path string) FilesystemConnectorBuilder
+		WithFormat(format FormatBuilder) FilesystemConnectorBuilder
+		WithRollingFileSize(size string) FilesystemConnectorBuilder
+		WithRolloverInterval(interval time.Duration) FilesystemConnectorBuilder
+	}
+	filesystemConnectorBuilderImpl struct {
+		*connectorBuilderImpl
+	}
+)
+
+func NewFilesystemConnectorBuilder() FilesystemConnectorBuilder {
+	c := &filesystemConnectorBuilderImpl{connectorBuilderImpl: newConnectorBuilderImpl()}
+	c.withOption("connector", "filesystem")
+	return c
+}
+
+// WithPath sets the directory the files are written to, such as s3://bucket/behaviors or file:///data/behaviors
+func (c *filesystemConnectorBuilderImpl) WithPath(path string) FilesystemConnectorBuilder {
+	c.withOption("path", path)
+	return c
+}
+
+func (c *filesystemConnectorBuilderImpl) WithFormat(format FormatBuilder) FilesystemConnectorBuilder {
+	c.withFormat("", format)
+	return c
+}
+
+// WithRollingFileSize sets the size a part file is rolled at, such as 128MB
+func (c *filesystemConnectorBuilderImpl) WithRollingFileSize(size string) FilesystemConnectorBuilder {
+	c.withOption("sink.rolling-policy.file-size", size)
+	return c
+}
+
+func (c *filesystemConnectorBuilderImpl) WithRolloverInterval(interval time.Duration) FilesystemConnectorBuilder {
+	c.withOption("sink.rolling-policy.rollover-interval", durationOption(interval))
+	return c
+}
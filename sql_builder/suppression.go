@@ -0,0 +1,74 @@
+package sql_builder
+
+import (
+	"fmt"
+	"time"
+)
+
+// SuppressionExpSQLBuilder
+
+type (
+	// SuppressionExpSQLBuilder keeps the first alerts of each key in tumbling windows of the time attribute, using a
+	// window Top-N, and joins them to the number of alerts of their key and window. The kept alerts get the columns
+	// window_start, window_end, alert_rank and suppressed_count and are emitted when their window closes
+	SuppressionExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithQueryTable(name string) SuppressionExpSQLBuilder
+		WithTimeField(ts string) SuppressionExpSQLBuilder
+		WithKeyField(key string) SuppressionExpSQLBuilder
+		WithDuration(duration time.Duration) SuppressionExpSQLBuilder
+		WithMaxAlerts(maxAlerts int) SuppressionExpSQLBuilder
+	}
+	suppressionExpSQLBuilderImpl struct {
+		srcTableName string
+		tsField      string
+		keyField     string
+		duration     time.Duration
+		maxAlerts    int
+	}
+)
+
+func NewSuppressionExpSQLBuilder() SuppressionExpSQLBuilder {
+	return &suppressionExpSQLBuilderImpl{maxAlerts: 1}
+}
+
+func (v *suppressionExpSQLBuilderImpl) WithQueryTable(name string) SuppressionExpSQLBuilder {
+	v.srcTableName = name
+	return v
+}
+
+func (v *suppressionExpSQLBuilderImpl) WithTimeField(ts string) SuppressionExpSQLBuilder {
+	v.tsField = ts
+	return v
+}
+
+// WithKeyField sets the column alerts are deduplicated on, it must not be null so the count can be joined back
+func (v *suppressionExpSQLBuilderImpl) WithKeyField(key string) SuppressionExpSQLBuilder {
+	v.keyField = key
+	return v
+}
+
+func (v *suppressionExpSQLBuilderImpl) WithDuration(duration time.Duration) SuppressionExpSQLBuilder {
+	v.duration = duration
+	return v
+}
+
+// WithMaxAlerts sets how many alerts of a key are kept per window, 1 by default
+func (v *suppressionExpSQLBuilderImpl) WithMaxAlerts(maxAlerts int) SuppressionExpSQLBuilder {
+	v.maxAlerts = maxAlerts
+	return v
+}
+
+func (v *suppressionExpSQLBuilderImpl) Build() string {
+	window := NewWindowTableSQLBuilder().
+		WithQueryTable(v.srcTableName).
+		WithTimeField(v.tsField).
+		WithSize(v.duration).
+		Build()
+	key := QuoteIdentifier(v.keyField)
+	kept := fmt.Sprintf("SELECT * FROM (SELECT *,ROW_NUMBER() OVER (PARTITION BY window_start,window_end,%[1]v ORDER BY %[2]v ASC) AS `alert_rank` "+
+		"FROM %[3]v) WHERE `alert_rank` <= %[4]v", key, QuoteIdentifier(v.tsField), window, v.maxAlerts)
+	counts := fmt.Sprintf("SELECT window_start,window_end,%[1]v,COUNT(*) AS `alert_count` FROM %[2]v GROUP BY window_start,window_end,%[1]v", key, window)
+	return fmt.Sprintf("SELECT k.*,GREATEST(c.`alert_count` - %[1]v, 0) AS `suppressed_count` FROM (%[2]v) k JOIN (%[3]v) c "+
+		"ON k.window_start = c.window_start AND k.window_end = c.window_end AND k.%[4]v = c.%[4]v", v.maxAlerts, kept, counts, key)
+}
@@ -0,0 +1,169 @@
+package sql_builder
+
+import (
+	"flink_ueba_manager/sql_builder/data_type"
+	"fmt"
+	"time"
+)
+
+const (
+	// TimestampFormatSQL parses strings such as 2006-01-02 15:04:05.000, it is the default format
+	TimestampFormatSQL     = "sql"
+	TimestampFormatISO8601 = "iso-8601"
+	// TimestampFormatEpoch detects the unit of an epoch by its magnitude, like util.NormalizeTimeAsMilliseconds
+	TimestampFormatEpoch        = "epoch"
+	TimestampFormatEpochSeconds = "epoch_s"
+	TimestampFormatEpochMillis  = "epoch_ms"
+	TimestampFormatEpochMicros  = "epoch_us"
+	TimestampFormatEpochNanos   = "epoch_ns"
+	// TimestampFormatCustom parses strings with a java.time pattern such as dd/MM/yyyy HH:mm:ss
+	TimestampFormatCustom = "custom"
+	// TimestampFormatLTZ uses a TIMESTAMP_LTZ column, such as the Kafka broker timestamp, as is
+	TimestampFormatLTZ = "timestamp_ltz"
+
+	DefaultWatermarkDelay = time.Minute
+
+	// iso8601LocalRegex captures the date and time of an ISO-8601 time, iso8601OffsetRegex the sign, hours and
+	// minutes of its offset. Flink runs them as Java regular expressions
+	iso8601LocalRegex  = `^(\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d{1,9})?)`
+	iso8601OffsetRegex = `([+-])(\d{2}):?(\d{2})$`
+)
+
+// IsEpochTimestampFormat reports whether the format parses numeric epochs
+func IsEpochTimestampFormat(format string) bool {
+	switch format {
+	case TimestampFormatEpoch, TimestampFormatEpochSeconds, TimestampFormatEpochMillis, TimestampFormatEpochMicros, TimestampFormatEpochNanos:
+		return true
+	}
+	return false
+}
+
+// IsTimestampFormat reports whether the format is supported by TimestampExpSQLBuilder
+func IsTimestampFormat(format string) bool {
+	switch format {
+	case "", TimestampFormatSQL, TimestampFormatISO8601, TimestampFormatCustom, TimestampFormatLTZ:
+		return true
+	}
+	return IsEpochTimestampFormat(format)
+}
+
+// TimestampExpSQLBuilder
+
+type (
+	// TimestampExpSQLBuilder renders the expression converting a raw column into an event time.
+	// Epochs are converted into TIMESTAMP_LTZ(3), strings into TIMESTAMP(3) holding the UTC time
+	TimestampExpSQLBuilder interface {
+		FlinkSQLBuilder
+		WithFormat(format string) TimestampExpSQLBuilder
+		WithPattern(pattern string) TimestampExpSQLBuilder
+		WithTimezone(timezone string) TimestampExpSQLBuilder
+		DataType() string
+		BuildLTZ() string
+	}
+	timestampExpSQLBuilderImpl struct {
+		column   string
+		format   string
+		pattern  string
+		timezone string
+	}
+)
+
+func NewTimestampExpSQLBuilder(column string) TimestampExpSQLBuilder {
+	return &timestampExpSQLBuilderImpl{column: column, format: TimestampFormatSQL}
+}
+
+func (t *timestampExpSQLBuilderImpl) WithFormat(format string) TimestampExpSQLBuilder {
+	if format != "" {
+		t.format = format
+	}
+	return t
+}
+
+func (t *timestampExpSQLBuilderImpl) WithPattern(pattern string) TimestampExpSQLBuilder {
+	t.pattern = pattern
+	return t
+}
+
+// WithTimezone sets the time zone of string timestamps without offset, it has no effect on epochs
+func (t *timestampExpSQLBuilderImpl) WithTimezone(timezone string) TimestampExpSQLBuilder {
+	t.timezone = timezone
+	return t
+}
+
+// DataType returns the type of the rendered expression
+func (t *timestampExpSQLBuilderImpl) DataType() string {
+	if IsEpochTimestampFormat(t.format) || t.format == TimestampFormatLTZ {
+		return data_type.TIMESTAMP_LTZ_PRECISION(3)
+	}
+	return data_type.TIMESTAMP_PRECISION(3)
+}
+
+func (t *timestampExpSQLBuilderImpl) Build() string {
+	switch t.format {
+	case TimestampFormatLTZ:
+		return fmt.Sprintf("CAST(%v AS TIMESTAMP_LTZ(3))", t.column)
+	case TimestampFormatEpochSeconds:
+		return fmt.Sprintf("TO_TIMESTAMP_LTZ(%v, 0)", t.column)
+	case TimestampFormatEpochMillis:
+		return fmt.Sprintf("TO_TIMESTAMP_LTZ(%v, 3)", t.column)
+	case TimestampFormatEpochMicros:
+		return fmt.Sprintf("TO_TIMESTAMP_LTZ(%v / 1000, 3)", t.column)
+	case TimestampFormatEpochNanos:
+		return fmt.Sprintf("TO_TIMESTAMP_LTZ(%v / 1000000, 3)", t.column)
+	case TimestampFormatEpoch:
+		return fmt.Sprintf("TO_TIMESTAMP_LTZ(CASE WHEN %[1]v > 1999999999999999 THEN %[1]v / 1000000 "+
+			"WHEN %[1]v > 1999999999999 THEN %[1]v / 1000 "+
+			"WHEN %[1]v > 1999999999 THEN %[1]v "+
+			"ELSE %[1]v * 1000 END, 3)", t.column)
+	case TimestampFormatISO8601:
+		return t.buildISO8601()
+	case TimestampFormatCustom:
+		return t.toUTC(fmt.Sprintf("TO_TIMESTAMP(%v, '%v')", t.column, escapeLiteral(t.pattern)))
+	default:
+		return t.toUTC(fmt.Sprintf("CAST(%v as TIMESTAMP(3))", t.column))
+	}
+}
+
+// BuildLTZ renders the expression as a TIMESTAMP_LTZ(3). Strings, parsed into UTC times, are converted from
+// their distance to the epoch so the result does not depend on the session time zone
+func (t *timestampExpSQLBuilderImpl) BuildLTZ() string {
+	ts := t.Build()
+	if t.DataType() == data_type.TIMESTAMP_LTZ_PRECISION(3) {
+		return ts
+	}
+	return fmt.Sprintf("TO_TIMESTAMP_LTZ(CAST(TIMESTAMPDIFF(SECOND, TIMESTAMP '1970-01-01 00:00:00', %[1]v) AS BIGINT) * 1000 + "+
+		"MOD(EXTRACT(MILLISECOND FROM %[1]v), 1000), 3)", ts)
+}
+
+// buildISO8601 parses times such as 2006-01-02T15:04:05.000+07:00. The offset, Z or +hh:mm, -hhmm and the like,
+// is subtracted from the local time, times without offset are in the configured time zone
+func (t *timestampExpSQLBuilderImpl) buildISO8601() string {
+	local := fmt.Sprintf("CAST(REPLACE(REGEXP_EXTRACT(%v, '%v', 1), 'T', ' ') AS TIMESTAMP(3))", t.column, iso8601LocalRegex)
+	offsetPart := func(group int) string {
+		return fmt.Sprintf("REGEXP_EXTRACT(%v, '%v', %v)", t.column, iso8601OffsetRegex, group)
+	}
+	offsetSeconds := fmt.Sprintf("(CASE WHEN %v = '-' THEN -1 ELSE 1 END) * (CAST(%v AS INT) * 3600 + CAST(%v AS INT) * 60)",
+		offsetPart(1), offsetPart(2), offsetPart(3))
+	return fmt.Sprintf("CASE WHEN REGEXP(%[1]v, '%[2]v') THEN TIMESTAMPADD(SECOND, -%[3]v, %[4]v) "+
+		"WHEN UPPER(%[1]v) LIKE '%%Z' THEN %[4]v ELSE %[5]v END",
+		t.column, iso8601OffsetRegex, offsetSeconds, local, t.toUTC(local))
+}
+
+// toUTC shifts a local timestamp of the configured time zone to UTC. The offset is computed by CONVERT_TZ
+// at second precision and added back to the original value so milliseconds are kept
+func (t *timestampExpSQLBuilderImpl) toUTC(ts string) string {
+	if t.timezone == "" || t.timezone == "UTC" {
+		return ts
+	}
+	seconds := fmt.Sprintf("DATE_FORMAT(%v, 'yyyy-MM-dd HH:mm:ss')", ts)
+	return fmt.Sprintf("TIMESTAMPADD(SECOND, TIMESTAMPDIFF(SECOND, CAST(%v AS TIMESTAMP(3)), "+
+		"CAST(CONVERT_TZ(%v, '%v', 'UTC') AS TIMESTAMP(3))), %v)", seconds, seconds, escapeLiteral(t.timezone), ts)
+}
+
+// buildInterval renders a duration as a SQL interval literal
+func buildInterval(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("INTERVAL '%v' SECOND", int64(d/time.Second))
+	}
+	return fmt.Sprintf("INTERVAL '%.3f' SECOND", d.Seconds())
+}
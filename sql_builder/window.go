@@ -0,0 +1,59 @@
+package sql_builder
+
+import (
+	"fmt"
+	"time"
+)
+
+// WindowTableSQLBuilder
+
+type (
+	// WindowTableSQLBuilder renders the windowing table-valued function over the time attribute of a table: tumbling
+	// windows, or hopping windows when a slide is set. The windowed rows get the columns window_start, window_end and
+	// window_time
+	WindowTableSQLBuilder interface {
+		FlinkSQLBuilder
+		WithQueryTable(name string) WindowTableSQLBuilder
+		WithTimeField(ts string) WindowTableSQLBuilder
+		WithSize(size time.Duration) WindowTableSQLBuilder
+		WithSlide(slide time.Duration) WindowTableSQLBuilder
+	}
+	windowTableSQLBuilderImpl struct {
+		srcTableName string
+		tsField      string
+		size         time.Duration
+		slide        time.Duration
+	}
+)
+
+func NewWindowTableSQLBuilder() WindowTableSQLBuilder {
+	return &windowTableSQLBuilderImpl{}
+}
+
+func (v *windowTableSQLBuilderImpl) WithQueryTable(name string) WindowTableSQLBuilder {
+	v.srcTableName = name
+	return v
+}
+
+func (v *windowTableSQLBuilderImpl) WithTimeField(ts string) WindowTableSQLBuilder {
+	v.tsField = ts
+	return v
+}
+
+func (v *windowTableSQLBuilderImpl) WithSize(size time.Duration) WindowTableSQLBuilder {
+	v.size = size
+	return v
+}
+
+// WithSlide sets how far hopping windows move, the windows tumble when it is zero or the size
+func (v *windowTableSQLBuilderImpl) WithSlide(slide time.Duration) WindowTableSQLBuilder {
+	v.slide = slide
+	return v
+}
+
+func (v *windowTableSQLBuilderImpl) Build() string {
+	if v.slide > 0 && v.slide != v.size {
+		return fmt.Sprintf("TABLE(HOP(TABLE %v, DESCRIPTOR(%v), %v, %v))", v.srcTableName, QuoteIdentifier(v.tsField), buildInterval(v.slide), buildInterval(v.size))
+	}
+	return fmt.Sprintf("TABLE(TUMBLE(TABLE %v, DESCRIPTOR(%v), %v))", v.srcTableName, QuoteIdentifier(v.tsField), buildInterval(v.size))
+}
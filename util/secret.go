@@ -0,0 +1,138 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+/*
+Secrets are referenced in job configs as
+env:NAME   the value of the environment variable NAME
+file:PATH  the content of the file at PATH in the secrets directory, without trailing newlines. PATH is relative
+           and may not leave the directory, see SetSecretsDir
+
+Rendered statements carry placeholders for those references in the values of the secret connector options, so
+plans and logs never contain the values. ResolveSecrets replaces the placeholders of such a value right before the
+statement is submitted, see sql_builder.ResolveSecretOptions.
+*/
+const (
+	secretRefEnv  = "env:"
+	secretRefFile = "file:"
+)
+
+var secretPlaceholderRegexp = regexp.MustCompile(`\$\{secret:([^}|]+)(\|jaas)?\}`)
+
+// secretsDir is the directory the files of file: references are read from, file: references are rejected while
+// it is not set
+var secretsDir string
+
+// SetSecretsDir sets the directory the files of file: references are read from
+func SetSecretsDir(dir string) {
+	secretsDir = dir
+}
+
+// IsSecretRef reports whether the value references a secret
+func IsSecretRef(value string) bool {
+	return (strings.HasPrefix(value, secretRefEnv) && len(value) > len(secretRefEnv)) ||
+		(strings.HasPrefix(value, secretRefFile) && checkSecretFilePath(strings.TrimPrefix(value, secretRefFile)) == nil)
+}
+
+// SecretPlaceholder returns the placeholder of a secret used inside a SQL string literal
+func SecretPlaceholder(ref string) string {
+	return fmt.Sprintf("${secret:%v}", ref)
+}
+
+// ContainsSecretPlaceholder reports whether the value holds a secret placeholder, which user-supplied values may not
+func ContainsSecretPlaceholder(value string) bool {
+	return strings.Contains(value, "${secret:")
+}
+
+// JAASSecretPlaceholder returns the placeholder of a secret used inside a quoted JAAS option value
+func JAASSecretPlaceholder(ref string) string {
+	return fmt.Sprintf("${secret:%v|jaas}", ref)
+}
+
+// ResolveSecrets replaces the secret placeholders in a connector option value by their values
+func ResolveSecrets(value string) (string, error) {
+	var resolveErr error
+	resolved := secretPlaceholderRegexp.ReplaceAllStringFunc(value, func(placeholder string) string {
+		m := secretPlaceholderRegexp.FindStringSubmatch(placeholder)
+		secret, err := ResolveSecret(m[1])
+		if err != nil {
+			if resolveErr == nil {
+				resolveErr = err
+			}
+			return placeholder
+		}
+		if m[2] != "" {
+			return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(secret)
+		}
+		return secret
+	})
+	return resolved, resolveErr
+}
+
+// ResolveSecret returns the value of a secret reference
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretRefEnv):
+		name := strings.TrimPrefix(ref, secretRefEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret environment variable %v is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretRefFile):
+		path, err := secretFilePath(strings.TrimPrefix(ref, secretRefFile))
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read secret file %v: %v", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return "", fmt.Errorf("unsupported secret reference '%v'", ref)
+	}
+}
+
+// checkSecretFilePath makes sure the path of a file: reference is relative and stays in the secrets directory
+func checkSecretFilePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("secret file path is empty")
+	}
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("secret file %v must be relative to the secrets directory", path)
+	}
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf("secret file %v leaves the secrets directory", path)
+	}
+	return nil
+}
+
+// secretFilePath returns the path of the file of a file: reference in the secrets directory. Symbolic links are
+// followed, so a link in the directory may not point out of it either
+func secretFilePath(path string) (string, error) {
+	if secretsDir == "" {
+		return "", fmt.Errorf("secret file %v cannot be read, no secrets directory is configured", path)
+	}
+	if err := checkSecretFilePath(path); err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(secretsDir)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the secrets directory: %v", err)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(dir, path))
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret file %v: %v", path, err)
+	}
+	if rel, err := filepath.Rel(dir, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("secret file %v leaves the secrets directory", path)
+	}
+	return resolved, nil
+}
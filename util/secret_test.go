@@ -0,0 +1,62 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecretFile(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "secrets")
+	if err := os.MkdirAll(filepath.Join(dir, "kafka"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(dir, "kafka", "password"): "s3cret\n",
+		filepath.Join(root, "x"):                "outside",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "x"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	defer SetSecretsDir(secretsDir)
+	SetSecretsDir(dir)
+
+	if got, err := ResolveSecret("file:kafka/password"); err != nil || got != "s3cret" {
+		t.Errorf("got %q, %v, want s3cret", got, err)
+	}
+	if got, err := ResolveSecret("file:kafka/../kafka/password"); err != nil || got != "s3cret" {
+		t.Errorf("got %q, %v for a path cleaned into the directory, want s3cret", got, err)
+	}
+	for _, ref := range []string{
+		"file:../x",
+		"file:kafka/../../x",
+		"file:" + filepath.Join(root, "x"),
+		"file:/etc/passwd",
+		"file:link",
+	} {
+		got, err := ResolveSecret(ref)
+		if err == nil {
+			t.Errorf("%v was resolved to %q, want it rejected", ref, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), "secrets directory") {
+			t.Errorf("%v: unexpected error %v", ref, err)
+		}
+	}
+	for _, ref := range []string{"file:../x", "file:/etc/passwd"} {
+		if IsSecretRef(ref) {
+			t.Errorf("%v is accepted as a secret reference", ref)
+		}
+	}
+
+	SetSecretsDir("")
+	if _, err := ResolveSecret("file:kafka/password"); err == nil {
+		t.Error("a secret file was read without a secrets directory")
+	}
+}
@@ -0,0 +1,259 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/util"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AggregationCount         = "count"
+	AggregationCountDistinct = "count_distinct"
+	AggregationSum           = "sum"
+	AggregationAvg           = "avg"
+	AggregationMin           = "min"
+	AggregationMax           = "max"
+
+	// AggregateValueField is the column holding the aggregate of a group and window
+	AggregateValueField = "aggregate_value"
+)
+
+var (
+	aggregationFunctions = []string{AggregationCount, AggregationCountDistinct, AggregationSum, AggregationAvg, AggregationMin, AggregationMax}
+	// aggregationOperators maps the comparison operators of a threshold to their SQL
+	aggregationOperators = map[string]string{">": ">", ">=": ">=", "<": "<", "<=": "<=", "=": "=", "!=": "<>"}
+	// aggregationWindowColumns are the window bounds the alerts carry, they cannot be group columns
+	aggregationWindowColumns = []string{"window_start", "window_end"}
+)
+
+// DeriveSchemas fills the source schema from the schema registry when the source asks for it
+func (c *AggregationJobConfig) DeriveSchemas(fetch SchemaFetcher) error {
+	return c.SourceConfig.deriveSchema("source_config", fetch)
+}
+
+// FilterSchema returns the columns a filter can reference: the source columns and the event time
+func (c *AggregationJobConfig) FilterSchema() map[string]string {
+	schema := c.SourceConfig.Columns()
+	schema[EventTimeField] = c.SourceConfig.EventTime().DataType()
+	return schema
+}
+
+func (c *AggregationJobConfig) GetFunction() string {
+	return strings.ToLower(c.Function)
+}
+
+func (c *AggregationJobConfig) GetOperator() string {
+	if c.Operator == "" {
+		return ">"
+	}
+	return c.Operator
+}
+
+// OperatorSQL returns the SQL comparison operator of the threshold
+func (c *AggregationJobConfig) OperatorSQL() string {
+	return aggregationOperators[c.GetOperator()]
+}
+
+func (c *AggregationJobConfig) GetWindow() (time.Duration, error) {
+	return util.ParseDurationExtended(c.Window)
+}
+
+// GetSlide returns how far the windows hop, zero when they tumble
+func (c *AggregationJobConfig) GetSlide() (time.Duration, error) {
+	if c.Slide == "" {
+		return 0, nil
+	}
+	return util.ParseDurationExtended(c.Slide)
+}
+
+// AggregateExpression renders the aggregate of a group, see aggregateExpression
+func (c *AggregationJobConfig) AggregateExpression() string {
+	return aggregateExpression(c.GetFunction(), c.Field)
+}
+
+// AggregateType returns the type of the aggregate column
+func (c *AggregationJobConfig) AggregateType() string {
+	return aggregateType(c.GetFunction())
+}
+
+// aggregateExpression renders an aggregate function of a field. Counts are BIGINT, the other aggregates are
+// computed and returned as DOUBLE so averages of integers are not truncated
+func aggregateExpression(fn string, field string) string {
+	quoted := sql_builder.QuoteIdentifier(field)
+	switch fn {
+	case AggregationCount:
+		if field == "" {
+			return "COUNT(*)"
+		}
+		return fmt.Sprintf("COUNT(%v)", quoted)
+	case AggregationCountDistinct:
+		return fmt.Sprintf("COUNT(DISTINCT %v)", quoted)
+	default:
+		return fmt.Sprintf("%v(CAST(%v AS DOUBLE))", strings.ToUpper(fn), quoted)
+	}
+}
+
+// aggregateType returns the type of the result of an aggregate function
+func aggregateType(fn string) string {
+	switch fn {
+	case AggregationCount, AggregationCountDistinct:
+		return data_type.BIGINT()
+	default:
+		return data_type.DOUBLE()
+	}
+}
+
+// AlertColumns returns the columns of the alerts: the group columns, the window bounds, the aggregate and the typed
+// alert metadata. The alert sink is declared from it and the aggregation job selects it by name from the aggregates
+func (c *AggregationJobConfig) AlertColumns(ruleID string) []AlertColumn {
+	var columns []AlertColumn
+	schema := c.SourceConfig.Columns()
+	for _, f := range c.GroupBy {
+		columns = append(columns, AlertColumn{Name: f, Type: schema[f], Expression: sql_builder.QuoteIdentifier(f)})
+	}
+	for _, f := range aggregationWindowColumns {
+		columns = append(columns, AlertColumn{Name: f, Type: data_type.TIMESTAMP_PRECISION(3), Expression: f})
+	}
+	columns = append(columns,
+		AlertColumn{Name: AggregateValueField, Type: c.AggregateType(), Expression: sql_builder.QuoteIdentifier(AggregateValueField)},
+		AlertColumn{Name: "alert_id", Type: data_type.STRING(), Expression: "UUID()"},
+		AlertColumn{Name: "alert_time", Type: data_type.TIMESTAMP_LTZ_PRECISION(3), Expression: "CURRENT_ROW_TIMESTAMP()"},
+	)
+	return append(columns, ruleMetadataColumns(ruleID, c.Name, c.Technique, c.Tactic, c.Severity, c.riskScoreExpression(), c.objectExpression())...)
+}
+
+// riskScoreExpression renders the risk score of an alert, see riskScoreExpression
+func (c *AggregationJobConfig) riskScoreExpression() string {
+	return riskScoreExpression(c.Severity, c.RiskScore, c.RiskScoreFactors, sql_builder.QuoteIdentifier)
+}
+
+// objectExpression renders the object of an alert, the fixed label or the value of the object column
+func (c *AggregationJobConfig) objectExpression() string {
+	if c.ObjectLabel != "" {
+		return sql_builder.QuoteLiteral(c.ObjectLabel)
+	}
+	return fmt.Sprintf("CAST(%v AS STRING)", sql_builder.QuoteIdentifier(c.Object))
+}
+
+// Validate checks the aggregation job config against its source schema without contacting Flink
+func (c *AggregationJobConfig) Validate() error {
+	v := &validator{}
+	if c.ID == "" {
+		v.addf("id is required")
+	}
+	validateNoSecretPlaceholders(v, c)
+	validateAttack(v, c.Technique, c.Tactic)
+	validateKafkaConfig(v, "source_config", c.SourceConfig, true)
+	var schema map[string]string
+	if c.SourceConfig != nil {
+		schema = c.SourceConfig.Columns()
+		validateEventTime(v, "source_config", c.SourceConfig)
+	}
+	group := make(map[string]string, len(c.GroupBy))
+	if len(c.GroupBy) == 0 {
+		v.addf("group_by is required")
+	}
+	for i, f := range c.GroupBy {
+		if _, ok := group[f]; ok {
+			v.addf("group_by[%v]: duplicate field '%v'", i, f)
+			continue
+		}
+		group[f] = schema[f]
+		if schema == nil {
+			continue
+		}
+		typeStr, ok := schema[f]
+		if !ok {
+			v.addf("group_by[%v]: field '%v' is not in the schema", i, f)
+		} else if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("group_by[%v]: field '%v' has complex type %v", i, f, t)
+		}
+	}
+	for _, col := range append(append([]string{AggregateValueField}, aggregationWindowColumns...), alertMetadataColumns...) {
+		if _, ok := group[col]; ok {
+			v.addf("group_by: column '%v' is reserved for the alert metadata", col)
+		}
+	}
+	validateSink(v, "rule_output_config", c.RuleOutput, group, false)
+	validateSeverity(v, c.Severity)
+	aggregates := map[string]string{AggregateValueField: c.AggregateType()}
+	for f, typeStr := range group {
+		aggregates[f] = typeStr
+	}
+	validateRiskScore(v, c.RiskScore, c.RiskScoreFactors, aggregates)
+	switch {
+	case c.Object == "" && c.ObjectLabel == "":
+		v.addf("object or object_label is required")
+	case c.Object != "" && c.ObjectLabel != "":
+		v.addf("object and object_label are exclusive, object names a column and object_label is a fixed label")
+	case c.Object != "":
+		if _, ok := group[c.Object]; !ok {
+			v.addf("object '%v' must be one of group_by, the other columns are not kept in the aggregates", c.Object)
+		}
+	}
+	validateAggregate(v, c, schema)
+	window, err := c.GetWindow()
+	if err != nil || window <= 0 {
+		v.addf("window '%v' is not a valid duration", c.Window)
+	}
+	if slide, err := c.GetSlide(); err != nil || slide < 0 {
+		v.addf("slide '%v' is not a valid duration", c.Slide)
+	} else if window > 0 && slide > 0 && (slide > window || window%slide != 0) {
+		v.addf("slide '%v' must divide window '%v'", c.Slide, c.Window)
+	}
+	if c.Filter != "" && !c.RawFilter && c.SourceConfig != nil {
+		if _, err := filter.NewCompiler(c.FilterSchema()).Compile(c.Filter); err != nil {
+			v.addf("filter: %v", err)
+		}
+	}
+	return v.err()
+}
+
+func validateAggregate(v *validator, c *AggregationJobConfig, schema map[string]string) {
+	if _, ok := aggregationOperators[c.GetOperator()]; !ok {
+		v.addf("operator '%v' is not supported, use one of >, >=, <, <=, = or !=", c.Operator)
+	}
+	validateAggregateFunction(v, "function", c.Function, "field", c.Field, schema)
+}
+
+// validateAggregateFunction checks an aggregate function is supported and its field suits it, the field is only
+// optional for count
+func validateAggregateFunction(v *validator, fnName string, function string, fieldName string, field string, schema map[string]string) {
+	fn := strings.ToLower(function)
+	if !util.NewStringSetFrom(aggregationFunctions...).Has(fn) {
+		v.addf("%v '%v' is not supported, use one of %v", fnName, function, strings.Join(aggregationFunctions, ", "))
+		return
+	}
+	if field == "" {
+		if fn != AggregationCount {
+			v.addf("%v is required for function %v", fieldName, fn)
+		}
+		return
+	}
+	if schema == nil {
+		return
+	}
+	typeStr, ok := schema[field]
+	if !ok {
+		v.addf("%v '%v' is not in the schema", fieldName, field)
+		return
+	}
+	t, err := data_type.Parse(typeStr)
+	if err != nil {
+		return
+	}
+	switch fn {
+	case AggregationCount, AggregationCountDistinct:
+		if t.IsComplex() {
+			v.addf("%v '%v' has complex type %v", fieldName, field, t)
+		}
+	default:
+		if !t.IsNumeric() {
+			v.addf("%v '%v' must be numeric for function %v but is %v", fieldName, field, fn, t)
+		}
+	}
+}
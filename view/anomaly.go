@@ -0,0 +1,168 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/util"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	AnomalyThreshold = "threshold"
+	AnomalyBaseline  = "baseline"
+
+	defaultAnomalyMetric     = "cnt"
+	defaultAnomalyDeviations = 3
+	defaultAnomalyWindows    = 10
+	defaultAnomalyMinWindows = 3
+)
+
+// profileAggregateNameRegexp matches the names of the aggregates of the profile windows
+var profileAggregateNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// profileWindowColumns are the columns of the profile windows, which the aggregates cannot be named after
+var profileWindowColumns = []string{"window_start", "window_end", "window_time", defaultAnomalyMetric, "entities", "attributes"}
+
+// HasAnomaly reports whether the behavior job detects anomalies in its profile windows
+func (c *BehaviorJobConfig) HasAnomaly() bool {
+	return c.ProfileConfig != nil && c.ProfileConfig.Anomaly != nil
+}
+
+// Metrics returns the columns of the profile windows anomalies can be detected on: cnt and the aggregates
+func (c *ProfileConfig) Metrics() []string {
+	metrics := []string{defaultAnomalyMetric}
+	for _, agg := range c.Aggregates {
+		if agg != nil {
+			metrics = append(metrics, agg.Name)
+		}
+	}
+	return metrics
+}
+
+// Expression renders the aggregate of the behaviors of a window, see aggregateExpression
+func (c *profileAggregate) Expression() string {
+	return aggregateExpression(strings.ToLower(c.Function), c.Field)
+}
+
+// GetMode returns how windows are compared, to the threshold by default
+func (c *anomalyConfig) GetMode() string {
+	if c.Mode == "" {
+		return AnomalyThreshold
+	}
+	return strings.ToLower(c.Mode)
+}
+
+// GetMetric returns the profile column compared, cnt by default
+func (c *anomalyConfig) GetMetric() string {
+	if c.Metric == "" {
+		return defaultAnomalyMetric
+	}
+	return c.Metric
+}
+
+// GetDeviations returns the number of standard deviations above the baseline mean that is anomalous, 3 by default
+func (c *anomalyConfig) GetDeviations() float64 {
+	if c.Deviations == 0 {
+		return defaultAnomalyDeviations
+	}
+	return c.Deviations
+}
+
+// GetWindows returns the number of previous windows of the baseline, 10 by default
+func (c *anomalyConfig) GetWindows() int {
+	if c.Windows == 0 {
+		return defaultAnomalyWindows
+	}
+	return c.Windows
+}
+
+// GetMinWindows returns the number of previous windows a baseline needs, 3 or the number of windows when fewer
+func (c *anomalyConfig) GetMinWindows() int {
+	if c.MinWindows != 0 {
+		return c.MinWindows
+	}
+	if c.GetWindows() < defaultAnomalyMinWindows {
+		return c.GetWindows()
+	}
+	return defaultAnomalyMinWindows
+}
+
+// AnomalyColumns returns the columns of the anomalies. The anomaly sink is declared from it and the behavior job
+// selects it by name from the anomaly view, which computes value, expected and the baseline columns
+func (c *BehaviorJobConfig) AnomalyColumns(behaviorID string) []AlertColumn {
+	anomaly := c.ProfileConfig.Anomaly
+	column := func(name, typ string) AlertColumn {
+		return AlertColumn{Name: name, Type: typ, Expression: sql_builder.QuoteIdentifier(name)}
+	}
+	return []AlertColumn{
+		{Name: "anomaly_id", Type: data_type.STRING(), Expression: "UUID()"},
+		{Name: "behavior_id", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(behaviorID)},
+		{Name: "mode", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(anomaly.GetMode())},
+		{Name: "metric", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(anomaly.GetMetric())},
+		column("window_start", data_type.TIMESTAMP_PRECISION(3)),
+		column("window_end", data_type.TIMESTAMP_PRECISION(3)),
+		column("entities", data_type.STRING()),
+		column("attributes", data_type.STRING()),
+		column("value", data_type.DOUBLE()),
+		column("expected", data_type.DOUBLE()),
+		column("baseline_mean", data_type.DOUBLE()),
+		column("baseline_stddev", data_type.DOUBLE()),
+		column("baseline_windows", data_type.BIGINT()),
+	}
+}
+
+func validateAnomaly(v *validator, c *BehaviorJobConfig) {
+	if !c.HasAnomaly() {
+		if c.AnomalyOutput != nil {
+			v.addf("anomaly_output_config is only used with profile_config.anomaly")
+		}
+		return
+	}
+	anomaly := c.ProfileConfig.Anomaly
+	metrics := c.ProfileConfig.Metrics()
+	if !util.NewStringSetFrom(metrics...).Has(anomaly.GetMetric()) {
+		v.addf("profile_config.anomaly.metric '%v' is not a profile metric, use cnt or one of profile_config.aggregates: %v",
+			anomaly.GetMetric(), strings.Join(metrics, ", "))
+	}
+	switch mode := anomaly.GetMode(); mode {
+	case AnomalyThreshold:
+		if c.ProfileConfig.Threshold <= 0 {
+			v.addf("profile_config.threshold must be positive for the threshold anomaly mode")
+		}
+	case AnomalyBaseline:
+		if anomaly.GetDeviations() < 0 {
+			v.addf("profile_config.anomaly.deviations must not be negative")
+		}
+		if anomaly.GetWindows() < 1 {
+			v.addf("profile_config.anomaly.windows must be positive")
+		}
+		if minWindows := anomaly.GetMinWindows(); minWindows < 1 || minWindows > anomaly.GetWindows() {
+			v.addf("profile_config.anomaly.min_windows must be between 1 and windows (%v)", anomaly.GetWindows())
+		}
+	default:
+		v.addf("profile_config.anomaly.mode '%v' is not supported, use %v or %v", mode, AnomalyThreshold, AnomalyBaseline)
+	}
+	validateSink(v, "anomaly_output_config", c.AnomalyOutput, nil, false)
+}
+
+// validateProfileAggregates checks the aggregates of the profile windows against the behavior columns
+func validateProfileAggregates(v *validator, c *ProfileConfig, schema map[string]string) {
+	names := util.NewStringSetFrom(profileWindowColumns...)
+	for i, agg := range c.Aggregates {
+		name := fmt.Sprintf("profile_config.aggregates[%v]", i)
+		if agg == nil {
+			v.addf("%v is empty", name)
+			continue
+		}
+		switch {
+		case !profileAggregateNameRegexp.MatchString(agg.Name):
+			v.addf("%v.name '%v' must be a column name of letters, digits and underscores", name, agg.Name)
+		case names.Has(agg.Name):
+			v.addf("%v.name '%v' is already a column of the profile windows", name, agg.Name)
+		}
+		names.Add(agg.Name)
+		validateAggregateFunction(v, name+".function", agg.Function, name+".field", agg.Field, schema)
+	}
+}
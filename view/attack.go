@@ -0,0 +1,55 @@
+package view
+
+import (
+	"flink_ueba_manager/attack"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/util"
+	"strings"
+)
+
+// attackColumns returns the ATT&CK metadata of the technique of an alert: its tactic, the tactics of the technique
+// joined by commas when the rule does not name one, the name of the technique and the name of the sub-technique.
+// The columns are NULL when the rule has no technique
+func attackColumns(technique string, tactic string) []AlertColumn {
+	catalog := attack.Current()
+	if tactic == "" {
+		if t, ok := catalog.Technique(technique); ok {
+			tactic = strings.Join(t.Tactics, ",")
+		}
+	}
+	return []AlertColumn{
+		{Name: "tactic", Type: data_type.STRING(), Expression: nullableLiteral(tactic)},
+		{Name: "technique_name", Type: data_type.STRING(), Expression: nullableLiteral(catalog.TechniqueName(technique))},
+		{Name: "sub_technique", Type: data_type.STRING(), Expression: nullableLiteral(catalog.SubTechniqueName(technique))},
+	}
+}
+
+func nullableLiteral(value string) string {
+	if value == "" {
+		return "CAST(NULL AS STRING)"
+	}
+	return sql_builder.QuoteLiteral(value)
+}
+
+// validateAttack checks the technique of a rule is in the ATT&CK catalog and its tactic is one of the technique
+func validateAttack(v *validator, technique string, tactic string) {
+	if technique == "" {
+		if tactic != "" {
+			v.addf("tactic requires a technique")
+		}
+		return
+	}
+	if !attack.IsTechniqueID(technique) {
+		v.addf("technique '%v' is not an ATT&CK technique ID such as T1078 or T1078.004", technique)
+		return
+	}
+	t, ok := attack.Current().Technique(technique)
+	if !ok {
+		v.addf("technique '%v' is not in the ATT&CK catalog", technique)
+		return
+	}
+	if tactic != "" && !util.NewStringSetFrom(t.Tactics...).Has(tactic) {
+		v.addf("tactic '%v' is not a tactic of technique %v, use one of %v", tactic, technique, strings.Join(t.Tactics, ", "))
+	}
+}
@@ -0,0 +1,76 @@
+package view
+
+import (
+	"flink_ueba_manager/util"
+	"time"
+)
+
+const (
+	defaultBacktestLookback   = 7 * 24 * time.Hour
+	defaultBacktestSampleSize = 10
+	defaultBacktestTimeout    = 10 * time.Minute
+	// MaxBacktestSampleSize bounds the samples of a backtest, they are all returned in the response
+	MaxBacktestSampleSize = 1000
+)
+
+// GetRange returns the range of the replayed records, ending now and starting 7 days before its end by default
+func (r *RuleBacktestRequest) GetRange() (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if r.To != "" {
+		t, err := time.Parse(time.RFC3339, r.To)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+	from := to.Add(-defaultBacktestLookback)
+	if r.From != "" {
+		t, err := time.Parse(time.RFC3339, r.From)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+	return from, to, nil
+}
+
+func (r *RuleBacktestRequest) GetSampleSize() int {
+	if r.SampleSize == 0 {
+		return defaultBacktestSampleSize
+	}
+	return r.SampleSize
+}
+
+func (r *RuleBacktestRequest) GetTimeout() (time.Duration, error) {
+	if r.Timeout == "" {
+		return defaultBacktestTimeout, nil
+	}
+	return util.ParseDurationExtended(r.Timeout)
+}
+
+// Validate checks the range and options of a backtest and that its rule can be replayed in batch mode. The rule
+// itself is validated like a deployed rule
+func (r *RuleBacktestRequest) Validate() error {
+	v := &validator{}
+	if r.Rule == nil {
+		v.addf("rule or rule_id is required")
+	} else if r.Rule.HasSuppression() && r.Rule.SuppressionTimeField() != EventTimeField {
+		v.addf("rule: alerts suppressed on processing time cannot be replayed, set alert_time.source to %v", AlertTimeEvent)
+	}
+	if from, to, err := r.GetRange(); err != nil {
+		v.addf("from and to must be RFC 3339 times: %v", err)
+	} else if !from.Before(to) {
+		v.addf("from %v must be before to %v", r.From, r.To)
+	} else if to.After(time.Now()) {
+		v.addf("to %v must not be in the future, the backtest would wait for it", r.To)
+	}
+	if size := r.GetSampleSize(); size < 0 || size > MaxBacktestSampleSize {
+		v.addf("sample_size %v must be between 0 and %v", size, MaxBacktestSampleSize)
+	}
+	if timeout, err := r.GetTimeout(); err != nil {
+		v.addf("timeout: %v", err)
+	} else if timeout <= 0 {
+		v.addf("timeout must be positive")
+	}
+	return v.err()
+}
@@ -0,0 +1,99 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/util"
+)
+
+// Validate checks the behavior job config against its log source schema without contacting Flink
+func (c *BehaviorJobConfig) Validate() error {
+	v := &validator{}
+	if c.ID == "" {
+		v.addf("id is required")
+	}
+	validateNoSecretPlaceholders(v, c)
+	validateKafkaConfig(v, "source_config", c.LogSourceConfig, true)
+	var sourceColumns map[string]string
+	if c.LogSourceConfig != nil {
+		sourceColumns = c.FilterSchema()
+	}
+	validateSink(v, "behavior_output_config", c.BehaviorOutput, sourceColumns, false)
+	validateSink(v, "profile_output_config", c.ProfileOutput, nil, true)
+	if c.LogSourceConfig != nil {
+		validateEventTime(v, "source_config", c.LogSourceConfig)
+	}
+	if c.ProfileConfig == nil {
+		v.addf("profile_config is required")
+	} else {
+		validateObjects(v, "profile_config.entity", c.ProfileConfig.Entities, c.sourceSchema())
+		validateObjects(v, "profile_config.attribute", c.ProfileConfig.Attributes, c.sourceSchema())
+		if c.ProfileConfig.SavingDuration <= 0 {
+			v.addf("profile_config.saving_duration_minute must be positive")
+		}
+		validateResolvedObjects(v, c, sourceColumns)
+		validateProfileAggregates(v, c.ProfileConfig, c.profileSchema(sourceColumns))
+	}
+	validateAnomaly(v, c)
+	if !c.RawFilter && c.LogSourceConfig != nil {
+		if _, err := filter.NewCompiler(c.FilterSchema()).Compile(c.BehaviorFilter); err != nil {
+			v.addf("filter: %v", err)
+		}
+	}
+	return v.err()
+}
+
+// FilterSchema returns the columns a behavior filter can reference: the log source columns and the event time
+func (c *BehaviorJobConfig) FilterSchema() map[string]string {
+	schema := c.LogSourceConfig.Columns()
+	schema[EventTimeField] = c.LogSourceConfig.EventTime().DataType()
+	return schema
+}
+
+func (c *BehaviorJobConfig) sourceSchema() map[string]string {
+	if c.LogSourceConfig == nil {
+		return nil
+	}
+	return c.LogSourceConfig.Columns()
+}
+
+// profileSchema returns the behavior columns the profile windows aggregate: the source columns and the resolved
+// identities, nil when the source is not configured
+func (c *BehaviorJobConfig) profileSchema(sourceColumns map[string]string) map[string]string {
+	if sourceColumns == nil {
+		return nil
+	}
+	schema := make(map[string]string, len(sourceColumns))
+	for name, typ := range sourceColumns {
+		schema[name] = typ
+	}
+	for _, obj := range c.ResolvedObjects() {
+		schema[obj.ProfileField()] = data_type.STRING()
+	}
+	return schema
+}
+
+// validateResolvedObjects checks the columns added for the resolved identities do not clash with the source columns
+func validateResolvedObjects(v *validator, c *BehaviorJobConfig, sourceColumns map[string]string) {
+	resolved := util.NewStringSet()
+	for _, obj := range append(append([]*Object{}, c.ProfileConfig.Entities...), c.ProfileConfig.Attributes...) {
+		if obj == nil || !obj.IsResolved() {
+			continue
+		}
+		if resolved.Has(obj.Name) {
+			v.addf("profile_config: field '%v' is resolved more than once", obj.Name)
+		}
+		resolved.Add(obj.Name)
+		if _, ok := sourceColumns[obj.ProfileField()]; ok {
+			v.addf("profile_config: column '%v' of the resolved field '%v' clashes with a source column", obj.ProfileField(), obj.Name)
+		}
+	}
+	if _, ok := sourceColumns[LookupProcTimeField]; ok && c.HasLookup() {
+		v.addf("source_config: column '%v' is reserved for the lookup of jdbc reference tables", LookupProcTimeField)
+	}
+	if c.LogSourceConfig != nil && c.LogSourceConfig.IdleTimeout != "" && c.HasVersionedReference() {
+		if d, err := c.LogSourceConfig.GetIdleTimeout(); err == nil && d == 0 {
+			v.addf("source_config.idle_timeout must be positive to join an upsert-kafka reference table, it is one minute by default")
+		}
+	}
+}
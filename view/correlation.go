@@ -0,0 +1,195 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/util"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DeriveSchemas fills the source schema from the schema registry when the source asks for it
+func (c *CorrelationJobConfig) DeriveSchemas(fetch SchemaFetcher) error {
+	return c.SourceConfig.deriveSchema("source_config", fetch)
+}
+
+// GetWithin returns the time bound of a sequence, zero when the sequence is not bounded
+func (c *CorrelationJobConfig) GetWithin() (time.Duration, error) {
+	if c.Within == "" {
+		return 0, nil
+	}
+	return util.ParseDurationExtended(c.Within)
+}
+
+// CountColumn returns the column holding the number of events matched by the step
+func (s *correlationStep) CountColumn() string {
+	return s.Name + "_count"
+}
+
+// Measures returns the columns computed over the events of a sequence: the event times of its first and last
+// events and the number of events matched by each step
+func (c *CorrelationJobConfig) Measures() []AlertColumn {
+	eventTime := c.SourceConfig.EventTime().DataType()
+	first, last := c.Steps[0], c.Steps[len(c.Steps)-1]
+	measures := []AlertColumn{
+		{Name: "match_start", Type: eventTime, Expression: fmt.Sprintf("FIRST(%v.%v)", first.Name, sql_builder.QuoteIdentifier(EventTimeField))},
+		{Name: "match_end", Type: eventTime, Expression: fmt.Sprintf("LAST(%v.%v)", last.Name, sql_builder.QuoteIdentifier(EventTimeField))},
+	}
+	for _, step := range c.Steps {
+		measures = append(measures, AlertColumn{
+			Name:       step.CountColumn(),
+			Type:       data_type.BIGINT(),
+			Expression: fmt.Sprintf("COUNT(%v.%v)", step.Name, sql_builder.QuoteIdentifier(EventTimeField)),
+		})
+	}
+	return measures
+}
+
+// AlertColumns returns the columns of the alerts: the partition columns, the measures and the typed alert
+// metadata. The alert sink is declared from it and the correlation job selects it by name from the matches
+func (c *CorrelationJobConfig) AlertColumns(ruleID string) []AlertColumn {
+	var columns []AlertColumn
+	schema := c.SourceConfig.Columns()
+	for _, f := range c.PartitionBy {
+		columns = append(columns, AlertColumn{Name: f, Type: schema[f], Expression: sql_builder.QuoteIdentifier(f)})
+	}
+	for _, m := range c.Measures() {
+		columns = append(columns, AlertColumn{Name: m.Name, Type: m.Type, Expression: sql_builder.QuoteIdentifier(m.Name)})
+	}
+	columns = append(columns,
+		AlertColumn{Name: "alert_id", Type: data_type.STRING(), Expression: "UUID()"},
+		AlertColumn{Name: "alert_time", Type: data_type.TIMESTAMP_LTZ_PRECISION(3), Expression: "CURRENT_ROW_TIMESTAMP()"},
+	)
+	return append(columns, ruleMetadataColumns(ruleID, c.Name, c.Technique, c.Tactic, c.Severity, c.riskScoreExpression(), c.objectExpression())...)
+}
+
+// riskScoreExpression renders the risk score of an alert, see riskScoreExpression
+func (c *CorrelationJobConfig) riskScoreExpression() string {
+	return riskScoreExpression(c.Severity, c.RiskScore, c.RiskScoreFactors, sql_builder.QuoteIdentifier)
+}
+
+// objectExpression renders the object of an alert, the fixed label or the value of the object column
+func (c *CorrelationJobConfig) objectExpression() string {
+	if c.ObjectLabel != "" {
+		return sql_builder.QuoteLiteral(c.ObjectLabel)
+	}
+	return fmt.Sprintf("CAST(%v AS STRING)", sql_builder.QuoteIdentifier(c.Object))
+}
+
+// Validate checks the correlation job config against its source schema without contacting Flink
+func (c *CorrelationJobConfig) Validate() error {
+	v := &validator{}
+	if c.ID == "" {
+		v.addf("id is required")
+	}
+	validateNoSecretPlaceholders(v, c)
+	validateAttack(v, c.Technique, c.Tactic)
+	validateKafkaConfig(v, "source_config", c.SourceConfig, true)
+	var schema map[string]string
+	if c.SourceConfig != nil {
+		schema = c.SourceConfig.Columns()
+		validateEventTime(v, "source_config", c.SourceConfig)
+	}
+	partition := make(map[string]string, len(c.PartitionBy))
+	if len(c.PartitionBy) == 0 {
+		v.addf("partition_by is required")
+	}
+	for i, f := range c.PartitionBy {
+		if _, ok := partition[f]; ok {
+			v.addf("partition_by[%v]: duplicate field '%v'", i, f)
+			continue
+		}
+		partition[f] = schema[f]
+		if schema == nil {
+			continue
+		}
+		typeStr, ok := schema[f]
+		if !ok {
+			v.addf("partition_by[%v]: field '%v' is not in the schema", i, f)
+		} else if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("partition_by[%v]: field '%v' has complex type %v", i, f, t)
+		}
+	}
+	validateSink(v, "rule_output_config", c.RuleOutput, partition, false)
+	validateSeverity(v, c.Severity)
+	validateRiskScore(v, c.RiskScore, c.RiskScoreFactors, partition)
+	switch {
+	case c.Object == "" && c.ObjectLabel == "":
+		v.addf("object or object_label is required")
+	case c.Object != "" && c.ObjectLabel != "":
+		v.addf("object and object_label are exclusive, object names a column and object_label is a fixed label")
+	case c.Object != "":
+		if _, ok := partition[c.Object]; !ok {
+			v.addf("object '%v' must be one of partition_by, the other columns are not kept in the matches", c.Object)
+		}
+	}
+	if d, err := c.GetWithin(); err != nil || d < 0 {
+		v.addf("within '%v' is not a valid duration", c.Within)
+	}
+	validateCorrelationSteps(v, c, schema, partition)
+	return v.err()
+}
+
+var (
+	stepNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+	// quantifierRegexp matches the MATCH_RECOGNIZE quantifiers, optionally reluctant
+	quantifierRegexp      = regexp.MustCompile(`^(\{\d+\}|\{\d+,\d*\}|\+|\*|\?)?\??$`)
+	exactQuantifierRegexp = regexp.MustCompile(`^(\{\d+\})?$`)
+)
+
+func validateCorrelationSteps(v *validator, c *CorrelationJobConfig, schema map[string]string, partition map[string]string) {
+	if len(c.Steps) == 0 {
+		v.addf("steps is required")
+		return
+	}
+	filterSchema := make(map[string]string, len(schema)+1)
+	for col, typeStr := range schema {
+		filterSchema[col] = typeStr
+	}
+	if c.SourceConfig != nil {
+		filterSchema[EventTimeField] = c.SourceConfig.EventTime().DataType()
+	}
+	names := util.NewStringSet()
+	hasCondition := false
+	for i, step := range c.Steps {
+		name := fmt.Sprintf("steps[%v]", i)
+		if step == nil {
+			v.addf("%v is required", name)
+			continue
+		}
+		if !stepNameRegexp.MatchString(step.Name) {
+			v.addf("%v.name '%v' must start with a letter and contain only letters, digits and underscores", name, step.Name)
+		} else if names.Has(strings.ToLower(step.Name)) {
+			v.addf("%v.name: duplicate step '%v'", name, step.Name)
+		}
+		names.Add(strings.ToLower(step.Name))
+		if _, ok := partition[step.CountColumn()]; ok {
+			v.addf("%v.name: column '%v' of the step clashes with partition_by", name, step.CountColumn())
+		}
+		if !quantifierRegexp.MatchString(step.Quantifier) {
+			v.addf("%v.quantifier '%v' is not supported", name, step.Quantifier)
+		} else if i == len(c.Steps)-1 && !exactQuantifierRegexp.MatchString(step.Quantifier) &&
+			!(len(step.Quantifier) > 1 && strings.HasSuffix(step.Quantifier, "?")) {
+			v.addf("%v.quantifier '%v': the last step cannot be greedy, append ? to make it reluctant", name, step.Quantifier)
+		}
+		if step.Filter != "" {
+			hasCondition = true
+			if !step.RawFilter && schema != nil {
+				if _, err := filter.NewCompiler(filterSchema).Compile(step.Filter); err != nil {
+					v.addf("%v.filter: %v", name, err)
+				}
+			}
+		}
+	}
+	if !hasCondition {
+		v.addf("steps: at least one step needs a filter")
+	}
+	for _, col := range append([]string{"match_start", "match_end"}, alertMetadataColumns...) {
+		if _, ok := partition[col]; ok {
+			v.addf("partition_by: column '%v' is reserved for the alert metadata", col)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SchemaFetcher returns the latest Avro schema of a registry subject, userInfo is an optional secret reference
+type SchemaFetcher func(registryURL, subject, userInfo string) (string, error)
+
+// GetFormat returns the format of the records, json by default
+func (c *kafkaConfig) GetFormat() string {
+	return c.Format.GetType()
+}
+
+// FormatBuilder returns the format with its options. Sources skip malformed json and csv records
+// unless ignore_parse_errors is disabled
+func (c *kafkaConfig) FormatBuilder(source bool) sql_builder.FormatBuilder {
+	return c.Format.builder(source)
+}
+
+// GetType returns the type of the format, json by default
+func (f *formatConfig) GetType() string {
+	if f == nil || f.Type == "" {
+		return sql_builder.FormatJSON
+	}
+	return strings.ToLower(f.Type)
+}
+
+func (f *formatConfig) builder(source bool) sql_builder.FormatBuilder {
+	formatType := f.GetType()
+	if f == nil {
+		f = &formatConfig{}
+	}
+	ignoreParseErrors := source && (f.IgnoreParseErrors == nil || *f.IgnoreParseErrors)
+	switch formatType {
+	case sql_builder.FormatAvro:
+		return sql_builder.NewAvroFormatBuilder()
+	case sql_builder.FormatAvroConfluent:
+		builder := sql_builder.NewAvroConfluentFormatBuilder(f.RegistryURL)
+		if f.Subject != "" {
+			builder.WithSubject(f.Subject)
+		}
+		if f.RegistryUserInfo != "" {
+			builder.WithBasicAuthUserInfo(secretPlaceholder(f.RegistryUserInfo))
+		}
+		return builder
+	case sql_builder.FormatCSV:
+		builder := sql_builder.NewCSVFormatBuilder()
+		if f.FieldDelimiter != "" {
+			builder.WithFieldDelimiter(f.FieldDelimiter)
+		}
+		if f.DisableQuoteCharacter {
+			builder.WithDisableQuoteCharacter(true)
+		} else if f.QuoteCharacter != "" {
+			builder.WithQuoteCharacter(f.QuoteCharacter)
+		}
+		if f.AllowComments {
+			builder.WithAllowComments(true)
+		}
+		if ignoreParseErrors {
+			builder.WithIgnoreParseErrors(true)
+		}
+		if f.ArrayElementDelimiter != "" {
+			builder.WithArrayElementDelimiter(f.ArrayElementDelimiter)
+		}
+		if f.NullLiteral != "" {
+			builder.WithNullLiteral(f.NullLiteral)
+		}
+		return builder
+	case sql_builder.FormatRaw:
+		builder := sql_builder.NewRawFormatBuilder()
+		if f.Charset != "" {
+			builder.WithCharset(f.Charset)
+		}
+		if f.Endianness != "" {
+			builder.WithEndianness(f.Endianness)
+		}
+		return builder
+	default:
+		builder := sql_builder.NewJSONFormatBuilder()
+		standard := f.TimestampStandard
+		if standard == "" && source {
+			standard = sql_builder.JSONTimestampFormatISO8601
+		}
+		if standard != "" {
+			builder.WithTimestampFormat(strings.ToUpper(standard))
+		}
+		if source {
+			builder.
+				WithIgnoreParseErrors(ignoreParseErrors).
+				WithFailOnMissingField(f.FailOnMissingField)
+		}
+		return builder
+	}
+}
+
+// OrderedSchema returns the columns and types of the schema, in the order of the csv fields or else by name
+func (c *kafkaConfig) OrderedSchema() [][]string {
+	var arr [][]string
+	if c.GetFormat() == sql_builder.FormatCSV && len(c.Format.Columns) > 0 {
+		for _, col := range c.Format.Columns {
+			arr = append(arr, []string{col, c.Schema[col]})
+		}
+		return arr
+	}
+	for k, v := range c.Schema {
+		arr = append(arr, []string{k, v})
+	}
+	sort.Slice(arr, func(i, j int) bool {
+		return arr[i][0] < arr[j][0]
+	})
+	return arr
+}
+
+func (c *kafkaConfig) registrySubject() string {
+	if c.Format.Subject != "" {
+		return c.Format.Subject
+	}
+	return c.Topic + "-value"
+}
+
+// deriveSchema fills the schema of an avro-confluent source from the latest schema of its subject
+func (c *kafkaConfig) deriveSchema(name string, fetch SchemaFetcher) error {
+	if c == nil || c.GetFormat() != sql_builder.FormatAvroConfluent || !c.Format.DeriveSchema {
+		return nil
+	}
+	schema, err := fetch(c.Format.RegistryURL, c.registrySubject(), c.Format.RegistryUserInfo)
+	if err != nil {
+		return fmt.Errorf("%v: cannot derive the schema of subject %v: %v", name, c.registrySubject(), err)
+	}
+	fields, err := data_type.FromAvroSchema(schema)
+	if err != nil {
+		return fmt.Errorf("%v: cannot derive the schema of subject %v: %v", name, c.registrySubject(), err)
+	}
+	c.Schema = make(map[string]string, len(fields))
+	for _, f := range fields {
+		c.Schema[f.Name] = f.Type.String()
+	}
+	return nil
+}
+
+// DeriveSchemas fills the source schema from the schema registry when the source asks for it
+func (c *BehaviorJobConfig) DeriveSchemas(fetch SchemaFetcher) error {
+	return c.LogSourceConfig.deriveSchema("source_config", fetch)
+}
+
+// DeriveSchemas fills the source schema from the schema registry when the source asks for it
+func (c *RuleJobConfig) DeriveSchemas(fetch SchemaFetcher) error {
+	return c.ProfilePredictorOutput.deriveSchema("profile_predictor_config", fetch)
+}
+
+// validateFormat checks the format options of a source or an output against its schema
+func validateFormat(v *validator, name string, f *formatConfig, schema map[string]string, source bool) {
+	if f == nil {
+		return
+	}
+	switch format := f.GetType(); format {
+	case sql_builder.FormatJSON:
+		switch strings.ToUpper(f.TimestampStandard) {
+		case "", sql_builder.JSONTimestampFormatISO8601, sql_builder.JSONTimestampFormatSQL:
+		default:
+			v.addf("%v.timestamp_standard '%v' is not supported", name, f.TimestampStandard)
+		}
+	case sql_builder.FormatAvro:
+	case sql_builder.FormatAvroConfluent:
+		if f.RegistryURL == "" {
+			v.addf("%v.registry_url is required for the avro-confluent format", name)
+		} else if u, err := url.Parse(f.RegistryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.addf("%v.registry_url '%v' is not a valid http(s) URL", name, f.RegistryURL)
+		}
+		validateSecretRef(v, name+".registry_user_info", f.RegistryUserInfo, false)
+		if f.DeriveSchema && !source {
+			v.addf("%v.derive_schema is only supported on sources", name)
+		}
+	case sql_builder.FormatCSV:
+		if f.FieldDelimiter != "" && utf8.RuneCountInString(f.FieldDelimiter) != 1 {
+			v.addf("%v.field_delimiter must be a single character", name)
+		}
+		if f.QuoteCharacter != "" && utf8.RuneCountInString(f.QuoteCharacter) != 1 {
+			v.addf("%v.quote_character must be a single character", name)
+		}
+		if source {
+			validateCSVColumns(v, name, f.Columns, schema)
+		}
+	case sql_builder.FormatRaw:
+		switch f.Endianness {
+		case "", sql_builder.RawEndiannessBigEndian, sql_builder.RawEndiannessLittleEndian:
+		default:
+			v.addf("%v.endianness '%v' is not supported", name, f.Endianness)
+		}
+		if !source {
+			v.addf("%v: the raw format cannot be used for outputs, which have several columns", name)
+		} else if len(schema) != 1 {
+			v.addf("%v: the raw format requires a schema of exactly one column", name)
+		}
+	default:
+		v.addf("%v.type '%v' is not supported", name, format)
+	}
+}
+
+// validateCSVColumns checks the csv fields map every column of the schema, csv fields being matched by position
+func validateCSVColumns(v *validator, name string, columns []string, schema map[string]string) {
+	if len(columns) == 0 {
+		if len(schema) > 1 {
+			v.addf("%v.columns is required to order the csv fields of a schema with several columns", name)
+		}
+		return
+	}
+	seen := make(map[string]struct{}, len(columns))
+	for _, col := range columns {
+		if _, ok := schema[col]; !ok {
+			v.addf("%v.columns: '%v' is not in the schema", name, col)
+		}
+		if _, ok := seen[col]; ok {
+			v.addf("%v.columns: duplicate column '%v'", name, col)
+		}
+		seen[col] = struct{}{}
+	}
+	for _, col := range sortedKeys(schema) {
+		if _, ok := seen[col]; !ok {
+			v.addf("%v.columns: column '%v' of the schema is missing", name, col)
+		}
+	}
+}
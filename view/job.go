@@ -1,6 +1,11 @@
 package view
 
 type (
+	// JobConfig is a job pulled from the JobHub, its schemas are derived before it is validated
+	JobConfig interface {
+		DeriveSchemas(fetch SchemaFetcher) error
+		Validate() error
+	}
 	ProfileConfig struct {
 		ID             string    `json:"id"`
 		Name           string    `json:"name"`
@@ -11,20 +16,197 @@ type (
 		ProfileTime    string    `json:"profile_time"`
 		SavingDuration int64     `json:"saving_duration_minute"`
 		Threshold      float64   `json:"threshold"`
+		// Aggregates are the metrics of the profile windows besides cnt, which anomalies can be detected on. They
+		// are computed in the profile windows only, the profile output keeps its columns
+		Aggregates []*profileAggregate `json:"aggregates"`
+		// Anomaly enables the anomaly detection of the profile windows, written to anomaly_output_config
+		Anomaly *anomalyConfig `json:"anomaly"`
+	}
+	// profileAggregate is an aggregate of the behaviors of each profile window, such as the distinct ports
+	profileAggregate struct {
+		// Name is the column of the aggregate in the profile windows, such as distinct_ports
+		Name string `json:"name"`
+		// Function is the aggregate function: count, count_distinct, sum, avg, min or max
+		Function string `json:"function"`
+		// Field is the behavior column aggregated, count counts the behaviors when it is empty
+		Field string `json:"field"`
+	}
+	// anomalyConfig sets how the profile windows are compared to find anomalies
+	anomalyConfig struct {
+		// Mode is threshold, comparing each window to the threshold of the profile, or baseline, comparing it to
+		// the mean plus k standard deviations of the previous windows of the same entity and attribute
+		Mode string `json:"mode"`
+		// Metric is the profile column compared: cnt, the default, or the name of one of the aggregates
+		Metric string `json:"metric"`
+		// Deviations is k, the number of standard deviations above the mean that is anomalous, 3 by default
+		Deviations float64 `json:"deviations"`
+		// Windows is the number of previous windows the baseline is computed over, 10 by default
+		Windows int `json:"windows"`
+		// MinWindows is the number of previous windows needed before a baseline is trusted, 3 by default
+		MinWindows int `json:"min_windows"`
 	}
+	// Object is a profiled field. An original field is profiled as is, a mapping or reference field is profiled
+	// by the identity it resolves to, such as the employee ID of a username, in the <field_name>_resolved column
 	Object struct {
-		Name      string                 `json:"field_name" example:"server_id"`
+		Name string `json:"field_name" example:"server_id"`
+		// Type is original (default), mapping or reference
 		Type      string                 `json:"type" enums:"original,mapping,reference" example:"original"`
 		ExtraData map[string]interface{} `json:"extra_data"`
+		// Mapping declares the value map of a mapping field
+		Mapping *mappingConfig `json:"mapping"`
+		// Reference declares the reference table of a reference field
+		Reference *referenceConfig `json:"reference"`
+	}
+	// mappingConfig maps the values of a field, the values missing from the map keep their raw value
+	mappingConfig struct {
+		Values map[string]string `json:"values"`
+	}
+	// referenceConfig resolves a field through a reference table such as an asset inventory or an HR directory,
+	// the values without a matching row keep their raw value
+	referenceConfig struct {
+		// Table is the reference table and its schema: a jdbc table looked up at processing time, or an
+		// upsert-kafka topic joined as a versioned table at the event time of the behaviors. A versioned table
+		// holds back the behaviors until its watermark passes theirs, so the idle_timeout of the source, one
+		// minute by default, lets the join go on while the topic is not written to
+		Table *sinkConfig `json:"table"`
+		// Key is the column of the reference table matched against the field, its primary key. It has the type
+		// of the field
+		Key string `json:"key"`
+		// Value is the column of the reference table profiled instead of the field, such as employee_id
+		Value string `json:"value"`
+		// CacheTTL caches the rows looked up in a jdbc table for that long, e.g. 10m. Rows are not cached by default
+		CacheTTL string `json:"cache_ttl"`
+		// CacheMaxRows bounds the cache of a jdbc table, 10000 by default
+		CacheMaxRows int `json:"cache_max_rows"`
 	}
 	kafkaConfig struct {
-		BootstrapServer string            `json:"bootstrap.servers" binding:"required"`
-		Topic           string            `json:"topic" binding:"required"`
-		AuthenType      string            `json:"authen_type"`
-		Keytab          string            `json:"keytab"`
-		Principal       string            `json:"principal"`
-		Schema          map[string]string `json:"schema"`
-		TimestampField  string            `json:"timestamp_field"`
+		BootstrapServer string `json:"bootstrap.servers" binding:"required"`
+		Topic           string `json:"topic" binding:"required"`
+		// AuthenType is the SASL mechanism: kerberos, plain, scram-sha-256 or scram-sha-512. Empty disables SASL
+		AuthenType string `json:"authen_type"`
+		Keytab     string `json:"keytab"`
+		Principal  string `json:"principal"`
+		// KerberosServiceName is the Kerberos name of the brokers, kafka by default
+		KerberosServiceName string `json:"kerberos_service_name"`
+		Username            string `json:"username"`
+		// Password is a secret reference, env:NAME or file:PATH in the secrets directory, resolved when the job is
+		// deployed
+		Password string `json:"password"`
+		// SecurityProtocol overrides the protocol derived from AuthenType and SSL
+		SecurityProtocol string     `json:"security_protocol"`
+		SSL              *sslConfig `json:"ssl"`
+		// StartupMode is where a source starts reading: group-offsets (default), earliest, latest, timestamp
+		// or specific-offsets. With group-offsets a redeployed job resumes from the offsets committed by its group
+		// and only a new group starts from the earliest offset, so replaying a topic is opt-in
+		StartupMode string `json:"startup_mode"`
+		// StartupTimestamp is the epoch of the timestamp startup mode, in seconds, milliseconds, microseconds or nanoseconds
+		StartupTimestamp int64 `json:"startup_timestamp"`
+		// StartupSpecificOffsets are the offsets of the specific-offsets startup mode, e.g. partition:0,offset:42;partition:1,offset:300
+		StartupSpecificOffsets string `json:"startup_specific_offsets"`
+		// GroupID overrides the consumer group template of the application config, see config.Config.KafkaGroupID
+		GroupID string `json:"group_id"`
+		// Format is the format of the records, json by default
+		Format *formatConfig `json:"format"`
+		// Metadata are the Kafka metadata of a source exposed as kafka_<name> columns and carried into behaviors
+		// and alerts: topic, partition, offset, key, headers, timestamp, timestamp-type or leader-epoch
+		Metadata       []string          `json:"metadata"`
+		Schema         map[string]string `json:"schema"`
+		TimestampField string            `json:"timestamp_field"`
+		// TimestampFormat is one of sql (default), iso-8601, epoch, epoch_s, epoch_ms, epoch_us, epoch_ns or custom
+		TimestampFormat string `json:"timestamp_format"`
+		// TimestampPattern is the java.time pattern of the custom timestamp format
+		TimestampPattern string `json:"timestamp_pattern"`
+		// TimestampTimezone is the time zone of string timestamps without offset, UTC by default
+		TimestampTimezone string `json:"timestamp_timezone"`
+		// WatermarkDelay is how late events may arrive, e.g. 30s or 2h. Defaults to 1m
+		WatermarkDelay string `json:"watermark_delay"`
+		// IdleTimeout marks the source idle when no event arrives for that long, so watermarks keep advancing
+		IdleTimeout string `json:"idle_timeout"`
+	}
+	// sslConfig holds the stores used for TLS, passwords are secret references like kafkaConfig.Password
+	sslConfig struct {
+		TruststoreLocation string `json:"truststore_location"`
+		TruststorePassword string `json:"truststore_password"`
+		TruststoreType     string `json:"truststore_type"`
+		KeystoreLocation   string `json:"keystore_location"`
+		KeystorePassword   string `json:"keystore_password"`
+		KeyPassword        string `json:"key_password"`
+		KeystoreType       string `json:"keystore_type"`
+	}
+	formatConfig struct {
+		// Type is json, avro, avro-confluent, csv or raw
+		Type string `json:"type"`
+		// TimestampStandard is the json timestamp format, ISO-8601 (default) or SQL
+		TimestampStandard string `json:"timestamp_standard"`
+		// IgnoreParseErrors skips malformed json and csv records, sources ignore them by default
+		IgnoreParseErrors  *bool `json:"ignore_parse_errors"`
+		FailOnMissingField bool  `json:"fail_on_missing_field"`
+		// RegistryURL is the Confluent Schema Registry of the avro-confluent format
+		RegistryURL string `json:"registry_url"`
+		// Subject is the registry subject, <topic>-value by default
+		Subject string `json:"subject"`
+		// RegistryUserInfo is a secret reference to the user:password of the registry
+		RegistryUserInfo string `json:"registry_user_info"`
+		// DeriveSchema fills the schema of a source from the latest schema of its subject
+		DeriveSchema bool `json:"derive_schema"`
+		// Columns is the order of the csv fields, required for csv sources with more than one column
+		Columns               []string `json:"columns"`
+		FieldDelimiter        string   `json:"field_delimiter"`
+		QuoteCharacter        string   `json:"quote_character"`
+		DisableQuoteCharacter bool     `json:"disable_quote_character"`
+		AllowComments         bool     `json:"allow_comments"`
+		ArrayElementDelimiter string   `json:"array_element_delimiter"`
+		NullLiteral           string   `json:"null_literal"`
+		// Charset and Endianness are the options of the raw format
+		Charset    string `json:"charset"`
+		Endianness string `json:"endianness"`
+	}
+	// sinkConfig is a job output, a Kafka topic unless Type selects another connector whose jar must be
+	// available on the Flink cluster. The Kafka options stay at the top level for compatibility
+	sinkConfig struct {
+		// Type is kafka (default), upsert-kafka, elasticsearch, opensearch, jdbc or filesystem
+		Type string `json:"type"`
+		kafkaConfig
+		// KeyFormat is the format of the record keys of the upsert-kafka sink, json by default
+		KeyFormat     *formatConfig        `json:"key_format"`
+		Elasticsearch *elasticsearchConfig `json:"elasticsearch"`
+		JDBC          *jdbcConfig          `json:"jdbc"`
+		Filesystem    *filesystemConfig    `json:"filesystem"`
+	}
+	// elasticsearchConfig configures the elasticsearch and opensearch sinks
+	elasticsearchConfig struct {
+		Hosts []string `json:"hosts"`
+		// Index may be dynamic such as alerts-{alert_time|yyyy-MM-dd}
+		Index string `json:"index"`
+		// Version is the major Elasticsearch version, 6 or 7 (default)
+		Version  int    `json:"version"`
+		Username string `json:"username"`
+		// Password is a secret reference like kafkaConfig.Password
+		Password            string `json:"password"`
+		BulkFlushMaxActions int    `json:"bulk_flush_max_actions"`
+		BulkFlushInterval   string `json:"bulk_flush_interval"`
+	}
+	jdbcConfig struct {
+		URL      string `json:"url"`
+		Table    string `json:"table"`
+		Driver   string `json:"driver"`
+		Username string `json:"username"`
+		// Password is a secret reference like kafkaConfig.Password
+		Password            string `json:"password"`
+		BufferFlushMaxRows  int    `json:"buffer_flush_max_rows"`
+		BufferFlushInterval string `json:"buffer_flush_interval"`
+		MaxRetries          int    `json:"max_retries"`
+	}
+	filesystemConfig struct {
+		// Path is the directory the files are written to, such as s3://bucket/behaviors
+		Path string `json:"path"`
+		// Format is parquet (default), avro, csv or json
+		Format string `json:"format"`
+		// Compression is the parquet compression codec, such as SNAPPY
+		Compression string `json:"compression"`
+		// RollingFileSize is the size a part file is rolled at, such as 128MB
+		RollingFileSize  string `json:"rolling_file_size"`
+		RolloverInterval string `json:"rollover_interval"`
 	}
 	LogSourceConfig struct {
 		Config kafkaConfig `json:"config" binding:"required"`
@@ -33,19 +215,223 @@ type (
 		ID              string         `json:"id"`
 		LogSourceConfig *kafkaConfig   `json:"source_config" binding:"required"`
 		ProfileConfig   *ProfileConfig `json:"profile_config" binding:"required"`
-		ProfileOutput   *kafkaConfig   `json:"profile_output_config" binding:"required"`
-		BehaviorOutput  *kafkaConfig   `json:"behavior_output_config" binding:"required"`
-		BehaviorFilter  string         `json:"filter" binding:"required"`
+		ProfileOutput   *sinkConfig    `json:"profile_output_config" binding:"required"`
+		BehaviorOutput  *sinkConfig    `json:"behavior_output_config" binding:"required"`
+		// AnomalyOutput receives the anomalous profile windows, it is required when profile_config.anomaly is set
+		AnomalyOutput  *sinkConfig `json:"anomaly_output_config"`
+		BehaviorFilter string      `json:"filter" binding:"required"`
+		// RawFilter passes BehaviorFilter to Flink as a SQL expression instead of compiling it as a filter expression
+		RawFilter bool `json:"raw_filter"`
 	}
 	RuleJobConfig struct {
-		ID                     string       `json:"id"`
-		Name                   string       `json:"name"`
-		Filter                 string       `json:"filter"`
-		Object                 string       `json:"object"`
-		Technique              string       `json:"technique"`
-		Severity               string       `json:"severity"`
-		RiskScore              int          `json:"risk_score"`
-		ProfilePredictorOutput *kafkaConfig `json:"profile_predictor_config" binding:"required"`
-		RuleOutput             *kafkaConfig `json:"rule_output_config" binding:"required"`
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Filter    string `json:"filter"`
+		RawFilter bool   `json:"raw_filter"`
+		// Object is the predictor column naming the object of the alerts, such as entities
+		Object string `json:"object"`
+		// ObjectLabel is a fixed label used as the object of every alert instead of a column
+		ObjectLabel string `json:"object_label"`
+		// Technique is the ATT&CK technique or sub-technique the rule detects, such as T1078.004
+		Technique string `json:"technique"`
+		// Tactic narrows the technique to one of its tactics, the alerts carry every tactic of the technique otherwise
+		Tactic string `json:"tactic"`
+		// Severity is a level of the severity taxonomy, given by name, alias or rank
+		Severity string `json:"severity"`
+		// RiskScore is the risk score of the alerts, the score of the severity when it is zero
+		RiskScore int `json:"risk_score"`
+		// RiskScoreFactors scale the risk score of each alert by predictor columns, such as the asset criticality
+		RiskScoreFactors []*riskFactorConfig `json:"risk_score_factors"`
+		AlertTime        *alertTimeConfig    `json:"alert_time"`
+		// Suppression limits the alerts emitted for the same group, no alert is suppressed when it is not set
+		Suppression            *suppressionConfig `json:"suppression"`
+		ProfilePredictorOutput *kafkaConfig       `json:"profile_predictor_config" binding:"required"`
+		RuleOutput             *sinkConfig        `json:"rule_output_config" binding:"required"`
+	}
+	// riskFactorConfig scales the risk score of an alert by the value of one of its columns
+	riskFactorConfig struct {
+		Field string `json:"field"`
+		// Factors maps values of the field to multipliers of the score, e.g. {"critical": 2, "low": 0.5}. Without
+		// factors, the value of a numeric field multiplies the score
+		Factors map[string]float64 `json:"factors"`
+		// Default multiplies the score when the field is NULL or its value has no factor, 1 by default
+		Default *float64 `json:"default"`
+	}
+	// alertTimeConfig sets how the alert_time of the alerts is computed and rendered
+	alertTimeConfig struct {
+		// Source is processing (default), the time the alert is emitted, or event, the event time of the triggering
+		// record read from the timestamp_field of profile_predictor_config
+		Source string `json:"source"`
+		// Timezone is the session time zone alert_time_text is rendered in, UTC by default. alert_time itself is
+		// an instant, serialized by the json format of the output in UTC
+		Timezone string `json:"timezone"`
+		// Format is a java.time pattern such as yyyy-MM-dd HH:mm:ss, when set the alerts also carry alert_time_text
+		Format string `json:"format"`
+	}
+	// suppressionConfig limits the alerts of a rule. Alerts are grouped in tumbling windows of Duration on the time
+	// of the alert source and emitted when their window closes, with the number of alerts suppressed
+	suppressionConfig struct {
+		// Duration is how long the duplicates of an alert are suppressed, e.g. 10m
+		Duration string `json:"duration"`
+		// GroupBy are the predictor columns identifying duplicates, the object column by default
+		GroupBy []string `json:"group_by"`
+		// MaxAlerts is the number of alerts emitted per group and window, 1 by default
+		MaxAlerts int `json:"max_alerts"`
+	}
+	// CorrelationJobConfig is a rule matching an ordered sequence of events of the same partition, such as five
+	// failed logins followed by a successful one from the same user within 10 minutes
+	CorrelationJobConfig struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Technique string `json:"technique"`
+		Tactic    string `json:"tactic"`
+		Severity  string `json:"severity"`
+		RiskScore int    `json:"risk_score"`
+		// RiskScoreFactors scale the risk score of each alert by partition columns
+		RiskScoreFactors []*riskFactorConfig `json:"risk_score_factors"`
+		// PartitionBy are the source columns the events of a sequence share, such as user
+		PartitionBy []string `json:"partition_by"`
+		// Steps are the events of the sequence, in order
+		Steps []*correlationStep `json:"steps"`
+		// Within bounds the time between the first and the last event of a sequence, e.g. 10m
+		Within string `json:"within"`
+		// Object is the partition column naming the object of the alerts
+		Object string `json:"object"`
+		// ObjectLabel is a fixed label used as the object of every alert instead of a column
+		ObjectLabel  string       `json:"object_label"`
+		SourceConfig *kafkaConfig `json:"source_config" binding:"required"`
+		RuleOutput   *sinkConfig  `json:"rule_output_config" binding:"required"`
+	}
+	// correlationStep is an event of a correlation sequence
+	correlationStep struct {
+		// Name identifies the step in the pattern and names its count column, <name>_count
+		Name   string `json:"name"`
+		Filter string `json:"filter"`
+		// RawFilter passes Filter to Flink as a SQL expression instead of compiling it as a filter expression
+		RawFilter bool `json:"raw_filter"`
+		// Quantifier is how many consecutive events the step matches in the MATCH_RECOGNIZE syntax: empty for one,
+		// {5}, {3,}, {2,4}, +, * or ?. Append ? for a reluctant quantifier, which the last step requires
+		Quantifier string `json:"quantifier"`
+	}
+	// AggregationJobConfig is a rule comparing an aggregate of the source events of each group and time window to a
+	// threshold, such as more than 100 distinct destination ports per source IP in 5 minutes
+	AggregationJobConfig struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		Technique string `json:"technique"`
+		Tactic    string `json:"tactic"`
+		Severity  string `json:"severity"`
+		RiskScore int    `json:"risk_score"`
+		// RiskScoreFactors scale the risk score of each alert by group columns or the aggregate
+		RiskScoreFactors []*riskFactorConfig `json:"risk_score_factors"`
+		// Filter selects the source events aggregated, every event when empty
+		Filter string `json:"filter"`
+		// RawFilter passes Filter to Flink as a SQL expression instead of compiling it as a filter expression
+		RawFilter bool `json:"raw_filter"`
+		// GroupBy are the source columns the events are aggregated by, such as src_ip
+		GroupBy []string `json:"group_by"`
+		// Function is the aggregate function: count, count_distinct, sum, avg, min or max
+		Function string `json:"function"`
+		// Field is the source column aggregated, count counts the events when it is empty
+		Field string `json:"field"`
+		// Operator compares the aggregate to Threshold: >, >=, <, <=, = or !=, > by default. A group without
+		// events in a window has no aggregate, so < only matches groups with at least one event
+		Operator  string  `json:"operator"`
+		Threshold float64 `json:"threshold"`
+		// Window is the size of the windows, e.g. 5m
+		Window string `json:"window"`
+		// Slide makes the windows hop by Slide instead of tumbling, e.g. 1m
+		Slide string `json:"slide"`
+		// Object is the group column naming the object of the alerts
+		Object string `json:"object"`
+		// ObjectLabel is a fixed label used as the object of every alert instead of a column
+		ObjectLabel  string       `json:"object_label"`
+		SourceConfig *kafkaConfig `json:"source_config" binding:"required"`
+		RuleOutput   *sinkConfig  `json:"rule_output_config" binding:"required"`
+	}
+	// RiskJobConfig accumulates the risk scores of rule alerts per object over sliding windows, the core of the
+	// prioritization of entities. Each window emits a risk snapshot per object, and a notable event when the risk of
+	// an object reaches the threshold while it was below it in the previous window
+	RiskJobConfig struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		// Object is the alert column naming the entity risk is accumulated for, object by default
+		Object string `json:"object"`
+		// ScoreField is the alert column holding the risk score of an alert, risk_score by default
+		ScoreField string `json:"score_field"`
+		// Window is how long an alert counts towards the risk of its object, e.g. 24h
+		Window string `json:"window"`
+		// Slide is how often the risk of the objects is computed, e.g. 1h. The windows tumble when it is empty
+		Slide string `json:"slide"`
+		// HalfLife halves the score of an alert every HalfLife between the alert and the end of the window, e.g. 6h.
+		// The scores are summed without decay when it is empty
+		HalfLife  string  `json:"half_life"`
+		Threshold float64 `json:"threshold"`
+		// Severity is the severity of the notable events
+		Severity string `json:"severity"`
+		// SourceConfig reads the alerts of the rule outputs, several topics are separated by semicolons
+		SourceConfig *kafkaConfig `json:"source_config" binding:"required"`
+		// SnapshotOutput receives the risk snapshots, an upsert-kafka output keeps the latest snapshot per object
+		SnapshotOutput *sinkConfig `json:"snapshot_output_config" binding:"required"`
+		NotableOutput  *sinkConfig `json:"notable_output_config" binding:"required"`
+	}
+	// PlanRequest holds the jobs to render plans for, the jobs of the JobHub are used when it is empty
+	PlanRequest struct {
+		BehaviorJobs    []*BehaviorJobConfig    `json:"behavior_jobs"`
+		RuleJobs        []*RuleJobConfig        `json:"rule_jobs"`
+		CorrelationJobs []*CorrelationJobConfig `json:"correlation_jobs"`
+		AggregationJobs []*AggregationJobConfig `json:"aggregation_jobs"`
+		RiskJobs        []*RiskJobConfig        `json:"risk_jobs"`
+	}
+	JobPlan struct {
+		ID   string `json:"id"`
+		Kind string `json:"kind"`
+		// Rules are the IDs of the rules of a rule group
+		Rules      []string `json:"rules,omitempty"`
+		Statements []string `json:"statements"`
+		Explain    string   `json:"explain,omitempty"`
+		Error      string   `json:"error,omitempty"`
+	}
+	// RuleBacktestRequest replays a rule over a bounded range of its predictor topic, the rule is given inline or
+	// by the ID of a JobHub rule
+	RuleBacktestRequest struct {
+		Rule   *RuleJobConfig `json:"rule"`
+		RuleID string         `json:"rule_id"`
+		// From and To bound the replayed records by their Kafka timestamp as RFC 3339 times, the 7 days before now
+		// by default
+		From string `json:"from"`
+		To   string `json:"to"`
+		// SampleSize is the number of alerts returned as samples, 10 by default
+		SampleSize int `json:"sample_size"`
+		// Timeout bounds the wait for the results of each query of the backtest, 10m by default
+		Timeout string `json:"timeout"`
+	}
+	// RuleBacktestResult counts the alerts the rule would have emitted over the range, with samples of them
+	RuleBacktestResult struct {
+		RuleID      string                   `json:"rule_id"`
+		From        string                   `json:"from"`
+		To          string                   `json:"to"`
+		AlertCount  int64                    `json:"alert_count"`
+		ObjectCount int64                    `json:"object_count"`
+		Samples     []map[string]interface{} `json:"samples"`
+		Statements  []string                 `json:"statements"`
+	}
+	// RuleGroup is the rules sharing a profile predictor source, run by one job reading the source once
+	RuleGroup struct {
+		ID    string
+		Topic string
+		Rules []*RuleJobConfig
+	}
+	// RuleGroupStatus is a deployed rule group with the IDs of its rules, Error is set when its job failed.
+	// Savepoint is the savepoint of the previous job of the group its job restored, StateWarning tells which state
+	// of the rules the job lost when the group was deployed again
+	RuleGroupStatus struct {
+		ID           string   `json:"id"`
+		Topic        string   `json:"topic"`
+		Rules        []string `json:"rules"`
+		FlinkJobID   string   `json:"flink_job_id,omitempty"`
+		Savepoint    string   `json:"savepoint,omitempty"`
+		StateWarning string   `json:"state_warning,omitempty"`
+		Error        string   `json:"error,omitempty"`
 	}
 )
@@ -0,0 +1,430 @@
+package view
+
+import (
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/util"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AuthenTypeKerberos    = "kerberos"
+	AuthenTypePlain       = "plain"
+	AuthenTypeScramSHA256 = "scram-sha-256"
+	AuthenTypeScramSHA512 = "scram-sha-512"
+
+	defaultKerberosServiceName = "kafka"
+)
+
+var saslMechanisms = map[string]string{
+	AuthenTypeKerberos:    sql_builder.KafkaSASLMechanismGSSAPI,
+	"gssapi":              sql_builder.KafkaSASLMechanismGSSAPI,
+	AuthenTypePlain:       sql_builder.KafkaSASLMechanismPlain,
+	AuthenTypeScramSHA256: sql_builder.KafkaSASLMechanismScramSHA256,
+	AuthenTypeScramSHA512: sql_builder.KafkaSASLMechanismScramSHA512,
+}
+
+// EventTime returns the builder of the expression converting the timestamp field into the event time.
+// TIMESTAMP_LTZ fields, such as the kafka_timestamp metadata column, are used as is
+func (c *kafkaConfig) EventTime() sql_builder.TimestampExpSQLBuilder {
+	format := c.TimestampFormat
+	if format == "" || format == sql_builder.TimestampFormatSQL {
+		if t, err := data_type.Parse(c.Columns()[c.TimestampField]); err == nil && t.Kind == data_type.KindTimestampLTZ {
+			format = sql_builder.TimestampFormatLTZ
+		}
+	}
+	return sql_builder.NewTimestampExpSQLBuilder(c.TimestampField).
+		WithFormat(format).
+		WithPattern(c.TimestampPattern).
+		WithTimezone(c.TimestampTimezone)
+}
+
+// GetWatermarkDelay returns the watermark delay, one minute when it is not set
+func (c *kafkaConfig) GetWatermarkDelay() (time.Duration, error) {
+	if c.WatermarkDelay == "" {
+		return sql_builder.DefaultWatermarkDelay, nil
+	}
+	return util.ParseDurationExtended(c.WatermarkDelay)
+}
+
+// GetIdleTimeout returns the source idle timeout, zero disables idleness detection
+func (c *kafkaConfig) GetIdleTimeout() (time.Duration, error) {
+	if c.IdleTimeout == "" {
+		return 0, nil
+	}
+	return util.ParseDurationExtended(c.IdleTimeout)
+}
+
+// saslMechanism returns the SASL mechanism of the authentication type, empty when SASL is disabled
+func (c *kafkaConfig) saslMechanism() (string, bool) {
+	authenType := strings.ToLower(c.AuthenType)
+	if authenType == "" || authenType == "none" {
+		return "", true
+	}
+	mechanism, ok := saslMechanisms[authenType]
+	return mechanism, ok
+}
+
+// GetSecurityProtocol returns the configured protocol, or the one implied by the authentication type and SSL
+func (c *kafkaConfig) GetSecurityProtocol() string {
+	if c.SecurityProtocol != "" {
+		return strings.ToUpper(c.SecurityProtocol)
+	}
+	mechanism, _ := c.saslMechanism()
+	switch {
+	case mechanism != "" && c.SSL != nil:
+		return sql_builder.KafkaSecurityProtocolSASLSSL
+	case mechanism != "":
+		return sql_builder.KafkaSecurityProtocolSASLPlaintext
+	case c.SSL != nil:
+		return sql_builder.KafkaSecurityProtocolSSL
+	default:
+		return ""
+	}
+}
+
+// ApplySecurity adds the SASL and SSL options of the cluster to the connector.
+// Passwords are rendered as secret placeholders, see sql_builder.ResolveSecretOptions
+func (c *kafkaConfig) ApplySecurity(builder sql_builder.KafkaConnectorBuilder) {
+	protocol := c.GetSecurityProtocol()
+	if protocol == "" {
+		return
+	}
+	builder.WithSecurityProtocol(protocol)
+	mechanism, _ := c.saslMechanism()
+	switch mechanism {
+	case sql_builder.KafkaSASLMechanismGSSAPI:
+		serviceName := c.KerberosServiceName
+		if serviceName == "" {
+			serviceName = defaultKerberosServiceName
+		}
+		builder.
+			WithSASLMechanism(mechanism).
+			WithKerberosServiceName(serviceName).
+			WithSASLJAASConfig(sql_builder.KerberosJAASConfig(c.Keytab, c.Principal))
+	case sql_builder.KafkaSASLMechanismPlain:
+		builder.
+			WithSASLMechanism(mechanism).
+			WithSASLJAASConfig(sql_builder.PlainJAASConfig(c.Username, util.JAASSecretPlaceholder(c.Password)))
+	case sql_builder.KafkaSASLMechanismScramSHA256, sql_builder.KafkaSASLMechanismScramSHA512:
+		builder.
+			WithSASLMechanism(mechanism).
+			WithSASLJAASConfig(sql_builder.ScramJAASConfig(c.Username, util.JAASSecretPlaceholder(c.Password)))
+	}
+	if c.SSL == nil {
+		return
+	}
+	if c.SSL.TruststoreLocation != "" {
+		builder.WithSSLTruststore(c.SSL.TruststoreLocation, secretPlaceholder(c.SSL.TruststorePassword), c.SSL.TruststoreType)
+	}
+	if c.SSL.KeystoreLocation != "" {
+		builder.WithSSLKeystore(c.SSL.KeystoreLocation, secretPlaceholder(c.SSL.KeystorePassword),
+			secretPlaceholder(c.SSL.KeyPassword), c.SSL.KeystoreType)
+	}
+}
+
+func secretPlaceholder(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	return util.SecretPlaceholder(ref)
+}
+
+var startupModes = map[string]string{
+	"":                                       sql_builder.KafkaStartupModeGroupOffsets,
+	"earliest":                               sql_builder.KafkaStartupModeEarliest,
+	"latest":                                 sql_builder.KafkaStartupModeLatest,
+	sql_builder.KafkaStartupModeEarliest:     sql_builder.KafkaStartupModeEarliest,
+	sql_builder.KafkaStartupModeLatest:       sql_builder.KafkaStartupModeLatest,
+	sql_builder.KafkaStartupModeGroupOffsets: sql_builder.KafkaStartupModeGroupOffsets,
+	sql_builder.KafkaStartupModeTimestamp:    sql_builder.KafkaStartupModeTimestamp,
+	sql_builder.KafkaStartupModeSpecificOffsets: sql_builder.KafkaStartupModeSpecificOffsets,
+}
+
+// GetStartupMode returns the Flink startup mode of the source
+func (c *kafkaConfig) GetStartupMode() (string, bool) {
+	mode, ok := startupModes[strings.ToLower(c.StartupMode)]
+	return mode, ok
+}
+
+// GetGroupID renders the consumer group of the source for the job of the given kind and ID,
+// from the group ID template of the source or else of the application config
+func (c *kafkaConfig) GetGroupID(kind string, jobID string) string {
+	template := c.GroupID
+	if template == "" {
+		template = config.AppConfig.KafkaGroupID
+	}
+	if template == "" {
+		template = config.DefKafkaGroupID
+	}
+	return strings.NewReplacer("{kind}", kind, "{id}", jobID).Replace(template)
+}
+
+// ApplyStartup adds the consumer group and startup options of the source to the connector
+func (c *kafkaConfig) ApplyStartup(builder sql_builder.KafkaConnectorBuilder, kind string, jobID string) {
+	mode, _ := c.GetStartupMode()
+	builder.
+		WithGroupID(c.GetGroupID(kind, jobID)).
+		WithStartupMode(mode)
+	switch mode {
+	case sql_builder.KafkaStartupModeGroupOffsets:
+		builder.WithAutoOffsetReset("earliest")
+	case sql_builder.KafkaStartupModeTimestamp:
+		builder.WithStartupTimestamp(util.NormalizeTimeAsMilliseconds(c.StartupTimestamp))
+	case sql_builder.KafkaStartupModeSpecificOffsets:
+		builder.WithStartupSpecificOffsets(c.StartupSpecificOffsets)
+	}
+}
+
+// KafkaMetadataKey is the metadata exposing the record key, which Flink reads as a key field rather than metadata
+const KafkaMetadataKey = "key"
+
+// kafkaMetadataTypes are the types of the Kafka metadata columns. They are nullable so behaviors and alerts
+// can be written to any output
+var kafkaMetadataTypes = map[string]string{
+	"topic":          data_type.STRING(),
+	"partition":      data_type.INT(),
+	"offset":         data_type.BIGINT(),
+	KafkaMetadataKey: data_type.STRING(),
+	"headers":        data_type.MAP(data_type.STRING(), data_type.BYTES()),
+	"timestamp":      data_type.TIMESTAMP_LTZ_PRECISION(3),
+	"timestamp-type": data_type.STRING(),
+	"leader-epoch":   data_type.INT(),
+}
+
+// MetadataColumnName returns the column exposing the Kafka metadata, e.g. kafka_timestamp_type for timestamp-type
+func MetadataColumnName(metadata string) string {
+	return "kafka_" + strings.ReplaceAll(metadata, "-", "_")
+}
+
+// MetadataColumns returns the names and types of the metadata columns of the source, in the configured order
+func (c *kafkaConfig) MetadataColumns() [][]string {
+	var arr [][]string
+	for _, m := range c.Metadata {
+		arr = append(arr, []string{MetadataColumnName(m), kafkaMetadataTypes[m]})
+	}
+	return arr
+}
+
+// Columns returns the columns of the source, its schema and its metadata columns
+func (c *kafkaConfig) Columns() map[string]string {
+	columns := make(map[string]string, len(c.Schema)+len(c.Metadata))
+	for k, t := range c.Schema {
+		columns[k] = t
+	}
+	for _, m := range c.MetadataColumns() {
+		columns[m[0]] = m[1]
+	}
+	return columns
+}
+
+// ApplyMetadataColumns adds the metadata columns to the schema of the source. The record key is a physical
+// column read by the raw key format, see ApplyFormat
+func (c *kafkaConfig) ApplyMetadataColumns(builder sql_builder.SchemaSQLBuilder) {
+	columns := c.MetadataColumns()
+	for i, m := range c.Metadata {
+		col := columns[i]
+		if m == KafkaMetadataKey {
+			builder.WithColumn(col[0], col[1])
+			continue
+		}
+		builder.WithMetadataColumn(col[0], col[1], m, true)
+	}
+}
+
+// ApplyTopic adds the topic and the brokers of the source to the connector
+func (c *kafkaConfig) ApplyTopic(builder sql_builder.KafkaConnectorBuilder) {
+	builder.
+		WithTopic(c.Topic).
+		WithBootstrapServers(c.BootstrapServer)
+}
+
+// ApplyFormat adds the format of the source to the connector, with the raw key format when the key is exposed
+func (c *kafkaConfig) ApplyFormat(builder sql_builder.KafkaConnectorBuilder) {
+	if !c.hasMetadata(KafkaMetadataKey) {
+		builder.WithFormat(c.FormatBuilder(true))
+		return
+	}
+	builder.
+		WithKeyFormat(sql_builder.NewRawFormatBuilder()).
+		WithKeyFields(MetadataColumnName(KafkaMetadataKey)).
+		WithValueFormat(c.FormatBuilder(true)).
+		WithValueFieldsInclude(sql_builder.KafkaValueFieldsIncludeExceptKey)
+}
+
+func (c *kafkaConfig) hasMetadata(metadata string) bool {
+	for _, m := range c.Metadata {
+		if m == metadata {
+			return true
+		}
+	}
+	return false
+}
+
+func validateKafkaConfig(v *validator, name string, cfg *kafkaConfig, withSchema bool) {
+	if cfg == nil {
+		v.addf("%v is required", name)
+		return
+	}
+	if cfg.Topic == "" {
+		v.addf("%v.topic is required", name)
+	}
+	if cfg.BootstrapServer == "" {
+		v.addf("%v.bootstrap.servers is required", name)
+	}
+	for _, server := range strings.Split(cfg.BootstrapServer, ",") {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(server)
+		if err != nil {
+			v.addf("%v.bootstrap.servers: invalid address '%v'", name, server)
+			continue
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			v.addf("%v.bootstrap.servers: invalid port in '%v'", name, server)
+		}
+	}
+	validateKafkaSecurity(v, name, cfg)
+	validateFormat(v, name+".format", cfg.Format, cfg.Schema, withSchema)
+	if !withSchema {
+		if len(cfg.Metadata) > 0 {
+			v.addf("%v.metadata is only supported on sources", name)
+		}
+		return
+	}
+	validateKafkaStartup(v, name, cfg)
+	validateMetadata(v, name, cfg)
+	if len(cfg.Schema) == 0 {
+		v.addf("%v.schema is required", name)
+	}
+	for _, col := range sortedKeys(cfg.Schema) {
+		if err := data_type.Validate(cfg.Schema[col]); err != nil {
+			v.addf("%v.schema.%v: %v", name, col, err)
+		}
+	}
+}
+
+func validateKafkaSecurity(v *validator, name string, cfg *kafkaConfig) {
+	mechanism, ok := cfg.saslMechanism()
+	if !ok {
+		v.addf("%v.authen_type '%v' is not supported", name, cfg.AuthenType)
+	}
+	switch protocol := cfg.GetSecurityProtocol(); protocol {
+	case "", sql_builder.KafkaSecurityProtocolPlaintext, sql_builder.KafkaSecurityProtocolSSL:
+		if mechanism != "" {
+			v.addf("%v.security_protocol %v cannot be used with authen_type %v", name, protocol, cfg.AuthenType)
+		}
+	case sql_builder.KafkaSecurityProtocolSASLPlaintext, sql_builder.KafkaSecurityProtocolSASLSSL:
+		if mechanism == "" {
+			v.addf("%v.security_protocol %v requires an authen_type", name, protocol)
+		}
+	default:
+		v.addf("%v.security_protocol '%v' is not supported", name, cfg.SecurityProtocol)
+	}
+	switch mechanism {
+	case sql_builder.KafkaSASLMechanismGSSAPI:
+		if cfg.Keytab == "" || cfg.Principal == "" {
+			v.addf("%v: keytab and principal are required for kerberos authentication", name)
+		}
+	case sql_builder.KafkaSASLMechanismPlain, sql_builder.KafkaSASLMechanismScramSHA256, sql_builder.KafkaSASLMechanismScramSHA512:
+		if cfg.Username == "" {
+			v.addf("%v.username is required for %v authentication", name, cfg.AuthenType)
+		}
+		validateSecretRef(v, name+".password", cfg.Password, true)
+	default:
+		validateSecretRef(v, name+".password", cfg.Password, false)
+	}
+	if cfg.SSL != nil {
+		validateSecretRef(v, name+".ssl.truststore_password", cfg.SSL.TruststorePassword, false)
+		validateSecretRef(v, name+".ssl.keystore_password", cfg.SSL.KeystorePassword, false)
+		validateSecretRef(v, name+".ssl.key_password", cfg.SSL.KeyPassword, false)
+		if cfg.SSL.KeystoreLocation == "" && (cfg.SSL.KeystorePassword != "" || cfg.SSL.KeyPassword != "") {
+			v.addf("%v.ssl.keystore_location is required when a keystore password is set", name)
+		}
+	}
+}
+
+// validateMetadata checks the metadata columns of a source do not clash with its schema
+func validateMetadata(v *validator, name string, cfg *kafkaConfig) {
+	seen := make(map[string]struct{}, len(cfg.Metadata))
+	for _, m := range cfg.Metadata {
+		if _, ok := kafkaMetadataTypes[m]; !ok {
+			v.addf("%v.metadata '%v' is not supported", name, m)
+			continue
+		}
+		if _, ok := seen[m]; ok {
+			v.addf("%v.metadata: duplicate metadata '%v'", name, m)
+			continue
+		}
+		seen[m] = struct{}{}
+		if _, ok := cfg.Schema[MetadataColumnName(m)]; ok {
+			v.addf("%v.metadata: column '%v' of metadata '%v' is already in the schema", name, MetadataColumnName(m), m)
+		}
+	}
+}
+
+var specificOffsetsRegexp = regexp.MustCompile(`^partition:\d+,offset:\d+(;partition:\d+,offset:\d+)*$`)
+
+// validateKafkaStartup checks the startup mode of a source and the options that belong to it
+func validateKafkaStartup(v *validator, name string, cfg *kafkaConfig) {
+	mode, ok := cfg.GetStartupMode()
+	if !ok {
+		v.addf("%v.startup_mode '%v' is not supported", name, cfg.StartupMode)
+	}
+	if mode == sql_builder.KafkaStartupModeTimestamp {
+		if cfg.StartupTimestamp <= 0 {
+			v.addf("%v.startup_timestamp is required for the timestamp startup mode", name)
+		}
+	} else if cfg.StartupTimestamp != 0 {
+		v.addf("%v.startup_timestamp is only used by the timestamp startup mode", name)
+	}
+	if mode == sql_builder.KafkaStartupModeSpecificOffsets {
+		if !specificOffsetsRegexp.MatchString(cfg.StartupSpecificOffsets) {
+			v.addf("%v.startup_specific_offsets '%v' must look like partition:0,offset:42;partition:1,offset:300", name, cfg.StartupSpecificOffsets)
+		}
+	} else if cfg.StartupSpecificOffsets != "" {
+		v.addf("%v.startup_specific_offsets is only used by the specific-offsets startup mode", name)
+	}
+}
+
+// validateEventTime checks the timestamp field of a source against its declared format, time zone and watermark
+func validateEventTime(v *validator, name string, cfg *kafkaConfig) {
+	if cfg.TimestampField == "" {
+		v.addf("%v.timestamp_field is required", name)
+	} else if typeStr, ok := cfg.Columns()[cfg.TimestampField]; !ok {
+		v.addf("%v.timestamp_field '%v' is not in the schema", name, cfg.TimestampField)
+	} else if t, err := data_type.Parse(typeStr); err == nil {
+		isEpoch := sql_builder.IsEpochTimestampFormat(cfg.TimestampFormat)
+		switch {
+		case isEpoch && !t.IsIntegral():
+			v.addf("%v.timestamp_field '%v' must be an integer for format %v but is %v", name, cfg.TimestampField, cfg.TimestampFormat, t)
+		case cfg.TimestampFormat == sql_builder.TimestampFormatLTZ && t.Kind != data_type.KindTimestampLTZ:
+			v.addf("%v.timestamp_field '%v' must be a TIMESTAMP_LTZ for format %v but is %v", name, cfg.TimestampField, cfg.TimestampFormat, t)
+		case !isEpoch && cfg.TimestampFormat != sql_builder.TimestampFormatLTZ && !t.IsCharacterString() &&
+			!(t.IsTemporal() && (cfg.TimestampFormat == "" || cfg.TimestampFormat == sql_builder.TimestampFormatSQL)):
+			v.addf("%v.timestamp_field '%v' must be a string for format %v but is %v", name, cfg.TimestampField, cfg.TimestampFormat, t)
+		}
+	}
+	if !sql_builder.IsTimestampFormat(cfg.TimestampFormat) {
+		v.addf("%v.timestamp_format '%v' is not supported", name, cfg.TimestampFormat)
+	}
+	if cfg.TimestampFormat == sql_builder.TimestampFormatCustom && cfg.TimestampPattern == "" {
+		v.addf("%v.timestamp_pattern is required for the custom timestamp format", name)
+	}
+	if cfg.TimestampTimezone != "" && !isValidTimezone(cfg.TimestampTimezone) {
+		v.addf("%v.timestamp_timezone '%v' is not a valid time zone", name, cfg.TimestampTimezone)
+	}
+	if d, err := cfg.GetWatermarkDelay(); err != nil || d < 0 {
+		v.addf("%v.watermark_delay '%v' is not a valid duration", name, cfg.WatermarkDelay)
+	}
+	if d, err := cfg.GetIdleTimeout(); err != nil || d < 0 {
+		v.addf("%v.idle_timeout '%v' is not a valid duration", name, cfg.IdleTimeout)
+	}
+}
@@ -0,0 +1,288 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/util"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ObjectOriginal  = "original"
+	ObjectMapping   = "mapping"
+	ObjectReference = "reference"
+
+	// LookupProcTimeField is the processing time of a log source, jdbc reference tables are looked up as of it
+	LookupProcTimeField = "lookup_proc_time"
+	// ReferenceTimeField is the version time of an upsert-kafka reference table, the time its rows were written
+	ReferenceTimeField = "reference_time"
+
+	defaultReferenceCacheMaxRows = 10000
+	// defaultReferenceIdleTimeout is the idle timeout of a job joining an upsert-kafka reference table, a topic
+	// rarely written to whose watermark would otherwise hold back every behavior
+	defaultReferenceIdleTimeout = time.Minute
+)
+
+// GetType returns the type of the object, original by default
+func (o *Object) GetType() string {
+	if o.Type == "" {
+		return ObjectOriginal
+	}
+	return strings.ToLower(o.Type)
+}
+
+// IsResolved reports whether the object is profiled by the identity its field resolves to rather than its raw value
+func (o *Object) IsResolved() bool {
+	return o.GetType() == ObjectMapping || o.GetType() == ObjectReference
+}
+
+// ProfileField returns the column the object is profiled by
+func (o *Object) ProfileField() string {
+	if o.IsResolved() {
+		return o.Name + "_resolved"
+	}
+	return o.Name
+}
+
+// ResolvedExpression renders the identity the field resolves to as a STRING, source and reference are the aliases
+// of the behaviors and of the reference table of the object
+func (o *Object) ResolvedExpression(source string, reference string) string {
+	raw := fmt.Sprintf("CAST(%v.%v AS STRING)", source, sql_builder.QuoteIdentifier(o.Name))
+	if o.GetType() == ObjectReference {
+		return fmt.Sprintf("COALESCE(CAST(%v.%v AS STRING), %v)", reference, sql_builder.QuoteIdentifier(o.Reference.Value), raw)
+	}
+	values := make([]string, 0, len(o.Mapping.Values))
+	for value := range o.Mapping.Values {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	builder := sql_builder.NewCaseExpSQLBuilder(raw)
+	for _, value := range values {
+		builder.WithWhen(sql_builder.QuoteLiteral(value), sql_builder.QuoteLiteral(o.Mapping.Values[value]))
+	}
+	return builder.WithElse(raw).Build()
+}
+
+// JoinCondition renders the condition joining the behaviors to the reference table of the object
+func (o *Object) JoinCondition(source string, reference string) string {
+	return fmt.Sprintf("%v.%v = %v.%v", source, sql_builder.QuoteIdentifier(o.Name), reference, sql_builder.QuoteIdentifier(o.Reference.Key))
+}
+
+// IsLookup reports whether the reference table is looked up at processing time rather than joined as a versioned table
+func (r *referenceConfig) IsLookup() bool {
+	return r.Table.GetType() == SinkJDBC
+}
+
+// TimeField returns the column of the behaviors the reference table is joined as of
+func (r *referenceConfig) TimeField() string {
+	if r.IsLookup() {
+		return LookupProcTimeField
+	}
+	return EventTimeField
+}
+
+// Connector returns the connector of the reference table, with the lookup cache of a jdbc table
+func (r *referenceConfig) Connector() (sql_builder.ConnectorBuilder, error) {
+	if !r.IsLookup() {
+		return r.Table.Connector()
+	}
+	builder, err := r.Table.jdbcConnector()
+	if err != nil {
+		return nil, err
+	}
+	if r.CacheTTL != "" {
+		ttl, err := util.ParseDurationExtended(r.CacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		maxRows := r.CacheMaxRows
+		if maxRows <= 0 {
+			maxRows = defaultReferenceCacheMaxRows
+		}
+		builder.WithLookupCache(maxRows, ttl)
+	}
+	return builder, nil
+}
+
+// ResolvedObjects returns the entities and attributes resolved through a mapping or a reference table, each field once
+func (c *BehaviorJobConfig) ResolvedObjects() []*Object {
+	var objects []*Object
+	seen := util.NewStringSet()
+	for _, obj := range append(append([]*Object{}, c.ProfileConfig.Entities...), c.ProfileConfig.Attributes...) {
+		if obj == nil || !obj.IsResolved() || seen.Has(obj.Name) {
+			continue
+		}
+		seen.Add(obj.Name)
+		objects = append(objects, obj)
+	}
+	return objects
+}
+
+// ReferenceObjects returns the objects resolved through a reference table
+func (c *BehaviorJobConfig) ReferenceObjects() []*Object {
+	var objects []*Object
+	for _, obj := range c.ResolvedObjects() {
+		if obj.GetType() == ObjectReference {
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+// HasLookup reports whether a reference table is looked up, the log source then needs a processing time
+func (c *BehaviorJobConfig) HasLookup() bool {
+	for _, obj := range c.ReferenceObjects() {
+		if obj.Reference != nil && obj.Reference.Table != nil && obj.Reference.IsLookup() {
+			return true
+		}
+	}
+	return false
+}
+
+// HasVersionedReference reports whether an upsert-kafka reference table is joined as a versioned table
+func (c *BehaviorJobConfig) HasVersionedReference() bool {
+	for _, obj := range c.ReferenceObjects() {
+		if obj.Reference != nil && obj.Reference.Table != nil && !obj.Reference.IsLookup() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetIdleTimeout returns the idle timeout of the sources of the job, the one of the log source. It is one minute when
+// not set and an upsert-kafka reference table is joined, so the idle reference topic does not stall the join
+func (c *BehaviorJobConfig) GetIdleTimeout() (time.Duration, error) {
+	if c.LogSourceConfig.IdleTimeout == "" && c.HasVersionedReference() {
+		return defaultReferenceIdleTimeout, nil
+	}
+	return c.LogSourceConfig.GetIdleTimeout()
+}
+
+// BehaviorColumns returns the columns of the behaviors: the log source columns, its metadata, the event time and
+// the resolved identities. The behavior sink is declared from it and the behaviors are inserted by name
+func (c *BehaviorJobConfig) BehaviorColumns() [][]string {
+	columns := append(c.LogSourceConfig.OrderedSchema(), c.LogSourceConfig.MetadataColumns()...)
+	columns = append(columns, []string{EventTimeField, c.LogSourceConfig.EventTime().DataType()})
+	for _, obj := range c.ResolvedObjects() {
+		columns = append(columns, []string{obj.ProfileField(), data_type.STRING()})
+	}
+	return columns
+}
+
+// validateObjects checks the profile entities or attributes, of which one or two fields are supported
+func validateObjects(v *validator, name string, objects []*Object, schema map[string]string) {
+	if len(objects) == 0 || len(objects) > 2 {
+		v.addf("%v must contain one or two fields", name)
+	}
+	for i, obj := range objects {
+		if obj == nil || obj.Name == "" {
+			v.addf("%v[%v].field_name is required", name, i)
+			continue
+		}
+		typeStr, ok := schema[obj.Name]
+		if !ok {
+			v.addf("%v[%v]: field '%v' is not in the schema", name, i, obj.Name)
+			continue
+		}
+		if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("%v[%v]: field '%v' has complex type %v", name, i, obj.Name, t)
+		}
+		validateObjectResolution(v, fmt.Sprintf("%v[%v]", name, i), obj, typeStr)
+	}
+}
+
+// validateObjectResolution checks how the object is resolved, fieldType is the type of its field
+func validateObjectResolution(v *validator, name string, obj *Object, fieldType string) {
+	switch objType := obj.GetType(); objType {
+	case ObjectOriginal:
+		if obj.Mapping != nil || obj.Reference != nil {
+			v.addf("%v: mapping and reference are only used with the mapping and reference types", name)
+		}
+	case ObjectMapping:
+		if obj.Mapping == nil || len(obj.Mapping.Values) == 0 {
+			v.addf("%v.mapping.values is required for the mapping type", name)
+		}
+		if obj.Reference != nil {
+			v.addf("%v.reference is only used with the reference type", name)
+		}
+	case ObjectReference:
+		if obj.Mapping != nil {
+			v.addf("%v.mapping is only used with the mapping type", name)
+		}
+		if obj.Reference == nil {
+			v.addf("%v.reference is required for the reference type", name)
+			return
+		}
+		validateReference(v, name+".reference", obj.Reference, fieldType)
+	default:
+		v.addf("%v.type '%v' is not supported, use original, mapping or reference", name, obj.Type)
+	}
+}
+
+// validateReference checks the reference table of an object. The field is joined on the key, the primary key of the
+// table, which must have the type of the field: Flink only joins a versioned table on its primary key as is
+func validateReference(v *validator, name string, ref *referenceConfig, fieldType string) {
+	if ref.Table == nil {
+		v.addf("%v.table is required", name)
+		return
+	}
+	table := ref.Table
+	switch tableType := table.GetType(); tableType {
+	case SinkJDBC:
+		validateJDBCSink(v, name+".table", table.JDBC)
+	case SinkUpsertKafka:
+		validateKafkaConfig(v, name+".table", &table.kafkaConfig, true)
+		validateFormat(v, name+".table.key_format", table.KeyFormat, nil, false)
+		for _, col := range []string{ReferenceTimeField, MetadataColumnName("timestamp")} {
+			if _, ok := table.Schema[col]; ok {
+				v.addf("%v.table: column '%v' is reserved for the version time", name, col)
+			}
+		}
+		if ref.CacheTTL != "" {
+			v.addf("%v.cache_ttl is only used with jdbc tables", name)
+		}
+	default:
+		v.addf("%v.table.type '%v' is not supported, use jdbc or upsert-kafka", name, tableType)
+	}
+	if len(table.Schema) == 0 {
+		v.addf("%v.table.schema is required", name)
+	}
+	for _, col := range []struct{ option, field string }{{"key", ref.Key}, {"value", ref.Value}} {
+		if col.field == "" {
+			v.addf("%v.%v is required", name, col.option)
+			continue
+		}
+		typeStr, ok := table.Schema[col.field]
+		if !ok {
+			v.addf("%v.%v: column '%v' is not in the schema of the table", name, col.option, col.field)
+		} else if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("%v.%v: column '%v' has complex type %v", name, col.option, col.field, t)
+		} else if col.option == "key" && !sameKeyType(typeStr, fieldType) {
+			v.addf("%v.key: column '%v' has type %v but the field has type %v", name, col.field, typeStr, fieldType)
+		}
+	}
+	validateDuration(v, name+".cache_ttl", ref.CacheTTL)
+	if ref.CacheMaxRows < 0 {
+		v.addf("%v.cache_max_rows must not be negative", name)
+	}
+}
+
+// sameKeyType reports whether a field of type a can be joined on a key of type b: the types are equal but for
+// their nullability, or are both character strings
+func sameKeyType(a string, b string) bool {
+	ta, err := data_type.Parse(a)
+	if err != nil {
+		return true
+	}
+	tb, err := data_type.Parse(b)
+	if err != nil {
+		return true
+	}
+	if ta.IsCharacterString() && tb.IsCharacterString() {
+		return true
+	}
+	return ta.AsNullable().String() == tb.AsNullable().String()
+}
@@ -0,0 +1,134 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/util"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// RiskObjectField, RiskScoreField and RiskCountField are the columns of a risk snapshot: the object, its risk
+	// in the window and the number of alerts of the window
+	RiskObjectField = "object"
+	RiskScoreField  = "risk_score"
+	RiskCountField  = "alert_count"
+	// PreviousRiskScoreField is the risk of the object in the previous window, carried by notable events
+	PreviousRiskScoreField = "previous_risk_score"
+)
+
+// DeriveSchemas fills the source schema from the schema registry when the source asks for it
+func (c *RiskJobConfig) DeriveSchemas(fetch SchemaFetcher) error {
+	return c.SourceConfig.deriveSchema("source_config", fetch)
+}
+
+func (c *RiskJobConfig) GetObject() string {
+	if c.Object == "" {
+		return "object"
+	}
+	return c.Object
+}
+
+func (c *RiskJobConfig) GetScoreField() string {
+	if c.ScoreField == "" {
+		return "risk_score"
+	}
+	return c.ScoreField
+}
+
+func (c *RiskJobConfig) GetWindow() (time.Duration, error) {
+	return util.ParseDurationExtended(c.Window)
+}
+
+// GetSlide returns how far the windows move, the window size when they tumble
+func (c *RiskJobConfig) GetSlide() (time.Duration, error) {
+	if c.Slide == "" {
+		return c.GetWindow()
+	}
+	return util.ParseDurationExtended(c.Slide)
+}
+
+// GetHalfLife returns the half-life of the scores, zero when they do not decay
+func (c *RiskJobConfig) GetHalfLife() (time.Duration, error) {
+	if c.HalfLife == "" {
+		return 0, nil
+	}
+	return util.ParseDurationExtended(c.HalfLife)
+}
+
+// SnapshotColumns returns the columns of the risk snapshots, selected by name from the risk of the objects
+func (c *RiskJobConfig) SnapshotColumns(jobID string) []AlertColumn {
+	return []AlertColumn{
+		{Name: RiskObjectField, Type: data_type.STRING(), Expression: sql_builder.QuoteIdentifier(RiskObjectField)},
+		{Name: "window_start", Type: data_type.TIMESTAMP_PRECISION(3), Expression: "window_start"},
+		{Name: "window_end", Type: data_type.TIMESTAMP_PRECISION(3), Expression: "window_end"},
+		{Name: RiskScoreField, Type: data_type.DOUBLE(), Expression: sql_builder.QuoteIdentifier(RiskScoreField)},
+		{Name: RiskCountField, Type: data_type.BIGINT(), Expression: sql_builder.QuoteIdentifier(RiskCountField)},
+		{Name: "risk_job_id", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(jobID)},
+	}
+}
+
+// NotableColumns returns the columns of the notable events: the snapshot crossing the threshold, the risk of the
+// previous window and the notable metadata
+func (c *RiskJobConfig) NotableColumns(jobID string) []AlertColumn {
+	columns := []AlertColumn{
+		{Name: "notable_id", Type: data_type.STRING(), Expression: "UUID()"},
+		{Name: "notable_time", Type: data_type.TIMESTAMP_LTZ_PRECISION(3), Expression: "CURRENT_ROW_TIMESTAMP()"},
+	}
+	columns = append(columns, c.SnapshotColumns(jobID)...)
+	return append(columns,
+		AlertColumn{Name: PreviousRiskScoreField, Type: data_type.DOUBLE(), Expression: fmt.Sprintf("CAST(%v AS DOUBLE)", sql_builder.QuoteIdentifier(PreviousRiskScoreField))},
+		AlertColumn{Name: "threshold", Type: data_type.DOUBLE(), Expression: fmt.Sprintf("CAST(%v AS DOUBLE)", strconv.FormatFloat(c.Threshold, 'f', -1, 64))},
+		AlertColumn{Name: "risk_job_name", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(c.Name)},
+		AlertColumn{Name: "severity", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(NormalizeSeverity(c.Severity))},
+	)
+}
+
+// Validate checks the risk job config against the schema of the alerts it reads without contacting Flink
+func (c *RiskJobConfig) Validate() error {
+	v := &validator{}
+	if c.ID == "" {
+		v.addf("id is required")
+	}
+	validateNoSecretPlaceholders(v, c)
+	validateKafkaConfig(v, "source_config", c.SourceConfig, true)
+	if c.SourceConfig != nil {
+		schema := c.SourceConfig.Columns()
+		validateEventTime(v, "source_config", c.SourceConfig)
+		if typeStr, ok := schema[c.GetObject()]; !ok {
+			v.addf("object '%v' is not in the schema", c.GetObject())
+		} else if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("object '%v' has complex type %v", c.GetObject(), t)
+		}
+		if typeStr, ok := schema[c.GetScoreField()]; !ok {
+			v.addf("score_field '%v' is not in the schema", c.GetScoreField())
+		} else if t, err := data_type.Parse(typeStr); err == nil && !t.IsNumeric() {
+			v.addf("score_field '%v' must be numeric but is %v", c.GetScoreField(), t)
+		}
+	}
+	window, err := c.GetWindow()
+	if err != nil || window <= 0 {
+		v.addf("window '%v' is not a valid duration", c.Window)
+	}
+	if slide, err := c.GetSlide(); err != nil || slide <= 0 {
+		v.addf("slide '%v' is not a valid duration", c.Slide)
+	} else if window > 0 && (slide > window || window%slide != 0) {
+		v.addf("slide '%v' must divide window '%v'", c.Slide, c.Window)
+	} else if slide%time.Second != 0 {
+		v.addf("slide '%v' must be a whole number of seconds", c.Slide)
+	}
+	if halfLife, err := c.GetHalfLife(); err != nil || halfLife < 0 || (c.HalfLife != "" && halfLife == 0) {
+		v.addf("half_life '%v' is not a valid duration", c.HalfLife)
+	}
+	if c.Threshold <= 0 {
+		v.addf("threshold must be positive")
+	}
+	if c.Severity != "" {
+		validateSeverity(v, c.Severity)
+	}
+	validateSink(v, "snapshot_output_config", c.SnapshotOutput, nil, true)
+	validateSink(v, "notable_output_config", c.NotableOutput, nil, false)
+	return v.err()
+}
@@ -0,0 +1,293 @@
+package view
+
+import (
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/util"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type (
+	// AlertColumn is a column of an alert or anomaly sink with the expression the job selects into it
+	AlertColumn struct {
+		Name       string
+		Type       string
+		Expression string
+	}
+)
+
+const (
+	AlertTimeProcessing = "processing"
+	AlertTimeEvent      = "event"
+
+	defaultAlertTimezone = "UTC"
+
+	// AlertProcTimeField is the processing time attribute of the predictor source used to suppress alerts
+	AlertProcTimeField = "alert_proc_time"
+	// SuppressionKeyField is the column of the suppression input holding the group of an alert
+	SuppressionKeyField = "suppression_key"
+)
+
+// suppressionWindowColumns are the columns of the window TVF, predictor columns of the same name are renamed
+// in the suppression input
+var suppressionWindowColumns = util.NewStringSetFrom("window_start", "window_end", "window_time")
+
+// GetAlertTimeSource returns where the alert time is taken from, processing time by default
+func (c *RuleJobConfig) GetAlertTimeSource() string {
+	if c.AlertTime == nil || c.AlertTime.Source == "" {
+		return AlertTimeProcessing
+	}
+	return strings.ToLower(c.AlertTime.Source)
+}
+
+// GetAlertTimezone returns the session time zone of the rule job, UTC by default
+func (c *RuleJobConfig) GetAlertTimezone() string {
+	if c.AlertTime == nil || c.AlertTime.Timezone == "" {
+		return defaultAlertTimezone
+	}
+	return c.AlertTime.Timezone
+}
+
+// GetAlertTimeFormat returns the pattern of alert_time_text, empty when the alerts do not carry it
+func (c *RuleJobConfig) GetAlertTimeFormat() string {
+	if c.AlertTime == nil {
+		return ""
+	}
+	return c.AlertTime.Format
+}
+
+// alertMetadataColumns are the columns the rule job adds to the predictor columns
+var alertMetadataColumns = []string{"alert_id", "alert_time", "alert_time_text", "rule_id", "rule_name", "technique", "tactic",
+	"technique_name", "sub_technique", "severity", "risk_score", "object", "suppressed_count", AlertProcTimeField, SuppressionKeyField,
+	"alert_rank", "alert_count"}
+
+// AlertColumns returns the columns of the alerts: the predictor columns followed by the typed alert metadata.
+// The rule sink is declared from it and the rule job selects it by name, so both always agree.
+// alert_time is expected to be computed by the rule view, see AlertTimeExpression
+func (c *RuleJobConfig) AlertColumns(ruleID string) []AlertColumn {
+	var columns []AlertColumn
+	for _, v := range append(c.ProfilePredictorOutput.OrderedSchema(), c.ProfilePredictorOutput.MetadataColumns()...) {
+		columns = append(columns, AlertColumn{Name: v[0], Type: v[1], Expression: sql_builder.QuoteIdentifier(c.suppressionInputName(v[0]))})
+	}
+	alertTime := sql_builder.QuoteIdentifier("alert_time")
+	columns = append(columns,
+		AlertColumn{Name: "alert_id", Type: data_type.STRING(), Expression: "UUID()"},
+		AlertColumn{Name: "alert_time", Type: data_type.TIMESTAMP_LTZ_PRECISION(3), Expression: alertTime},
+	)
+	if format := c.GetAlertTimeFormat(); format != "" {
+		columns = append(columns, AlertColumn{
+			Name:       "alert_time_text",
+			Type:       data_type.STRING(),
+			Expression: fmt.Sprintf("DATE_FORMAT(%v, %v)", alertTime, sql_builder.QuoteLiteral(format)),
+		})
+	}
+	columns = append(columns, ruleMetadataColumns(ruleID, c.Name, c.Technique, c.Tactic, c.Severity, c.riskScoreExpression(), c.objectExpression())...)
+	if c.HasSuppression() {
+		columns = append(columns, AlertColumn{Name: "suppressed_count", Type: data_type.BIGINT(), Expression: "`suppressed_count`"})
+	}
+	return columns
+}
+
+// ruleMetadataColumns returns the columns describing the rule of an alert, its ATT&CK technique and its object
+func ruleMetadataColumns(ruleID, name, technique, tactic, severity string, riskScore string, object string) []AlertColumn {
+	columns := []AlertColumn{
+		{Name: "rule_id", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(ruleID)},
+		{Name: "rule_name", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(name)},
+		{Name: "technique", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(technique)},
+	}
+	columns = append(columns, attackColumns(technique, tactic)...)
+	return append(columns,
+		AlertColumn{Name: "severity", Type: data_type.STRING(), Expression: sql_builder.QuoteLiteral(NormalizeSeverity(severity))},
+		AlertColumn{Name: "risk_score", Type: data_type.BIGINT(), Expression: riskScore},
+		AlertColumn{Name: "object", Type: data_type.STRING(), Expression: object},
+	)
+}
+
+// riskScoreExpression renders the risk score of an alert, see riskScoreExpression
+func (c *RuleJobConfig) riskScoreExpression() string {
+	return riskScoreExpression(c.Severity, c.RiskScore, c.RiskScoreFactors, func(field string) string {
+		return sql_builder.QuoteIdentifier(c.suppressionInputName(field))
+	})
+}
+
+// objectExpression renders the object of an alert, the fixed label or the value of the object column
+func (c *RuleJobConfig) objectExpression() string {
+	if c.ObjectLabel != "" {
+		return sql_builder.QuoteLiteral(c.ObjectLabel)
+	}
+	return fmt.Sprintf("CAST(%v AS STRING)", sql_builder.QuoteIdentifier(c.suppressionInputName(c.Object)))
+}
+
+// AlertTimeExpression renders the time of an alert, evaluated for every alert by Flink
+func (c *RuleJobConfig) AlertTimeExpression() string {
+	if c.GetAlertTimeSource() == AlertTimeEvent {
+		return c.ProfilePredictorOutput.EventTime().BuildLTZ()
+	}
+	return "CURRENT_ROW_TIMESTAMP()"
+}
+
+// HasSuppression reports whether the alerts of the rule are suppressed
+func (c *RuleJobConfig) HasSuppression() bool {
+	return c.Suppression != nil
+}
+
+// GetSuppressionDuration returns how long the duplicates of an alert are suppressed
+func (c *RuleJobConfig) GetSuppressionDuration() (time.Duration, error) {
+	return util.ParseDurationExtended(c.Suppression.Duration)
+}
+
+// GetSuppressionGroupBy returns the columns identifying duplicate alerts, the object column by default.
+// Alerts with an object label and no group_by are all duplicates of each other
+func (c *RuleJobConfig) GetSuppressionGroupBy() []string {
+	if len(c.Suppression.GroupBy) > 0 {
+		return c.Suppression.GroupBy
+	}
+	if c.Object != "" {
+		return []string{c.Object}
+	}
+	return nil
+}
+
+// GetMaxAlerts returns the number of alerts emitted per group and window, 1 by default
+func (c *RuleJobConfig) GetMaxAlerts() int {
+	if c.Suppression.MaxAlerts == 0 {
+		return 1
+	}
+	return c.Suppression.MaxAlerts
+}
+
+// SuppressionTimeField returns the time attribute alerts are suppressed on: the event time of the predictor
+// records when alert_time is taken from it, else the processing time
+func (c *RuleJobConfig) SuppressionTimeField() string {
+	if c.GetAlertTimeSource() == AlertTimeEvent {
+		return EventTimeField
+	}
+	return AlertProcTimeField
+}
+
+// SuppressionInputColumns returns the columns of the view alerts are suppressed from: the predictor columns,
+// renamed when they clash with the window columns, alert_time, the time attribute and the group of the alert
+func (c *RuleJobConfig) SuppressionInputColumns() []AlertColumn {
+	var columns []AlertColumn
+	for _, v := range append(c.ProfilePredictorOutput.OrderedSchema(), c.ProfilePredictorOutput.MetadataColumns()...) {
+		columns = append(columns, AlertColumn{Name: c.suppressionInputName(v[0]), Type: v[1], Expression: sql_builder.QuoteIdentifier(v[0])})
+	}
+	var keys []string
+	for _, f := range c.GetSuppressionGroupBy() {
+		keys = append(keys, fmt.Sprintf("COALESCE(CAST(%v AS STRING), '')", sql_builder.QuoteIdentifier(f)))
+	}
+	key := "''"
+	if len(keys) > 0 {
+		key = fmt.Sprintf("CONCAT_WS('|', %v)", strings.Join(keys, ", "))
+	}
+	return append(columns,
+		AlertColumn{Name: "alert_time", Type: data_type.TIMESTAMP_LTZ_PRECISION(3), Expression: "`alert_time`"},
+		AlertColumn{Name: c.SuppressionTimeField(), Expression: sql_builder.QuoteIdentifier(c.SuppressionTimeField())},
+		AlertColumn{Name: SuppressionKeyField, Type: data_type.STRING(), Expression: key},
+	)
+}
+
+// suppressionInputName returns the name of a predictor column in the suppression input
+func (c *RuleJobConfig) suppressionInputName(column string) string {
+	if c.HasSuppression() && suppressionWindowColumns.Has(column) {
+		return "predictor_" + column
+	}
+	return column
+}
+
+// Validate checks the rule job config against its predictor source schema without contacting Flink
+func (c *RuleJobConfig) Validate() error {
+	v := &validator{}
+	if c.ID == "" {
+		v.addf("id is required")
+	}
+	validateNoSecretPlaceholders(v, c)
+	validateAttack(v, c.Technique, c.Tactic)
+	validateKafkaConfig(v, "profile_predictor_config", c.ProfilePredictorOutput, true)
+	var predictorColumns map[string]string
+	if c.ProfilePredictorOutput != nil {
+		predictorColumns = c.ProfilePredictorOutput.Columns()
+	}
+	validateSink(v, "rule_output_config", c.RuleOutput, predictorColumns, false)
+	validateSeverity(v, c.Severity)
+	validateRiskScore(v, c.RiskScore, c.RiskScoreFactors, predictorColumns)
+	if c.ProfilePredictorOutput != nil {
+		switch {
+		case c.Object == "" && c.ObjectLabel == "":
+			v.addf("object or object_label is required")
+		case c.Object != "" && c.ObjectLabel != "":
+			v.addf("object and object_label are exclusive, object names a column and object_label is a fixed label")
+		case c.Object != "":
+			if _, ok := c.ProfilePredictorOutput.Columns()[c.Object]; !ok {
+				v.addf("object '%v' is not in the schema", c.Object)
+			}
+		}
+		for _, col := range alertMetadataColumns {
+			if _, ok := c.ProfilePredictorOutput.Columns()[col]; ok {
+				v.addf("profile_predictor_config: column '%v' is reserved for the alert metadata", col)
+			}
+		}
+		if lookups, err := c.WatchlistLookups(); err != nil {
+			v.addf("filter: %v", err)
+		} else if len(lookups) > 0 {
+			if !config.AppConfig.Watchlist.HasTable() {
+				v.addf("filter: WATCHLIST needs the watchlist table, which is not configured")
+			}
+			for col := range c.ProfilePredictorOutput.Columns() {
+				if col == LookupProcTimeField || strings.HasPrefix(col, filter.WatchlistColumnPrefix) {
+					v.addf("profile_predictor_config: column '%v' is reserved for the watchlist lookups", col)
+				}
+			}
+		}
+	}
+	validateAlertTime(v, c)
+	validateSuppression(v, c)
+	return v.err()
+}
+
+func validateSuppression(v *validator, c *RuleJobConfig) {
+	if !c.HasSuppression() {
+		return
+	}
+	if c.Suppression.Duration == "" {
+		v.addf("suppression.duration is required")
+	} else if d, err := c.GetSuppressionDuration(); err != nil || d <= 0 {
+		v.addf("suppression.duration '%v' is not a valid duration", c.Suppression.Duration)
+	}
+	if c.Suppression.MaxAlerts < 0 {
+		v.addf("suppression.max_alerts must not be negative")
+	}
+	if c.ProfilePredictorOutput == nil {
+		return
+	}
+	columns := c.ProfilePredictorOutput.Columns()
+	for i, f := range c.Suppression.GroupBy {
+		typeStr, ok := columns[f]
+		if !ok {
+			v.addf("suppression.group_by[%v]: field '%v' is not in the schema", i, f)
+			continue
+		}
+		if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("suppression.group_by[%v]: field '%v' has complex type %v", i, f, t)
+		}
+	}
+}
+
+func validateAlertTime(v *validator, c *RuleJobConfig) {
+	switch source := c.GetAlertTimeSource(); source {
+	case AlertTimeProcessing:
+	case AlertTimeEvent:
+		if c.ProfilePredictorOutput != nil {
+			validateEventTime(v, "profile_predictor_config", c.ProfilePredictorOutput)
+		}
+	default:
+		v.addf("alert_time.source '%v' is not supported, use %v or %v", source, AlertTimeProcessing, AlertTimeEvent)
+	}
+	if tz := c.GetAlertTimezone(); !isValidSessionTimezone(tz) {
+		v.addf("alert_time.timezone '%v' is not a valid time zone, use a name such as Asia/Ho_Chi_Minh or an offset such as GMT+07:00", tz)
+	}
+}
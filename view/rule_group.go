@@ -0,0 +1,89 @@
+package view
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// ruleGroupIDLength is the number of hex digits of the source fingerprint naming a rule group
+const ruleGroupIDLength = 12
+
+// GroupRules groups the rules by profile predictor source, so each group reads its source once. Rules only share a
+// source when their predictor configs are identical and their alerts are rendered in the same session time zone.
+// The groups and the rules of each group are sorted by ID
+func GroupRules(rules []*RuleJobConfig) []*RuleGroup {
+	groups := make(map[string]*RuleGroup)
+	for _, rule := range rules {
+		id := rule.sourceFingerprint()
+		group, ok := groups[id]
+		if !ok {
+			group = &RuleGroup{ID: id, Topic: rule.ProfilePredictorOutput.Topic}
+			groups[id] = group
+		}
+		group.Rules = append(group.Rules, rule)
+	}
+	sorted := make([]*RuleGroup, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group.Rules, func(i, j int) bool { return group.Rules[i].ID < group.Rules[j].ID })
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// sourceFingerprint identifies the predictor source of the rule and the time zone of its session
+func (c *RuleJobConfig) sourceFingerprint() string {
+	return fingerprint(struct {
+		Source   *kafkaConfig `json:"source"`
+		Timezone string       `json:"timezone"`
+	}{c.ProfilePredictorOutput, c.GetAlertTimezone()})[:ruleGroupIDLength]
+}
+
+// RuleIDs returns the IDs of the rules of the group
+func (g *RuleGroup) RuleIDs() []string {
+	ids := make([]string, 0, len(g.Rules))
+	for _, rule := range g.Rules {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+// Fingerprint identifies the rules of the group and their configs, so a group is redeployed when a rule joins
+// or leaves it or when one of its rules changes
+func (g *RuleGroup) Fingerprint() string {
+	return fingerprint(g.Rules)
+}
+
+// HasWatchlistLookups reports whether a rule of the group tests watchlists
+func (g *RuleGroup) HasWatchlistLookups() bool {
+	for _, rule := range g.Rules {
+		if lookups, err := rule.WatchlistLookups(); err == nil && len(lookups) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// SuppressionTimeFields returns the time attributes the rules of the group suppress their alerts on
+func (g *RuleGroup) SuppressionTimeFields() []string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, rule := range g.Rules {
+		if !rule.HasSuppression() || seen[rule.SuppressionTimeField()] {
+			continue
+		}
+		seen[rule.SuppressionTimeField()] = true
+		fields = append(fields, rule.SuppressionTimeField())
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// fingerprint hashes the JSON of a config, map keys are sorted by encoding/json so equal configs hash the same
+func fingerprint(v interface{}) string {
+	data, _ := json.Marshal(v)
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package view
+
+import (
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// severityLevel returns the level of a severity given by name, alias or 1-based rank, ignoring case
+func severityLevel(severity string) (*config.SeverityLevel, bool) {
+	levels := config.AppConfig.Severity.Levels
+	severity = strings.TrimSpace(severity)
+	if rank, err := strconv.Atoi(severity); err == nil {
+		if rank < 1 || rank > len(levels) {
+			return nil, false
+		}
+		return levels[rank-1], true
+	}
+	for _, level := range levels {
+		if strings.EqualFold(level.Name, severity) {
+			return level, true
+		}
+		for _, alias := range level.Aliases {
+			if strings.EqualFold(alias, severity) {
+				return level, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// NormalizeSeverity returns the name of the level of a severity, so High, HIGH and 4 all become high. A severity
+// outside the taxonomy is returned unchanged
+func NormalizeSeverity(severity string) string {
+	if level, ok := severityLevel(severity); ok {
+		return level.Name
+	}
+	return severity
+}
+
+// SeverityNames returns the names of the levels of the taxonomy, from the lowest
+func SeverityNames() []string {
+	var names []string
+	for _, level := range config.AppConfig.Severity.Levels {
+		names = append(names, level.Name)
+	}
+	return names
+}
+
+// riskScoreExpression renders the risk score of the alerts of a rule: its risk score, or the score of its severity
+// when it has none, scaled by the risk factors and bounded by the maximum risk score. column renders the reference
+// to a column of the alerts
+func riskScoreExpression(severity string, riskScore int, factors []*riskFactorConfig, column func(string) string) string {
+	score := riskScore
+	if score == 0 {
+		if level, ok := severityLevel(severity); ok {
+			score = level.Score
+		}
+	}
+	if len(factors) == 0 {
+		return fmt.Sprintf("CAST(%v AS BIGINT)", score)
+	}
+	terms := []string{fmt.Sprintf("CAST(%v AS DOUBLE)", score)}
+	for _, f := range factors {
+		terms = append(terms, f.expression(column(f.Field)))
+	}
+	return fmt.Sprintf("CAST(LEAST(GREATEST(ROUND(%v, 0), 0), %v) AS BIGINT)",
+		strings.Join(terms, " * "), config.AppConfig.Severity.MaxRiskScore)
+}
+
+// GetDefault returns the multiplier of the values without factor
+func (f *riskFactorConfig) GetDefault() float64 {
+	if f.Default == nil {
+		return 1
+	}
+	return *f.Default
+}
+
+// expression renders the multiplier of the score for the value of the field: its factor, or the value itself
+// for a numeric field without factors
+func (f *riskFactorConfig) expression(field string) string {
+	defaultFactor := formatFactor(f.GetDefault())
+	if len(f.Factors) == 0 {
+		return fmt.Sprintf("COALESCE(CAST(%v AS DOUBLE), %v)", field, defaultFactor)
+	}
+	values := make([]string, 0, len(f.Factors))
+	for value := range f.Factors {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	builder := sql_builder.NewCaseExpSQLBuilder(fmt.Sprintf("CAST(%v AS STRING)", field))
+	for _, value := range values {
+		builder.WithWhen(sql_builder.QuoteLiteral(value), formatFactor(f.Factors[value]))
+	}
+	return builder.WithElse(defaultFactor).Build()
+}
+
+// formatFactor renders a multiplier as a DOUBLE literal
+func formatFactor(factor float64) string {
+	return fmt.Sprintf("CAST(%v AS DOUBLE)", strconv.FormatFloat(factor, 'f', -1, 64))
+}
+
+// validateSeverity checks the severity is a level of the taxonomy
+func validateSeverity(v *validator, severity string) {
+	if severity == "" {
+		v.addf("severity is required")
+	} else if _, ok := severityLevel(severity); !ok {
+		v.addf("severity '%v' is not in the severity taxonomy, use one of %v or a rank from 1 to %v",
+			severity, strings.Join(SeverityNames(), ", "), len(SeverityNames()))
+	}
+}
+
+// validateRiskScore checks the risk score is within the maximum risk score and the risk factors scale it by columns
+// of the alerts, numeric columns when they have no factors
+func validateRiskScore(v *validator, riskScore int, factors []*riskFactorConfig, columns map[string]string) {
+	if maxScore := config.AppConfig.Severity.MaxRiskScore; riskScore < 0 || riskScore > maxScore {
+		v.addf("risk_score %v must be between 0 and %v", riskScore, maxScore)
+	}
+	for i, f := range factors {
+		name := fmt.Sprintf("risk_score_factors[%v]", i)
+		if f == nil || f.Field == "" {
+			v.addf("%v: field is required", name)
+			continue
+		}
+		for value, factor := range f.Factors {
+			if factor < 0 {
+				v.addf("%v: factor %v of value '%v' must not be negative", name, factor, value)
+			}
+		}
+		if f.GetDefault() < 0 {
+			v.addf("%v: default %v must not be negative", name, f.GetDefault())
+		}
+		if columns == nil {
+			continue
+		}
+		typeStr, ok := columns[f.Field]
+		if !ok {
+			v.addf("%v: field '%v' is not a column of the alerts", name, f.Field)
+			continue
+		}
+		if t, err := data_type.Parse(typeStr); err == nil && t.IsComplex() {
+			v.addf("%v: field '%v' has complex type %v", name, f.Field, t)
+		} else if err == nil && len(f.Factors) == 0 && !t.IsNumeric() {
+			v.addf("%v: field '%v' must be numeric to scale the score by its value but is %v, map its values with factors", name, f.Field, t)
+		}
+	}
+}
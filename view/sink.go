@@ -0,0 +1,291 @@
+package view
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/util"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	SinkKafka         = "kafka"
+	SinkUpsertKafka   = "upsert-kafka"
+	SinkElasticsearch = "elasticsearch"
+	SinkOpenSearch    = "opensearch"
+	SinkJDBC          = "jdbc"
+	SinkFilesystem    = "filesystem"
+
+	defaultElasticsearchVersion = 7
+)
+
+// GetType returns the connector of the output, kafka by default
+func (c *sinkConfig) GetType() string {
+	if c.Type == "" {
+		return SinkKafka
+	}
+	return strings.ToLower(c.Type)
+}
+
+// IsUpsert reports whether the output writes the latest row of each primary key rather than appending rows
+func (c *sinkConfig) IsUpsert() bool {
+	return c.GetType() == SinkUpsertKafka
+}
+
+// Connector returns the connector of the output with its options. Passwords are rendered as secret placeholders
+func (c *sinkConfig) Connector() (sql_builder.ConnectorBuilder, error) {
+	switch c.GetType() {
+	case SinkElasticsearch, SinkOpenSearch:
+		return c.elasticsearchConnector()
+	case SinkJDBC:
+		return c.jdbcConnector()
+	case SinkFilesystem:
+		return c.filesystemConnector()
+	case SinkUpsertKafka:
+		builder := sql_builder.NewUpsertKafkaConnectorBuilder()
+		builder.
+			WithTopic(c.Topic).
+			WithBootstrapServers(c.BootstrapServer).
+			WithKeyFormat(c.KeyFormat.builder(false)).
+			WithValueFormat(c.FormatBuilder(false))
+		c.ApplySecurity(builder)
+		return builder, nil
+	default:
+		builder := sql_builder.NewKafkaConnectorBuilder()
+		builder.
+			WithTopic(c.Topic).
+			WithBootstrapServers(c.BootstrapServer).
+			WithFormat(c.FormatBuilder(false))
+		c.ApplySecurity(builder)
+		return builder, nil
+	}
+}
+
+func (c *sinkConfig) elasticsearchConnector() (sql_builder.ConnectorBuilder, error) {
+	cfg := c.Elasticsearch
+	var builder sql_builder.ElasticsearchConnectorBuilder
+	if c.GetType() == SinkOpenSearch {
+		builder = sql_builder.NewOpenSearchConnectorBuilder()
+	} else {
+		version := cfg.Version
+		if version == 0 {
+			version = defaultElasticsearchVersion
+		}
+		builder = sql_builder.NewElasticsearchConnectorBuilder(version)
+	}
+	builder.
+		WithHosts(cfg.Hosts...).
+		WithIndex(cfg.Index)
+	if cfg.Username != "" {
+		builder.
+			WithUsername(cfg.Username).
+			WithPassword(secretPlaceholder(cfg.Password))
+	}
+	if cfg.BulkFlushMaxActions > 0 {
+		builder.WithBulkFlushMaxActions(cfg.BulkFlushMaxActions)
+	}
+	if cfg.BulkFlushInterval != "" {
+		interval, err := util.ParseDurationExtended(cfg.BulkFlushInterval)
+		if err != nil {
+			return nil, err
+		}
+		builder.WithBulkFlushInterval(interval)
+	}
+	return builder, nil
+}
+
+func (c *sinkConfig) jdbcConnector() (sql_builder.JDBCConnectorBuilder, error) {
+	cfg := c.JDBC
+	builder := sql_builder.NewJDBCConnectorBuilder()
+	builder.
+		WithURL(cfg.URL).
+		WithTableName(cfg.Table)
+	if cfg.Driver != "" {
+		builder.WithDriver(cfg.Driver)
+	}
+	if cfg.Username != "" {
+		builder.
+			WithUsername(cfg.Username).
+			WithPassword(secretPlaceholder(cfg.Password))
+	}
+	if cfg.BufferFlushMaxRows > 0 {
+		builder.WithBufferFlushMaxRows(cfg.BufferFlushMaxRows)
+	}
+	if cfg.BufferFlushInterval != "" {
+		interval, err := util.ParseDurationExtended(cfg.BufferFlushInterval)
+		if err != nil {
+			return nil, err
+		}
+		builder.WithBufferFlushInterval(interval)
+	}
+	if cfg.MaxRetries > 0 {
+		builder.WithMaxRetries(cfg.MaxRetries)
+	}
+	return builder, nil
+}
+
+func (c *sinkConfig) filesystemConnector() (sql_builder.ConnectorBuilder, error) {
+	cfg := c.Filesystem
+	builder := sql_builder.NewFilesystemConnectorBuilder()
+	builder.
+		WithPath(cfg.Path).
+		WithFormat(cfg.formatBuilder())
+	if cfg.RollingFileSize != "" {
+		builder.WithRollingFileSize(cfg.RollingFileSize)
+	}
+	if cfg.RolloverInterval != "" {
+		interval, err := util.ParseDurationExtended(cfg.RolloverInterval)
+		if err != nil {
+			return nil, err
+		}
+		builder.WithRolloverInterval(interval)
+	}
+	return builder, nil
+}
+
+// GetFormat returns the file format, parquet by default
+func (c *filesystemConfig) GetFormat() string {
+	if c.Format == "" {
+		return sql_builder.FormatParquet
+	}
+	return strings.ToLower(c.Format)
+}
+
+func (c *filesystemConfig) formatBuilder() sql_builder.FormatBuilder {
+	switch c.GetFormat() {
+	case sql_builder.FormatAvro:
+		return sql_builder.NewAvroFormatBuilder()
+	case sql_builder.FormatCSV:
+		return sql_builder.NewCSVFormatBuilder()
+	case sql_builder.FormatJSON:
+		return sql_builder.NewJSONFormatBuilder()
+	default:
+		builder := sql_builder.NewParquetFormatBuilder()
+		if c.Compression != "" {
+			builder.WithCompression(strings.ToUpper(c.Compression))
+		}
+		return builder
+	}
+}
+
+// validateSink checks an output against its connector. columns are the columns the output takes from the source,
+// the other columns of the outputs being of simple types. keyed tells whether the output has a primary key
+func validateSink(v *validator, name string, cfg *sinkConfig, columns map[string]string, keyed bool) {
+	if cfg == nil {
+		v.addf("%v is required", name)
+		return
+	}
+	switch sinkType := cfg.GetType(); sinkType {
+	case SinkKafka:
+		validateKafkaConfig(v, name, &cfg.kafkaConfig, false)
+	case SinkUpsertKafka:
+		if !keyed {
+			v.addf("%v: the upsert-kafka sink requires a primary key, which only profile and risk snapshot outputs have", name)
+		}
+		validateKafkaConfig(v, name, &cfg.kafkaConfig, false)
+		validateFormat(v, name+".key_format", cfg.KeyFormat, nil, false)
+	case SinkElasticsearch, SinkOpenSearch:
+		validateElasticsearchSink(v, name, cfg)
+	case SinkJDBC:
+		validateJDBCSink(v, name, cfg.JDBC)
+		validateSimpleColumns(v, name, "the jdbc sink", columns)
+	case SinkFilesystem:
+		validateFilesystemSink(v, name, cfg.Filesystem)
+		if cfg.Filesystem != nil && cfg.Filesystem.GetFormat() == sql_builder.FormatCSV {
+			validateSimpleColumns(v, name, "the csv format", columns)
+		}
+	default:
+		v.addf("%v.type '%v' is not supported", name, sinkType)
+	}
+}
+
+// dynamicIndexRegexp matches the placeholders of dynamic indexes such as {alert_time|yyyy-MM-dd}
+var dynamicIndexRegexp = regexp.MustCompile(`\{[^}]*\}`)
+
+func validateElasticsearchSink(v *validator, name string, cfg *sinkConfig) {
+	es := cfg.Elasticsearch
+	if es == nil {
+		v.addf("%v.elasticsearch is required for the %v sink", name, cfg.GetType())
+		return
+	}
+	name += ".elasticsearch"
+	if len(es.Hosts) == 0 {
+		v.addf("%v.hosts is required", name)
+	}
+	for _, host := range es.Hosts {
+		if u, err := url.Parse(host); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.addf("%v.hosts: '%v' is not a valid http(s) URL", name, host)
+		}
+	}
+	if es.Index == "" {
+		v.addf("%v.index is required", name)
+	} else if static := dynamicIndexRegexp.ReplaceAllString(es.Index, ""); static != strings.ToLower(static) {
+		v.addf("%v.index '%v' must be lowercase", name, es.Index)
+	}
+	if cfg.GetType() == SinkElasticsearch && es.Version != 0 && es.Version != 6 && es.Version != 7 {
+		v.addf("%v.version %v is not supported, use 6 or 7", name, es.Version)
+	}
+	validateCredentials(v, name, es.Username, es.Password)
+	validateDuration(v, name+".bulk_flush_interval", es.BulkFlushInterval)
+}
+
+func validateJDBCSink(v *validator, name string, cfg *jdbcConfig) {
+	if cfg == nil {
+		v.addf("%v.jdbc is required for the jdbc sink", name)
+		return
+	}
+	name += ".jdbc"
+	if !strings.HasPrefix(cfg.URL, "jdbc:") {
+		v.addf("%v.url must be a jdbc: URL", name)
+	}
+	if cfg.Table == "" {
+		v.addf("%v.table is required", name)
+	}
+	validateCredentials(v, name, cfg.Username, cfg.Password)
+	validateDuration(v, name+".buffer_flush_interval", cfg.BufferFlushInterval)
+	if cfg.MaxRetries < 0 {
+		v.addf("%v.max_retries must not be negative", name)
+	}
+}
+
+var fileSizeRegexp = regexp.MustCompile(`(?i)^\d+\s*(b|kb|mb|gb|tb|k|m|g|t|bytes|kibibytes|mebibytes|gibibytes|tebibytes)?$`)
+
+func validateFilesystemSink(v *validator, name string, cfg *filesystemConfig) {
+	if cfg == nil {
+		v.addf("%v.filesystem is required for the filesystem sink", name)
+		return
+	}
+	name += ".filesystem"
+	if u, err := url.Parse(cfg.Path); cfg.Path == "" || err != nil || u.Scheme == "" {
+		v.addf("%v.path must be a URI with a scheme such as s3://bucket/dir or file:///dir", name)
+	}
+	switch format := cfg.GetFormat(); format {
+	case sql_builder.FormatParquet, sql_builder.FormatAvro, sql_builder.FormatCSV, sql_builder.FormatJSON:
+		if cfg.Compression != "" && format != sql_builder.FormatParquet {
+			v.addf("%v.compression is only supported by the parquet format", name)
+		}
+	default:
+		v.addf("%v.format '%v' is not supported", name, cfg.Format)
+	}
+	if cfg.RollingFileSize != "" && !fileSizeRegexp.MatchString(cfg.RollingFileSize) {
+		v.addf("%v.rolling_file_size '%v' is not a valid size", name, cfg.RollingFileSize)
+	}
+	validateDuration(v, name+".rollover_interval", cfg.RolloverInterval)
+}
+
+// validateSimpleColumns rejects ARRAY, MAP and ROW columns for connectors and formats that cannot write them
+func validateSimpleColumns(v *validator, name string, what string, columns map[string]string) {
+	for _, col := range sortedKeys(columns) {
+		if t, err := data_type.Parse(columns[col]); err == nil && t.IsComplex() {
+			v.addf("%v: column '%v' has complex type %v which %v cannot write", name, col, t, what)
+		}
+	}
+}
+
+func validateCredentials(v *validator, name string, username string, password string) {
+	if username == "" && password != "" {
+		v.addf("%v.username is required when a password is set", name)
+	}
+	validateSecretRef(v, name+".password", password, username != "")
+}
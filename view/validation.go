@@ -0,0 +1,150 @@
+package view
+
+import (
+	"flink_ueba_manager/util"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// EventTimeField is the column holding the parsed event time of a log source
+const EventTimeField = "converted_ts"
+
+type (
+	// ValidationError holds every problem found in a job config
+	ValidationError struct {
+		Problems []string
+	}
+	validator struct {
+		problems []string
+	}
+)
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid job config: %v", strings.Join(e.Problems, "; "))
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+func validateDuration(v *validator, name string, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := util.ParseDurationExtended(value); err != nil || d <= 0 {
+		v.addf("%v '%v' is not a valid duration", name, value)
+	}
+}
+
+// validateSecretRef makes sure secrets are references, so their values never show up in plans or logs
+func validateSecretRef(v *validator, name string, value string, required bool) {
+	if value == "" {
+		if required {
+			v.addf("%v is required", name)
+		}
+		return
+	}
+	if !util.IsSecretRef(value) {
+		v.addf("%v must be a secret reference such as env:NAME or file:PATH, PATH relative to the secrets directory", name)
+	}
+}
+
+// validateNoSecretPlaceholders rejects secret placeholders in every string of a config. Placeholders are rendered
+// from secret references only, one written by hand would be resolved into the statement
+func validateNoSecretPlaceholders(v *validator, cfg interface{}) {
+	walkStrings(reflect.ValueOf(cfg), "", func(path string, value string) {
+		if util.ContainsSecretPlaceholder(value) {
+			v.addf("%v must not contain a secret placeholder", path)
+		}
+	})
+}
+
+func walkStrings(value reflect.Value, path string, visit func(path string, value string)) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !value.IsNil() {
+			walkStrings(value.Elem(), path, visit)
+		}
+	case reflect.String:
+		visit(path, value.String())
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" || field.Anonymous {
+				walkStrings(value.Field(i), path, visit)
+				continue
+			}
+			walkStrings(value.Field(i), joinPath(path, name), visit)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			walkStrings(value.Index(i), fmt.Sprintf("%v[%v]", path, i), visit)
+		}
+	case reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			keyPath := joinPath(path, fmt.Sprint(key))
+			if key.Kind() == reflect.String {
+				visit(keyPath, key.String())
+			}
+			walkStrings(value.MapIndex(key), keyPath, visit)
+		}
+	}
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+var offsetTimezoneRegexp = regexp.MustCompile(`^(UTC|GMT)?[+-]\d{1,2}(:\d{2})?$`)
+
+// sessionTimezoneRegexp matches the custom time zone ids accepted by table.local-time-zone besides zone names
+var sessionTimezoneRegexp = regexp.MustCompile(`^GMT[+-]\d{2}:\d{2}$`)
+
+func isValidSessionTimezone(tz string) bool {
+	if sessionTimezoneRegexp.MatchString(tz) {
+		return true
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil && !offsetTimezoneRegexp.MatchString(tz)
+}
+
+func isValidTimezone(tz string) bool {
+	if offsetTimezoneRegexp.MatchString(tz) {
+		return true
+	}
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
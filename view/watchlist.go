@@ -0,0 +1,141 @@
+package view
+
+import (
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/util"
+	"github.com/pkg/errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// Watchlist is a list of values maintained by analysts, such as privileged accounts, critical servers or bad
+	// IPs, that rule filters test fields against with field IN WATCHLIST('name')
+	Watchlist struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		// Kind labels the values of the list, such as account, host or ip
+		Kind   string   `json:"kind"`
+		Values []string `json:"values"`
+		// Version is incremented by every change of the list
+		Version   int64     `json:"version"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+	// WatchlistRequest creates a watchlist or replaces its values
+	WatchlistRequest struct {
+		// Name is only read when the watchlist is created
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Kind        string   `json:"kind"`
+		Values      []string `json:"values"`
+		// Version is the version the change is based on, the change is rejected when the watchlist has changed
+		// since. Zero skips the check
+		Version int64 `json:"version"`
+	}
+	// WatchlistPatch adds values to a watchlist and removes values from it
+	WatchlistPatch struct {
+		Add     []string `json:"add"`
+		Remove  []string `json:"remove"`
+		Version int64    `json:"version"`
+	}
+)
+
+const (
+	// WatchlistNameField and WatchlistValueField are the columns of the watchlist table, the database table the
+	// manager materializes the watchlists to
+	WatchlistNameField  = "watchlist_name"
+	WatchlistValueField = "watchlist_value"
+)
+
+// watchlistNameRegexp matches the names of watchlists, stored in the watchlist_name column of the table
+var watchlistNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,127}$`)
+
+// ValidateWatchlistName checks the name can be referenced from filters
+func ValidateWatchlistName(name string) error {
+	v := &validator{}
+	if !watchlistNameRegexp.MatchString(name) {
+		v.addf("name '%v' must start with a letter or a digit and contain only letters, digits, '_', '.' and '-', up to 128 characters", name)
+	}
+	return v.err()
+}
+
+// Validate checks the values of the request
+func (r *WatchlistRequest) Validate() error {
+	v := &validator{}
+	validateWatchlistValues(v, "values", r.Values)
+	return v.err()
+}
+
+// Validate checks the values of the patch
+func (p *WatchlistPatch) Validate() error {
+	v := &validator{}
+	if len(p.Add) == 0 && len(p.Remove) == 0 {
+		v.addf("add or remove is required")
+	}
+	validateWatchlistValues(v, "add", p.Add)
+	validateWatchlistValues(v, "remove", p.Remove)
+	return v.err()
+}
+
+func validateWatchlistValues(v *validator, name string, values []string) {
+	for i, value := range values {
+		if strings.TrimSpace(value) == "" {
+			v.addf("%v[%v] must not be empty", name, i)
+		}
+	}
+}
+
+// NormalizeWatchlistValues returns the values sorted, without duplicates
+func NormalizeWatchlistValues(values []string) []string {
+	set := make(map[string]struct{}, len(values))
+	normalized := make([]string, 0, len(values))
+	for _, value := range values {
+		if _, ok := set[value]; ok {
+			continue
+		}
+		set[value] = struct{}{}
+		normalized = append(normalized, value)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// WatchlistLookups compiles the filter of the rule and returns its watchlist lookups, none for a raw filter
+func (c *RuleJobConfig) WatchlistLookups() ([]*filter.WatchlistLookup, error) {
+	if c.RawFilter {
+		return nil, nil
+	}
+	compiler := filter.NewCompiler(c.ProfilePredictorOutput.Columns()).WithWatchlists()
+	if _, err := compiler.Compile(c.Filter); err != nil {
+		return nil, err
+	}
+	return compiler.Watchlists(), nil
+}
+
+// WatchlistConnector returns the connector of the watchlist table, which rule jobs look the watchlist values up
+// in. The looked up values are cached, so an update of a watchlist takes effect within the cache ttl
+func WatchlistConnector() (sql_builder.ConnectorBuilder, error) {
+	cfg := config.AppConfig.Watchlist
+	if !cfg.HasTable() {
+		return nil, errors.New("the watchlist table is not configured, set watchlist.url in the manager config")
+	}
+	ttl, err := util.ParseDurationExtended(cfg.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
+	builder := sql_builder.NewJDBCConnectorBuilder()
+	builder.
+		WithURL(cfg.URL).
+		WithTableName(cfg.Table).
+		WithLookupCache(cfg.CacheMaxRows, ttl)
+	if cfg.Username != "" {
+		builder.
+			WithUsername(cfg.Username).
+			WithPassword(secretPlaceholder(cfg.Password))
+	}
+	return builder, nil
+}
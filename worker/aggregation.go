@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// AggregationJobWorker runs an aggregation rule, comparing windowed aggregates of source events to a threshold
+	AggregationJobWorker struct {
+		ID         string
+		cfg        *view.AggregationJobConfig
+		flinkJobID string
+		logger     *logrus.Entry
+	}
+)
+
+func NewAggregationJobWorker(ID string, cfg *view.AggregationJobConfig) *AggregationJobWorker {
+	return &AggregationJobWorker{
+		ID:     ID,
+		cfg:    cfg,
+		logger: logrus.WithField("aggregation_job", ID),
+	}
+}
+
+func (s *AggregationJobWorker) Stop() error {
+	return nil
+}
+
+func (s *AggregationJobWorker) Run() error {
+	plan, err := s.Plan()
+	if err != nil {
+		return err
+	}
+	jobID, err := submitPlan(s.logger, plan)
+	if err != nil {
+		return err
+	}
+	s.flinkJobID = jobID
+	s.logger.Infof("done creating flink job %v", jobID)
+	return nil
+}
+
+// Plan renders the ordered statements of the job: source and sink tables, views, settings and the statement set
+func (s *AggregationJobWorker) Plan() ([]string, error) {
+	return renderPlan(
+		s.buildSource,
+		s.buildInput,
+		s.buildAggregation,
+		s.buildAlertSink,
+		setIdleTimeout(s.cfg.SourceConfig),
+		setTimezone("UTC"),
+		s.buildSetName,
+		s.buildJob,
+	)
+}
+
+func (s *AggregationJobWorker) buildSource() (string, error) {
+	cfg := s.cfg.SourceConfig
+	return buildKafkaSource(getAggregationSourceIDFrom(s.cfg.ID), cfg, jobStartup(cfg, "aggregation", s.cfg.ID), eventTimeColumn(cfg, timestampField))
+}
+
+// buildInput filters the source events aggregated, it is skipped when every event is aggregated
+func (s *AggregationJobWorker) buildInput() (string, error) {
+	if s.cfg.Filter == "" {
+		return "", nil
+	}
+	filterStr := s.cfg.Filter
+	if !s.cfg.RawFilter {
+		var err error
+		filterStr, err = filter.NewCompiler(s.cfg.FilterSchema()).Compile(s.cfg.Filter)
+		if err != nil {
+			return "", err
+		}
+	}
+	expBuilder := sql_builder.NewFilterExpSQLBuilder()
+	expBuilder.
+		WithFilter(filterStr).
+		WithFields("*").
+		WithQueryTable(getAggregationSourceIDFrom(s.cfg.ID))
+	stmStr := sql_builder.NewViewSQLBuilder(getAggregationInputIDFrom(s.cfg.ID)).
+		WithExpression(expBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *AggregationJobWorker) buildAggregation() (string, error) {
+	size, err := s.cfg.GetWindow()
+	if err != nil {
+		return "", err
+	}
+	slide, err := s.cfg.GetSlide()
+	if err != nil {
+		return "", err
+	}
+	input := getAggregationSourceIDFrom(s.cfg.ID)
+	if s.cfg.Filter != "" {
+		input = getAggregationInputIDFrom(s.cfg.ID)
+	}
+	window := sql_builder.NewWindowTableSQLBuilder().
+		WithQueryTable(input).
+		WithTimeField(timestampField).
+		WithSize(size).
+		WithSlide(slide).
+		Build()
+	expStr := sql_builder.NewAggregationExpSQLBuilder().
+		WithWindowTable(window).
+		WithGroupBy(s.cfg.GroupBy...).
+		WithAggregate(view.AggregateValueField, s.cfg.AggregateExpression()).
+		WithCondition(s.cfg.OperatorSQL(), s.cfg.Threshold).
+		Build()
+	stmStr := sql_builder.NewViewSQLBuilder(getAggregationIDFrom(s.cfg.ID)).
+		WithExpression(expStr).
+		Build()
+	return stmStr, nil
+}
+
+func (s *AggregationJobWorker) buildAlertSink() (string, error) {
+	id := getAggregationSinkIDFrom(s.cfg.ID)
+	tableBuilder := sql_builder.NewTableSQLBuilder(id)
+	// build schema
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	for _, col := range s.cfg.AlertColumns(getAggregationIDFrom(s.cfg.ID)) {
+		schemaBuilder.WithColumn(col.Name, col.Type)
+	}
+
+	connectorBuilder, err := s.cfg.RuleOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
+
+	stmStr := tableBuilder.
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *AggregationJobWorker) buildSetName() (string, error) {
+	return buildSetConfig("pipeline.name", fmt.Sprintf("aggregation_%v", s.cfg.ID)), nil
+}
+
+func (s *AggregationJobWorker) buildJob() (string, error) {
+	aggregationID := getAggregationIDFrom(s.cfg.ID)
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	var columns []string
+	for _, col := range s.cfg.AlertColumns(aggregationID) {
+		projectionBuilder.WithField(col.Name, col.Expression)
+		columns = append(columns, col.Name)
+	}
+	expStr := sql_builder.NewSelectSQLBuilder().
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(aggregationID).
+		Build()
+	insertStm := sql_builder.NewInsertSQLBuilder().
+		WithDestinationTable(getAggregationSinkIDFrom(s.cfg.ID)).
+		WithColumns(columns...).
+		WithExpression(expStr).
+		Build()
+	stmStr := sql_builder.NewStatementSetSQLBuilder().
+		WithInsertStatement(insertStm).
+		Build()
+	return stmStr, nil
+}
+
+func getAggregationSourceIDFrom(ID string) string {
+	return "aggregation_source_" + ID
+}
+
+func getAggregationInputIDFrom(ID string) string {
+	return "aggregation_input_" + ID
+}
+
+func getAggregationIDFrom(ID string) string {
+	return "aggregation_" + ID
+}
+
+func getAggregationSinkIDFrom(ID string) string {
+	return "aggregation_sink_" + ID
+}
@@ -1,34 +1,46 @@
 package worker
 
 import (
-	"flink_ueba_manager/config"
-	"flink_ueba_manager/external"
 	"flink_ueba_manager/sql_builder"
 	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/sql_builder/filter"
 	"flink_ueba_manager/view"
 	"fmt"
-	"sort"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strings"
 )
 
 const (
-	timestampField = "converted_ts"
+	timestampField = view.EventTimeField
+)
+
+var (
+	// profileKey is the primary key of profiles written to upsert outputs
+	profileKey = []string{"entities", "attributes", "window_start"}
+	// profileColumns are the columns of the profiling sink, the profile view also has the window_time attribute
+	// and the aggregates of the profile
+	profileColumns = []string{"window_start", "window_end", "cnt", "entities", "attributes"}
 )
 
 type (
 	IFlinkSQLWorker interface {
 		Run() error
+		Plan() ([]string, error)
 	}
 	BehaviorJobWorker struct {
 		ID         string
 		cfg        *view.BehaviorJobConfig
 		flinkJobID string
+		logger     *logrus.Entry
 	}
 )
 
 func NewBehaviorJobWorker(ID string, cfg *view.BehaviorJobConfig) *BehaviorJobWorker {
 	return &BehaviorJobWorker{
-		ID:  ID,
-		cfg: cfg,
+		ID:     ID,
+		cfg:    cfg,
+		logger: logrus.WithField("behavior_job", ID),
 	}
 }
 
@@ -37,120 +49,163 @@ func (s *BehaviorJobWorker) Stop() error {
 }
 
 func (s *BehaviorJobWorker) Run() error {
-	err := s.createLogSource()
-	if err != nil {
-		return err
-	}
-	err = s.createBehavior()
-	if err != nil {
-		return err
-	}
-	err = s.createProfileBatch()
-	if err != nil {
-		return err
-	}
-	err = s.createBehaviorSink()
-	if err != nil {
-		return err
-	}
-	err = s.createProfilingSink()
-	if err != nil {
-		return err
-	}
-	err = s.setName()
+	plan, err := s.Plan()
 	if err != nil {
 		return err
 	}
-	jobID, err := s.createJob()
+	jobID, err := submitPlan(s.logger, plan)
 	if err != nil {
 		return err
 	}
 	s.flinkJobID = jobID
-	fmt.Println("Done creating job")
+	s.logger.Infof("done creating flink job %v", jobID)
 	return nil
 }
 
-func (s *BehaviorJobWorker) createLogSource() error {
-	id := getLogSourceIDFrom(s.cfg.ID)
-	tableBuilder := sql_builder.NewTableSQLBuilder(id)
-	// build schema
-	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
-	for _, v := range getOrderedSchema(s.cfg.LogSourceConfig.Schema) {
-		schemaBuilder.WithColumn(v[0], v[1])
+// Plan renders the ordered statements of the job: source and sink tables, views, settings and the statement set
+func (s *BehaviorJobWorker) Plan() ([]string, error) {
+	builders := []func() (string, error){s.buildLogSource}
+	for _, obj := range s.cfg.ReferenceObjects() {
+		builders = append(builders, s.buildReference(obj))
 	}
-	schemaBuilder.WithTimestampField(s.cfg.LogSourceConfig.TimestampField, timestampField)
-	// build connector
-	connectorBuilder := sql_builder.NewConnectorBuilder()
-	connectorBuilder.
-		WithOption("connector", "kafka").
-		WithOption("topic", s.cfg.LogSourceConfig.Topic).
-		WithOption("properties.bootstrap.servers", s.cfg.LogSourceConfig.BootstrapServer).
-		WithOption("properties.group.id", config.AppConfig.KafkaGroupID).
-		WithOption("scan.startup.mode", "earliest-offset").
-		WithOption("format", "json").
-		WithOption("json.timestamp-format.standard", "ISO-8601").
-		WithOption("json.ignore-parse-errors", "true").
-		WithOption("json.fail-on-missing-field", "false")
+	return renderPlan(append(builders,
+		s.buildBehavior,
+		s.buildEnrichment,
+		s.buildProfileBatch,
+		s.buildBehaviorSink,
+		s.buildProfilingSink,
+		s.buildAnomaly,
+		s.buildAnomalySink,
+		setIdleTimeout(s.cfg),
+		setTimezone("UTC"),
+		s.buildSetName,
+		s.buildJob,
+	)...)
+}
 
-	stmStr := tableBuilder.
-		WithSchema(schemaBuilder.Build()).
-		WithConnector(connectorBuilder.Build()).
-		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
+func (s *BehaviorJobWorker) buildLogSource() (string, error) {
+	cfg := s.cfg.LogSourceConfig
+	columns := []sourceColumn{eventTimeColumn(cfg, timestampField)}
+	if s.cfg.HasLookup() {
+		columns = append(columns, procTimeColumn(view.LookupProcTimeField))
 	}
-	return nil
+	return buildKafkaSource(getLogSourceIDFrom(s.cfg.ID), cfg, jobStartup(cfg, "behavior", s.cfg.ID), columns...)
 }
 
-func (s *BehaviorJobWorker) createBehavior() error {
+func (s *BehaviorJobWorker) buildBehavior() (string, error) {
 	id := getBehaviorIDFrom(s.cfg.ID)
 	logSrcID := getLogSourceIDFrom(s.cfg.ID)
 	viewBuilder := sql_builder.NewViewSQLBuilder(id)
 
+	filterStr, err := s.buildFilter()
+	if err != nil {
+		return "", err
+	}
 	expBuilder := sql_builder.NewFilterExpSQLBuilder()
 	expBuilder.
-		WithFilter(s.cfg.BehaviorFilter).
+		WithFilter(filterStr).
 		WithFields("*").
 		WithQueryTable(logSrcID)
 	expStr := expBuilder.Build()
 	stmStr := viewBuilder.
 		WithExpression(expStr).
 		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
+	return stmStr, nil
+}
+
+// buildReference returns the builder of the reference table of an object: a jdbc table with its lookup cache, or
+// an upsert-kafka topic versioned by the time its rows were written
+func (s *BehaviorJobWorker) buildReference(obj *view.Object) func() (string, error) {
+	return func() (string, error) {
+		ref := obj.Reference
+		tableBuilder := sql_builder.NewTableSQLBuilder(getReferenceIDFrom(s.cfg.ID, obj.Name))
+		// build schema
+		schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+		for _, v := range ref.Table.OrderedSchema() {
+			schemaBuilder.WithColumn(v[0], v[1])
+		}
+		if !ref.IsLookup() {
+			writeTime := view.MetadataColumnName("timestamp")
+			schemaBuilder.
+				WithMetadataColumn(writeTime, data_type.TIMESTAMP_LTZ_PRECISION(3), "timestamp", true).
+				WithEventTimeField(view.ReferenceTimeField,
+					fmt.Sprintf("CAST(%v AS %v)", writeTime, s.cfg.LogSourceConfig.EventTime().DataType()), 0)
+		}
+		schemaBuilder.WithPrimaryKey(ref.Key)
+
+		connectorBuilder, err := ref.Connector()
+		if err != nil {
+			return "", err
+		}
+		if err := connectorBuilder.Err(); err != nil {
+			return "", err
+		}
+
+		stmStr := tableBuilder.
+			WithSchema(schemaBuilder.Build()).
+			WithConnector(connectorBuilder.Build()).
+			Build()
+		return stmStr, nil
 	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
+}
+
+// buildEnrichment renders the view of the behaviors with the identities their mapping and reference fields
+// resolve to, it is skipped when every field is profiled as is
+func (s *BehaviorJobWorker) buildEnrichment() (string, error) {
+	objects := s.cfg.ResolvedObjects()
+	if len(objects) == 0 {
+		return "", nil
 	}
-	return nil
+	const source = "b"
+	expBuilder := sql_builder.NewLookupJoinExpSQLBuilder()
+	expBuilder.WithQueryTable(getBehaviorIDFrom(s.cfg.ID), source)
+	for i, obj := range objects {
+		reference := fmt.Sprintf("r%v", i)
+		if obj.GetType() == view.ObjectReference {
+			expBuilder.WithLookupJoin(getReferenceIDFrom(s.cfg.ID, obj.Name), reference, obj.Reference.TimeField(),
+				obj.JoinCondition(source, reference))
+		}
+		expBuilder.WithField(obj.ProfileField(), obj.ResolvedExpression(source, reference))
+	}
+	stmStr := sql_builder.NewViewSQLBuilder(getEnrichmentIDFrom(s.cfg.ID)).
+		WithExpression(expBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+// enrichedBehaviorID returns the view the profiles and the behavior output read from
+func (s *BehaviorJobWorker) enrichedBehaviorID() string {
+	if len(s.cfg.ResolvedObjects()) > 0 {
+		return getEnrichmentIDFrom(s.cfg.ID)
+	}
+	return getBehaviorIDFrom(s.cfg.ID)
 }
 
-func (s *BehaviorJobWorker) createProfileBatch() error {
+func (s *BehaviorJobWorker) buildFilter() (string, error) {
+	if s.cfg.RawFilter {
+		return s.cfg.BehaviorFilter, nil
+	}
+	return filter.NewCompiler(s.cfg.FilterSchema()).Compile(s.cfg.BehaviorFilter)
+}
+
+func (s *BehaviorJobWorker) buildProfileBatch() (string, error) {
 	id := getProfileIDFrom(s.cfg.ID)
-	bhvID := getBehaviorIDFrom(s.cfg.ID)
+	bhvID := s.enrichedBehaviorID()
 	viewBuilder := sql_builder.NewViewSQLBuilder(id)
 	var (
 		entities   []string
 		attributes []string
 	)
 	for _, ent := range s.cfg.ProfileConfig.Entities {
-		entities = append(entities, ent.Name)
+		entities = append(entities, ent.ProfileField())
 	}
 	for _, att := range s.cfg.ProfileConfig.Attributes {
-		attributes = append(attributes, att.Name)
+		attributes = append(attributes, att.ProfileField())
 	}
 	expBuilder := sql_builder.NewTumblingCntWindowExpSQLBuilder()
+	for _, agg := range s.cfg.ProfileConfig.Aggregates {
+		expBuilder.WithAggregate(agg.Name, agg.Expression())
+	}
 
 	expStr := expBuilder.
 		WithQueryTable(bhvID).
@@ -163,55 +218,34 @@ func (s *BehaviorJobWorker) createProfileBatch() error {
 	stmStr := viewBuilder.
 		WithExpression(expStr).
 		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
-	}
-	return nil
+	return stmStr, nil
 }
 
-func (s *BehaviorJobWorker) createBehaviorSink() error {
+func (s *BehaviorJobWorker) buildBehaviorSink() (string, error) {
 	id := getBehaviorSinkIDFrom(s.cfg.ID)
 	tableBuilder := sql_builder.NewTableSQLBuilder(id)
 	// build schema
 	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
-	for _, v := range getOrderedSchema(s.cfg.LogSourceConfig.Schema) {
+	for _, v := range s.cfg.BehaviorColumns() {
 		schemaBuilder.WithColumn(v[0], v[1])
 	}
-	schemaBuilder.WithColumn(timestampField, data_type.TIMESTAMP_PRECISION(3))
 
-	connectorBuilder := sql_builder.NewConnectorBuilder()
-	connectorBuilder.
-		WithOption("connector", "kafka").
-		WithOption("topic", s.cfg.BehaviorOutput.Topic).
-		WithOption("properties.bootstrap.servers", s.cfg.BehaviorOutput.BootstrapServer).
-		WithOption("properties.group.id", config.AppConfig.KafkaGroupID).
-		WithOption("format", "json")
+	connectorBuilder, err := s.cfg.BehaviorOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
 
 	stmStr := tableBuilder.
 		WithSchema(schemaBuilder.Build()).
 		WithConnector(connectorBuilder.Build()).
 		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
-	}
-	return nil
+	return stmStr, nil
 }
 
-func (s *BehaviorJobWorker) createProfilingSink() error {
+func (s *BehaviorJobWorker) buildProfilingSink() (string, error) {
 	id := getProfilingSinkIDFrom(s.cfg.ID)
 	tableBuilder := sql_builder.NewTableSQLBuilder(id)
 	// build schema
@@ -222,91 +256,150 @@ func (s *BehaviorJobWorker) createProfilingSink() error {
 		WithColumn("cnt", data_type.BIGINT()).
 		WithColumn("entities", data_type.STRING()).
 		WithColumn("attributes", data_type.STRING())
+	if s.cfg.ProfileOutput.IsUpsert() {
+		schemaBuilder.WithPrimaryKey(profileKey...)
+	}
 
-	connectorBuilder := sql_builder.NewConnectorBuilder()
-	connectorBuilder.
-		WithOption("connector", "kafka").
-		WithOption("topic", s.cfg.ProfileOutput.Topic).
-		WithOption("properties.bootstrap.servers", s.cfg.ProfileOutput.BootstrapServer).
-		WithOption("properties.group.id", config.AppConfig.KafkaGroupID).
-		WithOption("format", "json")
+	connectorBuilder, err := s.cfg.ProfileOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
 
 	stmStr := tableBuilder.
 		WithSchema(schemaBuilder.Build()).
 		WithConnector(connectorBuilder.Build()).
 		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
-	}
-	return nil
+	return stmStr, nil
 }
 
-func (s *BehaviorJobWorker) setName() error {
+func (s *BehaviorJobWorker) buildSetName() (string, error) {
 	setCfgBuilder := sql_builder.NewSetConfigSQLBuilder()
 	stmStr := setCfgBuilder.WithConfig("pipeline.name", fmt.Sprintf("behavior_%v", s.cfg.ID)).Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
-	}
-	return nil
+	return stmStr, nil
 }
 
-func (s *BehaviorJobWorker) createJob() (string, error) {
+func (s *BehaviorJobWorker) buildJob() (string, error) {
 	profilingSinkID := getProfilingSinkIDFrom(s.cfg.ID)
 	profileID := getProfileIDFrom(s.cfg.ID)
 	profExpBuilder := sql_builder.NewSelectSQLBuilder()
-	profExpStr := profExpBuilder.
-		WithFields("*").
-		WithQueryTable(profileID).
-		Build()
+	if s.cfg.ProfileOutput.IsUpsert() {
+		// key columns are NOT NULL in upsert outputs, profiles without entity or attribute cannot be keyed
+		filterBuilder := sql_builder.NewFilterExpSQLBuilder()
+		filterBuilder.WithFilter("entities IS NOT NULL AND attributes IS NOT NULL")
+		profExpBuilder = filterBuilder
+	}
+	profExpBuilder.
+		WithFields(strings.Join(profileColumns, ",")).
+		WithQueryTable(profileID)
+	profExpStr := profExpBuilder.Build()
 	profInsertBuilder := sql_builder.NewInsertSQLBuilder()
 	insertProfilingStm := profInsertBuilder.
 		WithDestinationTable(profilingSinkID).
+		WithColumns(profileColumns...).
 		WithExpression(profExpStr).
 		Build()
 
 	bhvSinkID := getBehaviorSinkIDFrom(s.cfg.ID)
-	bhvID := getBehaviorIDFrom(s.cfg.ID)
+	var bhvColumns, bhvFields []string
+	for _, v := range s.cfg.BehaviorColumns() {
+		bhvColumns = append(bhvColumns, v[0])
+		bhvFields = append(bhvFields, sql_builder.QuoteIdentifier(v[0]))
+	}
 	bhvExpBuilder := sql_builder.NewSelectSQLBuilder()
 	bhvExpStr := bhvExpBuilder.
-		WithFields("*").
-		WithQueryTable(bhvID).
+		WithFields(strings.Join(bhvFields, ",")).
+		WithQueryTable(s.enrichedBehaviorID()).
 		Build()
 	bhvInsertBuilder := sql_builder.NewInsertSQLBuilder()
 	insertBhvStm := bhvInsertBuilder.
 		WithDestinationTable(bhvSinkID).
+		WithColumns(bhvColumns...).
 		WithExpression(bhvExpStr).
 		Build()
 
 	stmSetBuilder := sql_builder.NewStatementSetSQLBuilder()
-	stmStr := stmSetBuilder.
+	stmSetBuilder.
 		WithInsertStatement(insertBhvStm).
-		WithInsertStatement(insertProfilingStm).
+		WithInsertStatement(insertProfilingStm)
+	if s.cfg.HasAnomaly() {
+		stmSetBuilder.WithInsertStatement(s.buildAnomalyInsert())
+	}
+	return stmSetBuilder.Build(), nil
+}
+
+// buildAnomaly renders the view of the anomalous profile windows, or nothing when anomalies are not detected
+func (s *BehaviorJobWorker) buildAnomaly() (string, error) {
+	if !s.cfg.HasAnomaly() {
+		return "", nil
+	}
+	anomaly := s.cfg.ProfileConfig.Anomaly
+	var expBuilder sql_builder.AnomalyExpSQLBuilder
+	if anomaly.GetMode() == view.AnomalyBaseline {
+		expBuilder = sql_builder.NewBaselineAnomalyExpSQLBuilder(anomaly.GetWindows(), anomaly.GetDeviations()).
+			WithPartition("entities", "attributes").
+			WithTimeField("window_time").
+			WithMinWindows(anomaly.GetMinWindows())
+	} else {
+		expBuilder = sql_builder.NewThresholdAnomalyExpSQLBuilder(s.cfg.ProfileConfig.Threshold)
+	}
+	expStr := expBuilder.
+		WithQueryTable(getProfileIDFrom(s.cfg.ID)).
+		WithFields("window_start", "window_end", "entities", "attributes").
+		WithMetric(anomaly.GetMetric()).
+		Build()
+	stmStr := sql_builder.NewViewSQLBuilder(getAnomalyIDFrom(s.cfg.ID)).
+		WithExpression(expStr).
 		Build()
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
+	return stmStr, nil
+}
+
+func (s *BehaviorJobWorker) buildAnomalySink() (string, error) {
+	if !s.cfg.HasAnomaly() {
+		return "", nil
+	}
+	if s.cfg.AnomalyOutput == nil {
+		return "", errors.New("anomaly_output_config is required to detect anomalies")
+	}
+	tableBuilder := sql_builder.NewTableSQLBuilder(getAnomalySinkIDFrom(s.cfg.ID))
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	for _, col := range s.cfg.AnomalyColumns(s.cfg.ID) {
+		schemaBuilder.WithColumn(col.Name, col.Type)
+	}
+
+	connectorBuilder, err := s.cfg.AnomalyOutput.Connector()
 	if err != nil {
 		return "", err
 	}
-	fmt.Println(stmStr)
-	opRes, err := stm.GetOperationResult(0)
-	if err != nil {
+	if err := connectorBuilder.Err(); err != nil {
 		return "", err
 	}
-	return opRes.JobID, nil
+
+	stmStr := tableBuilder.
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *BehaviorJobWorker) buildAnomalyInsert() string {
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	var columns []string
+	for _, col := range s.cfg.AnomalyColumns(s.cfg.ID) {
+		projectionBuilder.WithField(col.Name, col.Expression)
+		columns = append(columns, col.Name)
+	}
+	expStr := sql_builder.NewSelectSQLBuilder().
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(getAnomalyIDFrom(s.cfg.ID)).
+		Build()
+	return sql_builder.NewInsertSQLBuilder().
+		WithDestinationTable(getAnomalySinkIDFrom(s.cfg.ID)).
+		WithColumns(columns...).
+		WithExpression(expStr).
+		Build()
 }
 
 func (s *BehaviorJobWorker) MonitorJobStatus() error {
@@ -321,6 +414,14 @@ func getBehaviorIDFrom(ID string) string {
 	return "behavior_" + ID
 }
 
+func getReferenceIDFrom(ID string, field string) string {
+	return "reference_" + ID + "_" + field
+}
+
+func getEnrichmentIDFrom(ID string) string {
+	return "enrichment_" + ID
+}
+
 func getProfileIDFrom(ID string) string {
 	return "profile_" + ID
 }
@@ -333,16 +434,10 @@ func getProfilingSinkIDFrom(ID string) string {
 	return "profiling_sink_" + ID
 }
 
-func getOrderedSchema(schema map[string]string) [][]string {
-	var arr [][]string
-	for k, v := range schema {
-		kv := make([]string, 0)
-		kv = append(kv, k)
-		kv = append(kv, v)
-		arr = append(arr, kv)
-	}
-	sort.Slice(arr, func(i, j int) bool {
-		return arr[i][0] < arr[j][0]
-	})
-	return arr
+func getAnomalyIDFrom(ID string) string {
+	return "anomaly_" + ID
+}
+
+func getAnomalySinkIDFrom(ID string) string {
+	return "anomaly_sink_" + ID
 }
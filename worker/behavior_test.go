@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"flink_ueba_manager/view"
+	"strings"
+	"testing"
+)
+
+// TestBehaviorAnomalyAggregate checks a baseline anomaly on a declared aggregate computes its OVER window on the
+// aggregate column of the profile windows
+func TestBehaviorAnomalyAggregate(t *testing.T) {
+	useTestConfig(t)
+	cfg := &view.BehaviorJobConfig{}
+	loadJobConfig(t, "behavior_anomaly", cfg)
+	w := NewBehaviorJobWorker(cfg.ID, cfg)
+
+	profile, err := w.buildProfileBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"COUNT(DISTINCT `port`) AS `distinct_ports`", "MAX(CAST(`port` AS DOUBLE)) AS `max_port`"} {
+		if !strings.Contains(profile, want) {
+			t.Errorf("the profile windows miss %v:\n%v", want, profile)
+		}
+	}
+
+	anomaly, err := w.buildAnomaly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"CAST(`distinct_ports` AS DOUBLE) AS `value`",
+		"SUM(CAST(`distinct_ports` AS DOUBLE)) OVER w - CAST(`distinct_ports` AS DOUBLE) AS `baseline_sum`",
+		"FROM " + getProfileIDFrom(cfg.ID) + " WINDOW w AS (PARTITION BY `entities`,`attributes` ORDER BY `window_time` " +
+			"ROWS BETWEEN 12 PRECEDING AND CURRENT ROW)",
+		"WHERE `baseline_windows` >= 4",
+	} {
+		if !strings.Contains(anomaly, want) {
+			t.Errorf("the anomalies miss %v:\n%v", want, anomaly)
+		}
+	}
+	if strings.Contains(anomaly, "`cnt`") {
+		t.Errorf("the anomalies compare cnt rather than the aggregate:\n%v", anomaly)
+	}
+}
+
+func TestBehaviorAnomalyMetricValidation(t *testing.T) {
+	useTestConfig(t)
+	tests := []struct {
+		name   string
+		modify func(cfg *view.BehaviorJobConfig)
+		want   string
+	}{
+		{
+			name:   "undeclared metric",
+			modify: func(cfg *view.BehaviorJobConfig) { cfg.ProfileConfig.Anomaly.Metric = "sum_bytes" },
+			want:   "profile_config.anomaly.metric 'sum_bytes' is not a profile metric",
+		},
+		{
+			name:   "non numeric field",
+			modify: func(cfg *view.BehaviorJobConfig) { cfg.ProfileConfig.Aggregates[1].Field = "user" },
+			want:   "profile_config.aggregates[1].field 'user' must be numeric for function max",
+		},
+		{
+			name:   "profile column",
+			modify: func(cfg *view.BehaviorJobConfig) { cfg.ProfileConfig.Aggregates[0].Name = "cnt" },
+			want:   "profile_config.aggregates[0].name 'cnt' is already a column of the profile windows",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &view.BehaviorJobConfig{}
+			loadJobConfig(t, "behavior_anomaly", cfg)
+			tt.modify(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
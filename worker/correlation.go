@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/filter"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// CorrelationJobWorker runs a correlation rule, matching ordered sequences of source events with MATCH_RECOGNIZE
+	CorrelationJobWorker struct {
+		ID         string
+		cfg        *view.CorrelationJobConfig
+		flinkJobID string
+		logger     *logrus.Entry
+	}
+)
+
+func NewCorrelationJobWorker(ID string, cfg *view.CorrelationJobConfig) *CorrelationJobWorker {
+	return &CorrelationJobWorker{
+		ID:     ID,
+		cfg:    cfg,
+		logger: logrus.WithField("correlation_job", ID),
+	}
+}
+
+func (s *CorrelationJobWorker) Stop() error {
+	return nil
+}
+
+func (s *CorrelationJobWorker) Run() error {
+	plan, err := s.Plan()
+	if err != nil {
+		return err
+	}
+	jobID, err := submitPlan(s.logger, plan)
+	if err != nil {
+		return err
+	}
+	s.flinkJobID = jobID
+	s.logger.Infof("done creating flink job %v", jobID)
+	return nil
+}
+
+// Plan renders the ordered statements of the job: source and sink tables, views, settings and the statement set
+func (s *CorrelationJobWorker) Plan() ([]string, error) {
+	return renderPlan(
+		s.buildSource,
+		s.buildMatches,
+		s.buildAlertSink,
+		setIdleTimeout(s.cfg.SourceConfig),
+		setTimezone("UTC"),
+		s.buildSetName,
+		s.buildJob,
+	)
+}
+
+func (s *CorrelationJobWorker) buildSource() (string, error) {
+	cfg := s.cfg.SourceConfig
+	return buildKafkaSource(getCorrelationSourceIDFrom(s.cfg.ID), cfg, jobStartup(cfg, "correlation", s.cfg.ID), eventTimeColumn(cfg, timestampField))
+}
+
+func (s *CorrelationJobWorker) buildMatches() (string, error) {
+	within, err := s.cfg.GetWithin()
+	if err != nil {
+		return "", err
+	}
+	expBuilder := sql_builder.NewMatchRecognizeExpSQLBuilder()
+	expBuilder.
+		WithQueryTable(getCorrelationSourceIDFrom(s.cfg.ID)).
+		WithPartitionBy(s.cfg.PartitionBy...).
+		WithOrderBy(timestampField).
+		WithWithin(within)
+	for _, m := range s.cfg.Measures() {
+		expBuilder.WithMeasure(m.Name, m.Expression)
+	}
+	filterSchema := s.cfg.SourceConfig.Columns()
+	filterSchema[timestampField] = s.cfg.SourceConfig.EventTime().DataType()
+	for _, step := range s.cfg.Steps {
+		condition := step.Filter
+		if condition != "" && !step.RawFilter {
+			condition, err = filter.NewCompiler(filterSchema).Compile(step.Filter)
+			if err != nil {
+				return "", fmt.Errorf("step %v: %v", step.Name, err)
+			}
+		}
+		expBuilder.WithVariable(step.Name, step.Quantifier, condition)
+	}
+	stmStr := sql_builder.NewViewSQLBuilder(getCorrelationIDFrom(s.cfg.ID)).
+		WithExpression(expBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *CorrelationJobWorker) buildAlertSink() (string, error) {
+	id := getCorrelationSinkIDFrom(s.cfg.ID)
+	tableBuilder := sql_builder.NewTableSQLBuilder(id)
+	// build schema
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	for _, col := range s.cfg.AlertColumns(getCorrelationIDFrom(s.cfg.ID)) {
+		schemaBuilder.WithColumn(col.Name, col.Type)
+	}
+
+	connectorBuilder, err := s.cfg.RuleOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
+
+	stmStr := tableBuilder.
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *CorrelationJobWorker) buildSetName() (string, error) {
+	return buildSetConfig("pipeline.name", fmt.Sprintf("correlation_%v", s.cfg.ID)), nil
+}
+
+func (s *CorrelationJobWorker) buildJob() (string, error) {
+	correlationID := getCorrelationIDFrom(s.cfg.ID)
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	var columns []string
+	for _, col := range s.cfg.AlertColumns(correlationID) {
+		projectionBuilder.WithField(col.Name, col.Expression)
+		columns = append(columns, col.Name)
+	}
+	expStr := sql_builder.NewSelectSQLBuilder().
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(correlationID).
+		Build()
+	insertStm := sql_builder.NewInsertSQLBuilder().
+		WithDestinationTable(getCorrelationSinkIDFrom(s.cfg.ID)).
+		WithColumns(columns...).
+		WithExpression(expStr).
+		Build()
+	stmStr := sql_builder.NewStatementSetSQLBuilder().
+		WithInsertStatement(insertStm).
+		Build()
+	return stmStr, nil
+}
+
+func getCorrelationSourceIDFrom(ID string) string {
+	return "correlation_source_" + ID
+}
+
+func getCorrelationIDFrom(ID string) string {
+	return "correlation_" + ID
+}
+
+func getCorrelationSinkIDFrom(ID string) string {
+	return "correlation_sink_" + ID
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"flink_ueba_manager/external"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	// flinkJobsTimeout bounds the listing of the jobs of the cluster
+	flinkJobsTimeout = 30 * time.Second
+	// savepointTimeout bounds the savepoint taken when a job is stopped
+	savepointTimeout = 5 * time.Minute
+)
+
+type (
+	// FlinkJob is a job of the cluster as listed by SHOW JOBS
+	FlinkJob struct {
+		ID     string
+		Name   string
+		Status string
+	}
+)
+
+// IsActive tells whether the job runs or is about to run again
+func (j *FlinkJob) IsActive() bool {
+	switch j.Status {
+	case "INITIALIZING", "CREATED", "RUNNING", "RESTARTING", "RECONCILING":
+		return true
+	}
+	return false
+}
+
+// renderPlan runs the statement builders in order and collects the rendered statements.
+// Builders of optional statements return an empty statement when they do not apply
+func renderPlan(builders ...func() (string, error)) ([]string, error) {
+	plan := make([]string, 0, len(builders))
+	for _, build := range builders {
+		stm, err := build()
+		if err != nil {
+			return nil, err
+		}
+		if stm == "" {
+			continue
+		}
+		plan = append(plan, stm)
+	}
+	return plan, nil
+}
+
+func buildSetConfig(key string, value string) string {
+	return sql_builder.NewSetConfigSQLBuilder().WithConfig(key, value).Build()
+}
+
+// submitPlan submits the statements one by one to the shared SQL gateway session.
+// The last statement of a plan is the statement set that starts the job, its job ID is returned
+func submitPlan(logger *logrus.Entry, plan []string) (string, error) {
+	session := external.GetFlinkSQLSession()
+	jobID := ""
+	for _, stmStr := range plan {
+		logger.Info(stmStr)
+		resolved, err := sql_builder.ResolveSecretOptions(stmStr, util.ResolveSecrets)
+		if err != nil {
+			return "", err
+		}
+		stm, err := session.SubmitStatement(resolved)
+		if err != nil {
+			return "", err
+		}
+		opRes, err := stm.GetOperationResult(0)
+		if err != nil {
+			return "", err
+		}
+		jobID = opRes.JobID
+	}
+	return jobID, nil
+}
+
+// submitStatement submits a statement which starts no job, such as a DROP, and waits for its result
+func submitStatement(session *external.FlinkSQLGatewaySession, logger *logrus.Entry, stmStr string) error {
+	logger.Info(stmStr)
+	stm, err := session.SubmitStatement(stmStr)
+	if err != nil {
+		return err
+	}
+	_, err = stm.GetOperationResult(0)
+	return err
+}
+
+// ListFlinkJobs lists the jobs of the cluster through the shared SQL gateway session, the jobs of earlier
+// sessions included. The name of a job is its pipeline.name, such as rule_<id>
+func ListFlinkJobs() ([]*FlinkJob, error) {
+	stm, err := external.GetFlinkSQLSession().SubmitStatement(sql_builder.ShowJobsStatement)
+	if err != nil {
+		return nil, err
+	}
+	columns, rows, err := stm.FetchRows(flinkJobsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col] = i
+	}
+	for _, col := range []string{"job id", "job name", "status"} {
+		if _, ok := index[col]; !ok {
+			return nil, errors.Errorf("column %v missing from the result of %v", col, sql_builder.ShowJobsStatement)
+		}
+	}
+	jobs := make([]*FlinkJob, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < len(columns) {
+			continue
+		}
+		jobs = append(jobs, &FlinkJob{
+			ID:     util.ParseString(row[index["job id"]]),
+			Name:   util.ParseString(row[index["job name"]]),
+			Status: util.ParseString(row[index["status"]]),
+		})
+	}
+	return jobs, nil
+}
+
+// StopFlinkJob stops a job of the cluster without a savepoint
+func StopFlinkJob(jobID string) error {
+	return submitStatement(external.GetFlinkSQLSession(), logrus.WithField("flink_job", jobID), sql_builder.StopJobStatement(jobID))
+}
+
+// stopJobWithSavepoint stops a job after taking a savepoint and returns the path of the savepoint
+func stopJobWithSavepoint(session *external.FlinkSQLGatewaySession, logger *logrus.Entry, jobID string) (string, error) {
+	stmStr := sql_builder.StopJobWithSavepointStatement(jobID)
+	logger.Info(stmStr)
+	stm, err := session.SubmitStatement(stmStr)
+	if err != nil {
+		return "", err
+	}
+	_, rows, err := stm.FetchRows(savepointTimeout)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 || util.ParseString(rows[0][0]) == "" {
+		return "", errors.Errorf("no savepoint path in the result of '%v'", stmStr)
+	}
+	return util.ParseString(rows[0][0]), nil
+}
+
+// ExplainPlan validates the plan of a worker through the SQL gateway without starting a job.
+// The DDL and SET statements are submitted to a dedicated session, which is closed afterwards,
+// and the statement set is replaced by its EXPLAIN counterpart
+func ExplainPlan(w IFlinkSQLWorker) (string, error) {
+	plan, err := w.Plan()
+	if err != nil {
+		return "", err
+	}
+	if len(plan) == 0 {
+		return "", errors.New("empty plan")
+	}
+	session, err := external.NewFlinkSQLGatewaySession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	for _, stmStr := range plan[:len(plan)-1] {
+		resolved, err := sql_builder.ResolveSecretOptions(stmStr, util.ResolveSecrets)
+		if err != nil {
+			return "", err
+		}
+		stm, err := session.SubmitStatement(resolved)
+		if err != nil {
+			return "", err
+		}
+		if _, err := stm.GetOperationResult(0); err != nil {
+			return "", errors.Wrapf(err, "statement '%v'", stmStr)
+		}
+	}
+	stm, err := session.SubmitStatement(sql_builder.ExplainStatement(plan[len(plan)-1]))
+	if err != nil {
+		return "", err
+	}
+	opRes, err := stm.GetOperationResult(0)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, row := range opRes.Rows() {
+		for _, f := range row {
+			lines = append(lines, util.ParseString(f))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
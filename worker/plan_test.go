@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"encoding/json"
+	"flag"
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/view"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// assertGolden compares the statements of a plan, one per line, with testdata/<name>.golden
+func assertGolden(t *testing.T, name string, plan []string) {
+	t.Helper()
+	got := strings.Join(plan, "\n") + "\n"
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%v differs from %v\ngot:\n%vwant:\n%v", name, path, got, want)
+	}
+}
+
+// loadJobConfig reads the job config of testdata/<name>.json and validates it
+func loadJobConfig(t *testing.T, name string, cfg interface{ Validate() error }) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// useTestConfig runs the test with the default config and a watchlist table
+func useTestConfig(t *testing.T) {
+	defaultConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = defaultConfig })
+	config.AppConfig = config.DefaultConfig()
+	config.AppConfig.Watchlist.URL = "jdbc:postgresql://db:5432/ueba"
+	config.AppConfig.Watchlist.Username = "ueba"
+	config.AppConfig.Watchlist.Password = "env:WATCHLIST_DB_PASSWORD"
+}
+
+func TestBehaviorPlan(t *testing.T) {
+	useTestConfig(t)
+	for _, name := range []string{"behavior", "behavior_reference", "behavior_anomaly"} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &view.BehaviorJobConfig{}
+			loadJobConfig(t, name, cfg)
+			plan, err := NewBehaviorJobWorker(cfg.ID, cfg).Plan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, name, plan)
+		})
+	}
+}
+
+func TestRulePlan(t *testing.T) {
+	useTestConfig(t)
+	for _, name := range []string{"rule_risk_score", "rule_suppressed_watchlist"} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &view.RuleJobConfig{}
+			loadJobConfig(t, name, cfg)
+			plan, err := NewRuleJobWorker(cfg.ID, cfg).Plan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertGolden(t, name, plan)
+		})
+	}
+}
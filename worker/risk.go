@@ -0,0 +1,222 @@
+package worker
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// RiskJobWorker runs a risk job, accumulating the risk scores of rule alerts per object
+	RiskJobWorker struct {
+		ID         string
+		cfg        *view.RiskJobConfig
+		flinkJobID string
+		logger     *logrus.Entry
+	}
+)
+
+func NewRiskJobWorker(ID string, cfg *view.RiskJobConfig) *RiskJobWorker {
+	return &RiskJobWorker{
+		ID:     ID,
+		cfg:    cfg,
+		logger: logrus.WithField("risk_job", ID),
+	}
+}
+
+func (s *RiskJobWorker) Stop() error {
+	return nil
+}
+
+func (s *RiskJobWorker) Run() error {
+	plan, err := s.Plan()
+	if err != nil {
+		return err
+	}
+	jobID, err := submitPlan(s.logger, plan)
+	if err != nil {
+		return err
+	}
+	s.flinkJobID = jobID
+	s.logger.Infof("done creating flink job %v", jobID)
+	return nil
+}
+
+// Plan renders the ordered statements of the job: source and sink tables, views, settings and the statement set
+func (s *RiskJobWorker) Plan() ([]string, error) {
+	return renderPlan(
+		s.buildSource,
+		s.buildRisk,
+		s.buildNotable,
+		s.buildSnapshotSink,
+		s.buildNotableSink,
+		setIdleTimeout(s.cfg.SourceConfig),
+		setTimezone("UTC"),
+		s.buildSetName,
+		s.buildJob,
+	)
+}
+
+func (s *RiskJobWorker) buildSource() (string, error) {
+	cfg := s.cfg.SourceConfig
+	return buildKafkaSource(getRiskSourceIDFrom(s.cfg.ID), cfg, jobStartup(cfg, "risk", s.cfg.ID), eventTimeColumn(cfg, timestampField))
+}
+
+// buildRisk sums the decayed scores of the alerts of each object and window
+func (s *RiskJobWorker) buildRisk() (string, error) {
+	size, err := s.cfg.GetWindow()
+	if err != nil {
+		return "", err
+	}
+	slide, err := s.cfg.GetSlide()
+	if err != nil {
+		return "", err
+	}
+	halfLife, err := s.cfg.GetHalfLife()
+	if err != nil {
+		return "", err
+	}
+	window := sql_builder.NewWindowTableSQLBuilder().
+		WithQueryTable(getRiskSourceIDFrom(s.cfg.ID)).
+		WithTimeField(timestampField).
+		WithSize(size).
+		WithSlide(slide).
+		Build()
+	expStr := sql_builder.NewRiskExpSQLBuilder().
+		WithWindowTable(window).
+		WithObject(view.RiskObjectField, s.cfg.GetObject()).
+		WithScore(view.RiskScoreField, s.cfg.GetScoreField()).
+		WithCount(view.RiskCountField).
+		WithDecay(timestampField, halfLife).
+		Build()
+	stmStr := sql_builder.NewViewSQLBuilder(getRiskIDFrom(s.cfg.ID)).
+		WithExpression(expStr).
+		Build()
+	return stmStr, nil
+}
+
+// buildNotable keeps the risk of the objects crossing the threshold
+func (s *RiskJobWorker) buildNotable() (string, error) {
+	slide, err := s.cfg.GetSlide()
+	if err != nil {
+		return "", err
+	}
+	expStr := sql_builder.NewThresholdCrossingExpSQLBuilder().
+		WithQueryTable(getRiskIDFrom(s.cfg.ID)).
+		WithKey(view.RiskObjectField).
+		WithValue(view.RiskScoreField).
+		WithPrevious(view.PreviousRiskScoreField).
+		WithThreshold(s.cfg.Threshold).
+		WithSlide(slide).
+		Build()
+	stmStr := sql_builder.NewViewSQLBuilder(getRiskNotableIDFrom(s.cfg.ID)).
+		WithExpression(expStr).
+		Build()
+	return stmStr, nil
+}
+
+// buildSnapshotSink declares the snapshot output, keyed by object when it is an upsert output
+func (s *RiskJobWorker) buildSnapshotSink() (string, error) {
+	id := getRiskSnapshotSinkIDFrom(s.cfg.ID)
+	tableBuilder := sql_builder.NewTableSQLBuilder(id)
+	// build schema
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	for _, col := range s.cfg.SnapshotColumns(getRiskIDFrom(s.cfg.ID)) {
+		schemaBuilder.WithColumn(col.Name, col.Type)
+	}
+	if s.cfg.SnapshotOutput.IsUpsert() {
+		schemaBuilder.WithPrimaryKey(view.RiskObjectField)
+	}
+
+	connectorBuilder, err := s.cfg.SnapshotOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
+
+	stmStr := tableBuilder.
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *RiskJobWorker) buildNotableSink() (string, error) {
+	id := getRiskNotableSinkIDFrom(s.cfg.ID)
+	tableBuilder := sql_builder.NewTableSQLBuilder(id)
+	// build schema
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	for _, col := range s.cfg.NotableColumns(getRiskIDFrom(s.cfg.ID)) {
+		schemaBuilder.WithColumn(col.Name, col.Type)
+	}
+
+	connectorBuilder, err := s.cfg.NotableOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
+
+	stmStr := tableBuilder.
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *RiskJobWorker) buildSetName() (string, error) {
+	return buildSetConfig("pipeline.name", fmt.Sprintf("risk_%v", s.cfg.ID)), nil
+}
+
+// buildJob inserts the snapshots and the notable events in one statement set, so both share the risk computation
+func (s *RiskJobWorker) buildJob() (string, error) {
+	riskID := getRiskIDFrom(s.cfg.ID)
+	stmStr := sql_builder.NewStatementSetSQLBuilder().
+		WithInsertStatement(s.buildInsert(getRiskSnapshotSinkIDFrom(s.cfg.ID), riskID, s.cfg.SnapshotColumns(riskID))).
+		WithInsertStatement(s.buildInsert(getRiskNotableSinkIDFrom(s.cfg.ID), getRiskNotableIDFrom(s.cfg.ID), s.cfg.NotableColumns(riskID))).
+		Build()
+	return stmStr, nil
+}
+
+// buildInsert inserts the columns by name into a sink, selecting their expressions from the query table
+func (s *RiskJobWorker) buildInsert(sinkID string, queryTable string, cols []view.AlertColumn) string {
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	var columns []string
+	for _, col := range cols {
+		projectionBuilder.WithField(col.Name, col.Expression)
+		columns = append(columns, col.Name)
+	}
+	expStr := sql_builder.NewSelectSQLBuilder().
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(queryTable).
+		Build()
+	return sql_builder.NewInsertSQLBuilder().
+		WithDestinationTable(sinkID).
+		WithColumns(columns...).
+		WithExpression(expStr).
+		Build()
+}
+
+func getRiskSourceIDFrom(ID string) string {
+	return "risk_source_" + ID
+}
+
+func getRiskIDFrom(ID string) string {
+	return "risk_" + ID
+}
+
+func getRiskNotableIDFrom(ID string) string {
+	return "risk_notable_" + ID
+}
+
+func getRiskSnapshotSinkIDFrom(ID string) string {
+	return "risk_snapshot_sink_" + ID
+}
+
+func getRiskNotableSinkIDFrom(ID string) string {
+	return "risk_notable_sink_" + ID
+}
@@ -1,26 +1,31 @@
 package worker
 
 import (
-	"flink_ueba_manager/config"
-	"flink_ueba_manager/external"
 	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/sql_builder/data_type"
+	"flink_ueba_manager/sql_builder/filter"
 	"flink_ueba_manager/view"
 	"fmt"
-	"time"
+	"github.com/sirupsen/logrus"
 )
 
 type (
 	RuleJobWorker struct {
-		ID         string
-		cfg        *view.RuleJobConfig
+		ID  string
+		cfg *view.RuleJobConfig
+		// sourceID is the predictor source the rule reads, the source of its group when it is grouped
+		sourceID   string
 		flinkJobID string
+		logger     *logrus.Entry
 	}
 )
 
 func NewRuleJobWorker(ID string, cfg *view.RuleJobConfig) *RuleJobWorker {
 	return &RuleJobWorker{
-		ID:  ID,
-		cfg: cfg,
+		ID:       ID,
+		cfg:      cfg,
+		sourceID: getProfilePredictorIDFrom(cfg.ID),
+		logger:   logrus.WithField("rule_job", ID),
 	}
 }
 
@@ -29,187 +34,277 @@ func (s *RuleJobWorker) Stop() error {
 }
 
 func (s *RuleJobWorker) Run() error {
-	err := s.createProfilePredictorSource()
+	plan, err := s.Plan()
 	if err != nil {
 		return err
 	}
-	err = s.createRule()
-	if err != nil {
-		return err
-	}
-	err = s.createRuleSink()
-	if err != nil {
-		return err
-	}
-	err = s.setName()
-	if err != nil {
-		return err
-	}
-	jobID, err := s.createJob()
+	jobID, err := submitPlan(s.logger, plan)
 	if err != nil {
 		return err
 	}
 	s.flinkJobID = jobID
-	fmt.Println("Done creating job")
+	s.logger.Infof("done creating flink job %v", jobID)
 	return nil
 }
 
-func (s *RuleJobWorker) createProfilePredictorSource() error {
-	id := getProfilePredictorIDFrom(s.cfg.ID)
-	tableBuilder := sql_builder.NewTableSQLBuilder(id)
-	// build schema
-	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
-	for _, v := range getOrderedSchema(s.cfg.ProfilePredictorOutput.Schema) {
-		schemaBuilder.WithColumn(v[0], v[1])
-	}
-	//schemaBuilder.WithColumn(timestampField, data_type.TIMESTAMP_PRECISION(3))
-	//schemaBuilder.WithTimestampField(s.cfg.LogSourceConfig.TimestampField, timestampField)
-	// build connector
-	connectorBuilder := sql_builder.NewConnectorBuilder()
-	connectorBuilder.
-		WithOption("connector", "kafka").
-		WithOption("topic", s.cfg.ProfilePredictorOutput.Topic).
-		WithOption("properties.bootstrap.servers", s.cfg.ProfilePredictorOutput.BootstrapServer).
-		WithOption("properties.group.id", config.AppConfig.KafkaGroupID).
-		WithOption("scan.startup.mode", "earliest-offset").
-		WithOption("format", "json").
-		WithOption("json.timestamp-format.standard", "ISO-8601").
-		WithOption("json.ignore-parse-errors", "true").
-		WithOption("json.fail-on-missing-field", "false")
+// Plan renders the ordered statements of the job: source and sink tables, views, settings and the statement set
+func (s *RuleJobWorker) Plan() ([]string, error) {
+	return renderPlan(
+		s.buildProfilePredictorSource,
+		s.buildWatchlistTable,
+		s.buildWatchlistLookup,
+		s.buildRule,
+		s.buildSuppressionInput,
+		s.buildSuppression,
+		s.buildRuleSink,
+		setIdleTimeout(s.cfg.ProfilePredictorOutput),
+		s.buildTimezone,
+		s.buildSetName,
+		s.buildJob,
+	)
+}
 
-	stmStr := tableBuilder.
-		WithSchema(schemaBuilder.Build()).
-		WithConnector(connectorBuilder.Build()).
-		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
+func (s *RuleJobWorker) buildProfilePredictorSource() (string, error) {
+	group := &view.RuleGroup{ID: s.cfg.ID, Rules: []*view.RuleJobConfig{s.cfg}}
+	return buildPredictorSource(s.sourceID, group, func(connectorBuilder sql_builder.KafkaConnectorBuilder) {
+		s.cfg.ProfilePredictorOutput.ApplyStartup(connectorBuilder, "rule", s.cfg.ID)
+	})
+}
+
+// buildPredictorSource declares the predictor source of the rules of a group, with the time attributes their
+// watchlist lookups and suppressions need. The rules of a group share their predictor config, startup adds the
+// consumer group and startup options of the source
+func buildPredictorSource(id string, group *view.RuleGroup, startup func(sql_builder.KafkaConnectorBuilder)) (string, error) {
+	cfg := group.Rules[0].ProfilePredictorOutput
+	var columns []sourceColumn
+	if group.HasWatchlistLookups() {
+		// the watchlist table is looked up at processing time
+		columns = append(columns, procTimeColumn(view.LookupProcTimeField))
 	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
+	// suppression windows need a time attribute
+	for _, field := range group.SuppressionTimeFields() {
+		if field == view.EventTimeField {
+			columns = append(columns, eventTimeColumn(cfg, view.EventTimeField))
+		} else {
+			columns = append(columns, procTimeColumn(view.AlertProcTimeField))
+		}
 	}
-	return nil
+	return buildKafkaSource(id, cfg, startup, columns...)
 }
 
-func (s *RuleJobWorker) createRule() error {
+func (s *RuleJobWorker) buildRule() (string, error) {
 	id := getRuleIDFrom(s.cfg.ID)
-	logSrcID := getProfilePredictorIDFrom(s.cfg.ID)
+	logSrcID := s.sourceID
+	lookups, err := s.cfg.WatchlistLookups()
+	if err != nil {
+		return "", err
+	}
+	if len(lookups) > 0 {
+		logSrcID = getWatchlistLookupIDFrom(s.cfg.ID)
+	}
 	viewBuilder := sql_builder.NewViewSQLBuilder(id)
 
+	filterStr, err := s.buildFilter()
+	if err != nil {
+		return "", err
+	}
 	expBuilder := sql_builder.NewFilterExpSQLBuilder()
 	expBuilder.
-		WithFilter(s.cfg.Filter).
-		WithFields("*").
+		WithFilter(filterStr).
+		WithFields(fmt.Sprintf("*,%v AS %v", s.cfg.AlertTimeExpression(), sql_builder.QuoteIdentifier("alert_time"))).
 		WithQueryTable(logSrcID)
 	expStr := expBuilder.Build()
 	stmStr := viewBuilder.
 		WithExpression(expStr).
 		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
+	return stmStr, nil
+}
+
+// buildSuppressionInput renders the view alerts are suppressed from, or nothing when alerts are not suppressed
+func (s *RuleJobWorker) buildSuppressionInput() (string, error) {
+	if !s.cfg.HasSuppression() {
+		return "", nil
+	}
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	for _, col := range s.cfg.SuppressionInputColumns() {
+		projectionBuilder.WithField(col.Name, col.Expression)
+	}
+	expStr := sql_builder.NewSelectSQLBuilder().
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(getRuleIDFrom(s.cfg.ID)).
+		Build()
+	stmStr := sql_builder.NewViewSQLBuilder(getSuppressionInputIDFrom(s.cfg.ID)).
+		WithExpression(expStr).
+		Build()
+	return stmStr, nil
+}
+
+// buildSuppression renders the view of the alerts left after suppression, or nothing when alerts are not suppressed
+func (s *RuleJobWorker) buildSuppression() (string, error) {
+	if !s.cfg.HasSuppression() {
+		return "", nil
+	}
+	duration, err := s.cfg.GetSuppressionDuration()
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = stm.GetOperationResult(0)
+	expStr := sql_builder.NewSuppressionExpSQLBuilder().
+		WithQueryTable(getSuppressionInputIDFrom(s.cfg.ID)).
+		WithTimeField(s.cfg.SuppressionTimeField()).
+		WithKeyField(view.SuppressionKeyField).
+		WithDuration(duration).
+		WithMaxAlerts(s.cfg.GetMaxAlerts()).
+		Build()
+	stmStr := sql_builder.NewViewSQLBuilder(getSuppressionIDFrom(s.cfg.ID)).
+		WithExpression(expStr).
+		Build()
+	return stmStr, nil
+}
+
+func (s *RuleJobWorker) buildFilter() (string, error) {
+	if s.cfg.RawFilter {
+		return s.cfg.Filter, nil
+	}
+	return filter.NewCompiler(s.cfg.ProfilePredictorOutput.Columns()).WithWatchlists().Compile(s.cfg.Filter)
+}
+
+// buildWatchlistTable renders the watchlist table, or nothing when the filter does not test watchlists
+func (s *RuleJobWorker) buildWatchlistTable() (string, error) {
+	lookups, err := s.cfg.WatchlistLookups()
+	if err != nil || len(lookups) == 0 {
+		return "", err
+	}
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	schemaBuilder.
+		WithColumn(view.WatchlistNameField, data_type.STRING()).
+		WithColumn(view.WatchlistValueField, data_type.STRING())
+	connectorBuilder, err := view.WatchlistConnector()
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
+	stmStr := sql_builder.NewTableSQLBuilder(getWatchlistIDFrom(s.cfg.ID)).
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
 }
 
-func (s *RuleJobWorker) createRuleSink() error {
+// buildWatchlistLookup renders the view of the predictions with a boolean column per watchlist lookup of the
+// filter, or nothing when the filter does not test watchlists. The watchlist table is looked up at processing
+// time, so an update of a watchlist applies to the predictions read after the table cache expires
+func (s *RuleJobWorker) buildWatchlistLookup() (string, error) {
+	lookups, err := s.cfg.WatchlistLookups()
+	if err != nil || len(lookups) == 0 {
+		return "", err
+	}
+	const source = "p"
+	expBuilder := sql_builder.NewLookupJoinExpSQLBuilder()
+	expBuilder.WithQueryTable(s.sourceID, source)
+	for i, lookup := range lookups {
+		alias := fmt.Sprintf("w%v", i)
+		condition := fmt.Sprintf("%[1]v.%[2]v = %[3]v AND %[1]v.%[4]v = CAST(%[5]v.%[6]v AS STRING)",
+			alias, sql_builder.QuoteIdentifier(view.WatchlistNameField), sql_builder.QuoteLiteral(lookup.Watchlist),
+			sql_builder.QuoteIdentifier(view.WatchlistValueField), source, lookup.Field)
+		expBuilder.
+			WithLookupJoin(getWatchlistIDFrom(s.cfg.ID), alias, view.LookupProcTimeField, condition).
+			WithField(lookup.Column, fmt.Sprintf("%v.%v IS NOT NULL", alias, sql_builder.QuoteIdentifier(view.WatchlistValueField)))
+	}
+	stmStr := sql_builder.NewViewSQLBuilder(getWatchlistLookupIDFrom(s.cfg.ID)).
+		WithExpression(expBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *RuleJobWorker) buildRuleSink() (string, error) {
 	id := getRuleSinkIDFrom(s.cfg.ID)
 	tableBuilder := sql_builder.NewTableSQLBuilder(id)
 	// build schema
 	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
-	for _, v := range getOrderedSchema(s.cfg.ProfilePredictorOutput.Schema) {
-		schemaBuilder.WithColumn(v[0], v[1])
-	}
-	//schemaBuilder.WithColumn(timestampField, data_type.TIMESTAMP_PRECISION(3))
-	schemaBuilder.WithColumn("alert_id", "STRING")
-	schemaBuilder.WithColumn("alert_time", "STRING")
-	schemaBuilder.WithColumn("rule_id", "STRING")
-	schemaBuilder.WithColumn("rule_name", "STRING")
-	schemaBuilder.WithColumn("technique", "STRING")
-	schemaBuilder.WithColumn("severity", "STRING")
-	schemaBuilder.WithColumn("risk_score", "BIGINT")
-	schemaBuilder.WithColumn("object", "STRING")
-
-	connectorBuilder := sql_builder.NewConnectorBuilder()
-	connectorBuilder.
-		WithOption("connector", "kafka").
-		WithOption("topic", s.cfg.RuleOutput.Topic).
-		WithOption("properties.bootstrap.servers", s.cfg.RuleOutput.BootstrapServer).
-		WithOption("properties.group.id", config.AppConfig.KafkaGroupID).
-		WithOption("format", "json")
+	for _, col := range s.cfg.AlertColumns(getRuleIDFrom(s.cfg.ID)) {
+		schemaBuilder.WithColumn(col.Name, col.Type)
+	}
+
+	connectorBuilder, err := s.cfg.RuleOutput.Connector()
+	if err != nil {
+		return "", err
+	}
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
 
 	stmStr := tableBuilder.
 		WithSchema(schemaBuilder.Build()).
 		WithConnector(connectorBuilder.Build()).
 		Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
-	}
-	return nil
+	return stmStr, nil
+}
+
+// buildTimezone always sets the session time zone, so the value of a previous job in the session is not inherited
+func (s *RuleJobWorker) buildTimezone() (string, error) {
+	return buildSetConfig("table.local-time-zone", s.cfg.GetAlertTimezone()), nil
 }
 
-func (s *RuleJobWorker) setName() error {
+func (s *RuleJobWorker) buildSetName() (string, error) {
 	setCfgBuilder := sql_builder.NewSetConfigSQLBuilder()
 	stmStr := setCfgBuilder.WithConfig("pipeline.name", fmt.Sprintf("rule_%v", s.cfg.ID)).Build()
-	fmt.Println(stmStr)
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return err
-	}
-	_, err = stm.GetOperationResult(0)
-	if err != nil {
-		return err
+	return stmStr, nil
+}
+
+func (s *RuleJobWorker) buildJob() (string, error) {
+	stmSetBuilder := sql_builder.NewStatementSetSQLBuilder()
+	stmStr := stmSetBuilder.
+		WithInsertStatement(s.buildInsert()).
+		Build()
+	return stmStr, nil
+}
+
+// buildInsert inserts the alerts of the rule into its sink, selecting the alert columns by name
+func (s *RuleJobWorker) buildInsert() string {
+	var columns []string
+	for _, col := range s.cfg.AlertColumns(getRuleIDFrom(s.cfg.ID)) {
+		columns = append(columns, col.Name)
 	}
-	return nil
+	ruleInsertBuilder := sql_builder.NewInsertSQLBuilder()
+	return ruleInsertBuilder.
+		WithDestinationTable(getRuleSinkIDFrom(s.cfg.ID)).
+		WithColumns(columns...).
+		WithExpression(s.buildAlerts()).
+		Build()
 }
 
-func (s *RuleJobWorker) createJob() (string, error) {
-	ruleSinkID := getRuleSinkIDFrom(s.cfg.ID)
+// buildAlerts selects the alert columns of the rule from its alerts, the suppressed alerts when it suppresses them
+func (s *RuleJobWorker) buildAlerts() string {
 	ruleID := getRuleIDFrom(s.cfg.ID)
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	for _, col := range s.cfg.AlertColumns(ruleID) {
+		projectionBuilder.WithField(col.Name, col.Expression)
+	}
+	alertsID := ruleID
+	if s.cfg.HasSuppression() {
+		alertsID = getSuppressionIDFrom(s.cfg.ID)
+	}
 	ruleExpBuilder := sql_builder.NewSelectSQLBuilder()
-	bhvExpStr := ruleExpBuilder.
-		WithFields(fmt.Sprintf("*,UUID(),'%v','%v',"+
-			"'%v','%v','%v',%v,%v as object", time.Now().Format("2006-01-02 15:04:05"), ruleID, s.cfg.Name, s.cfg.Technique, s.cfg.Severity, s.cfg.RiskScore, s.cfg.Object)).
-		WithQueryTable(ruleID).
-		Build()
-	bhvInsertBuilder := sql_builder.NewInsertSQLBuilder()
-	insertBhvStm := bhvInsertBuilder.
-		WithDestinationTable(ruleSinkID).
-		WithExpression(bhvExpStr).
+	return ruleExpBuilder.
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(alertsID).
 		Build()
+}
 
-	stmSetBuilder := sql_builder.NewStatementSetSQLBuilder()
-	stmStr := stmSetBuilder.
-		WithInsertStatement(insertBhvStm).
-		Build()
-	session := external.GetFlinkSQLSession()
-	stm, err := session.SubmitStatement(stmStr)
-	if err != nil {
-		return "", err
+// objects returns the tables and views the rule declares in the session, dependent views first
+func (s *RuleJobWorker) objects() (views []string, tables []string) {
+	if s.cfg.HasSuppression() {
+		views = append(views, getSuppressionIDFrom(s.cfg.ID), getSuppressionInputIDFrom(s.cfg.ID))
 	}
-	fmt.Println(stmStr)
-	opRes, err := stm.GetOperationResult(0)
-	if err != nil {
-		return "", err
+	views = append(views, getRuleIDFrom(s.cfg.ID))
+	if lookups, err := s.cfg.WatchlistLookups(); err == nil && len(lookups) > 0 {
+		views = append(views, getWatchlistLookupIDFrom(s.cfg.ID))
+		tables = append(tables, getWatchlistIDFrom(s.cfg.ID))
 	}
-	return opRes.JobID, nil
+	tables = append(tables, getRuleSinkIDFrom(s.cfg.ID))
+	return views, tables
 }
 
 func getRuleSinkIDFrom(ID string) string {
@@ -220,6 +315,22 @@ func getRuleIDFrom(ID string) string {
 	return "rule_" + ID
 }
 
+func getSuppressionInputIDFrom(ID string) string {
+	return "rule_suppression_input_" + ID
+}
+
+func getSuppressionIDFrom(ID string) string {
+	return "rule_suppressed_" + ID
+}
+
+func getWatchlistIDFrom(ID string) string {
+	return "rule_watchlist_" + ID
+}
+
+func getWatchlistLookupIDFrom(ID string) string {
+	return "rule_watchlist_lookup_" + ID
+}
+
 func getProfilePredictorIDFrom(ID string) string {
 	return "profiling_predictor_" + ID
 }
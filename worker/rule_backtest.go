@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"flink_ueba_manager/external"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/util"
+	"flink_ueba_manager/view"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type (
+	// RuleBacktestWorker replays a rule over a bounded range of its predictor topic in batch mode. It runs in a
+	// dedicated session and reads the alerts through the result API, nothing is written to the rule sink
+	RuleBacktestWorker struct {
+		rule       *RuleJobWorker
+		from       time.Time
+		to         time.Time
+		sampleSize int
+		timeout    time.Duration
+		logger     *logrus.Entry
+	}
+)
+
+func NewRuleBacktestWorker(req *view.RuleBacktestRequest) (*RuleBacktestWorker, error) {
+	from, to, err := req.GetRange()
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := req.GetTimeout()
+	if err != nil {
+		return nil, err
+	}
+	return &RuleBacktestWorker{
+		rule:       NewRuleJobWorker(req.Rule.ID, req.Rule),
+		from:       from,
+		to:         to,
+		sampleSize: req.GetSampleSize(),
+		timeout:    timeout,
+		logger:     logrus.WithField("rule_backtest", req.Rule.ID),
+	}, nil
+}
+
+// Plan renders the ordered statements declaring the alerts of the rule over the range and the settings of the
+// batch job, the queries of the backtest read the alerts
+func (s *RuleBacktestWorker) Plan() ([]string, error) {
+	return renderPlan(
+		s.buildProfilePredictorSource,
+		s.rule.buildWatchlistTable,
+		s.rule.buildWatchlistLookup,
+		s.rule.buildRule,
+		s.rule.buildSuppressionInput,
+		s.rule.buildSuppression,
+		s.buildAlerts,
+		s.buildRuntimeMode,
+		s.rule.buildTimezone,
+		s.buildSetName,
+	)
+}
+
+// Backtest runs the plan in a dedicated session, which is closed afterwards, then counts and samples the alerts
+func (s *RuleBacktestWorker) Backtest() (*view.RuleBacktestResult, error) {
+	plan, err := s.Plan()
+	if err != nil {
+		return nil, err
+	}
+	session, err := external.NewFlinkSQLGatewaySession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	for _, stmStr := range plan {
+		s.logger.Info(stmStr)
+		resolved, err := sql_builder.ResolveSecretOptions(stmStr, util.ResolveSecrets)
+		if err != nil {
+			return nil, err
+		}
+		stm, err := session.SubmitStatement(resolved)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := stm.GetOperationResult(0); err != nil {
+			return nil, errors.Wrapf(err, "statement '%v'", stmStr)
+		}
+	}
+	result := &view.RuleBacktestResult{
+		RuleID:     s.rule.cfg.ID,
+		From:       s.from.Format(time.RFC3339),
+		To:         s.to.Format(time.RFC3339),
+		Samples:    []map[string]interface{}{},
+		Statements: append(plan, s.countQuery(), s.sampleQuery()),
+	}
+	_, rows, err := s.query(session, s.countQuery())
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 && len(rows[0]) == 2 {
+		result.AlertCount = util.ParseInt64(rows[0][0])
+		result.ObjectCount = util.ParseInt64(rows[0][1])
+	}
+	columns, rows, err := s.query(session, s.sampleQuery())
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		sample := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if i < len(row) {
+				sample[col] = row[i]
+			}
+		}
+		result.Samples = append(result.Samples, sample)
+	}
+	return result, nil
+}
+
+// query runs a query of the backtest and fetches all its rows, each query starts its own batch job
+func (s *RuleBacktestWorker) query(session *external.FlinkSQLGatewaySession, stmStr string) ([]string, [][]interface{}, error) {
+	s.logger.Info(stmStr)
+	stm, err := session.SubmitStatement(stmStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, rows, err := stm.FetchRows(s.timeout)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "query '%v'", stmStr)
+	}
+	return columns, rows, nil
+}
+
+// buildProfilePredictorSource declares the predictor source bounded by the range, under a consumer group of its own
+// so the offsets of the rule job are left untouched
+func (s *RuleBacktestWorker) buildProfilePredictorSource() (string, error) {
+	cfg := s.rule.cfg.ProfilePredictorOutput
+	group := &view.RuleGroup{ID: s.rule.cfg.ID, Rules: []*view.RuleJobConfig{s.rule.cfg}}
+	return buildPredictorSource(s.rule.sourceID, group, func(connectorBuilder sql_builder.KafkaConnectorBuilder) {
+		connectorBuilder.
+			WithGroupID(cfg.GetGroupID("rule_backtest", s.rule.cfg.ID)).
+			WithStartupMode(sql_builder.KafkaStartupModeTimestamp).
+			WithStartupTimestamp(s.from.UnixMilli()).
+			WithBoundedMode(sql_builder.KafkaBoundedModeTimestamp).
+			WithBoundedTimestamp(s.to.UnixMilli())
+	})
+}
+
+// buildAlerts renders the view of the alerts the rule job would insert into its sink
+func (s *RuleBacktestWorker) buildAlerts() (string, error) {
+	stmStr := sql_builder.NewViewSQLBuilder(getRuleBacktestIDFrom(s.rule.cfg.ID)).
+		WithExpression(s.rule.buildAlerts()).
+		Build()
+	return stmStr, nil
+}
+
+func (s *RuleBacktestWorker) buildRuntimeMode() (string, error) {
+	return buildSetConfig("execution.runtime-mode", "batch"), nil
+}
+
+func (s *RuleBacktestWorker) buildSetName() (string, error) {
+	return buildSetConfig("pipeline.name", fmt.Sprintf("rule_backtest_%v", s.rule.cfg.ID)), nil
+}
+
+func (s *RuleBacktestWorker) countQuery() string {
+	projectionBuilder := sql_builder.NewProjectionSQLBuilder()
+	projectionBuilder.
+		WithField("alert_count", "COUNT(*)").
+		WithField("object_count", fmt.Sprintf("COUNT(DISTINCT %v)", sql_builder.QuoteIdentifier("object")))
+	return sql_builder.NewSelectSQLBuilder().
+		WithFields(projectionBuilder.Build()).
+		WithQueryTable(getRuleBacktestIDFrom(s.rule.cfg.ID)).
+		Build()
+}
+
+func (s *RuleBacktestWorker) sampleQuery() string {
+	expStr := sql_builder.NewSelectSQLBuilder().
+		WithFields("*").
+		WithQueryTable(getRuleBacktestIDFrom(s.rule.cfg.ID)).
+		Build()
+	return fmt.Sprintf("%v LIMIT %v", expStr, s.sampleSize)
+}
+
+func getRuleBacktestIDFrom(ID string) string {
+	return "rule_backtest_" + ID
+}
@@ -0,0 +1,202 @@
+package worker
+
+import (
+	"flink_ueba_manager/external"
+	"flink_ueba_manager/sql_builder"
+	"flink_ueba_manager/view"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RuleGroupJobNamePrefix prefixes the pipeline.name of the rule group jobs, followed by the group ID
+	RuleGroupJobNamePrefix = "rule_group_"
+
+	savepointPathConfig           = "execution.savepoint.path"
+	savepointUnclaimedStateConfig = "execution.savepoint.ignore-unclaimed-state"
+)
+
+type (
+	// RuleGroupJobWorker runs the rules of a group in one job: one predictor source and one insert per rule
+	RuleGroupJobWorker struct {
+		ID         string
+		group      *view.RuleGroup
+		rules      []*RuleJobWorker
+		flinkJobID string
+		// savepoint is the savepoint the job is restored from, see RestoreFrom
+		savepoint string
+		logger    *logrus.Entry
+	}
+)
+
+func NewRuleGroupJobWorker(group *view.RuleGroup) *RuleGroupJobWorker {
+	w := &RuleGroupJobWorker{
+		ID:     group.ID,
+		group:  group,
+		logger: logrus.WithField("rule_group", group.ID),
+	}
+	for _, rule := range group.Rules {
+		ruleWorker := NewRuleJobWorker(rule.ID, rule)
+		ruleWorker.sourceID = getRuleGroupSourceIDFrom(group.ID)
+		w.rules = append(w.rules, ruleWorker)
+	}
+	return w
+}
+
+// Stop stops the job of the group and drops the tables and views it declared in the session, so the group can be
+// deployed again with other rules
+func (s *RuleGroupJobWorker) Stop() error {
+	session := external.GetFlinkSQLSession()
+	if s.flinkJobID != "" {
+		// a job which already failed cannot be stopped, its tables and views are dropped all the same
+		if err := submitStatement(session, s.logger, sql_builder.StopJobStatement(s.flinkJobID)); err != nil {
+			s.logger.Warnf("error in stopping flink job %v: %v", s.flinkJobID, err)
+		}
+		s.flinkJobID = ""
+	}
+	return s.drop(session)
+}
+
+// StopWithSavepoint stops the job of the group like Stop after taking a savepoint, which the job of the group
+// deployed again restores. It returns the path of the savepoint, empty when the job was stopped without one
+func (s *RuleGroupJobWorker) StopWithSavepoint() (string, error) {
+	session := external.GetFlinkSQLSession()
+	savepoint := ""
+	if s.flinkJobID != "" {
+		var err error
+		if savepoint, err = stopJobWithSavepoint(session, s.logger, s.flinkJobID); err != nil {
+			s.logger.Warnf("error in stopping flink job %v with a savepoint, stopping it without one: %v", s.flinkJobID, err)
+			if err := submitStatement(session, s.logger, sql_builder.StopJobStatement(s.flinkJobID)); err != nil {
+				s.logger.Warnf("error in stopping flink job %v: %v", s.flinkJobID, err)
+			}
+		}
+		s.flinkJobID = ""
+	}
+	return savepoint, s.drop(session)
+}
+
+// drop drops the tables and views of the group declared in the session
+func (s *RuleGroupJobWorker) drop(session *external.FlinkSQLGatewaySession) error {
+	for _, stmStr := range s.dropStatements() {
+		if err := submitStatement(session, s.logger, stmStr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreFrom makes the job restore the savepoint of the job of the group stopped before. The state of the
+// operators missing from the job is ignored, since the job graph changes with the rules of the group
+func (s *RuleGroupJobWorker) RestoreFrom(savepoint string) {
+	s.savepoint = savepoint
+}
+
+func (s *RuleGroupJobWorker) Run() error {
+	plan, err := s.Plan()
+	if err != nil {
+		return err
+	}
+	if s.savepoint != "" {
+		// the savepoint settings stay in the shared session, the next jobs must not restore the savepoint
+		defer s.resetSavepoint()
+	}
+	jobID, err := submitPlan(s.logger, plan)
+	if err != nil {
+		return err
+	}
+	s.flinkJobID = jobID
+	s.logger.Infof("done creating flink job %v for rules %v", jobID, s.group.RuleIDs())
+	return nil
+}
+
+// FlinkJobID returns the ID of the job of the group, empty until it is started
+func (s *RuleGroupJobWorker) FlinkJobID() string {
+	return s.flinkJobID
+}
+
+// Plan renders the ordered statements of the job: the predictor source, the tables and views of each rule,
+// settings and the statement set
+func (s *RuleGroupJobWorker) Plan() ([]string, error) {
+	builders := []func() (string, error){s.buildProfilePredictorSource}
+	for _, rule := range s.rules {
+		builders = append(builders,
+			rule.buildWatchlistTable,
+			rule.buildWatchlistLookup,
+			rule.buildRule,
+			rule.buildSuppressionInput,
+			rule.buildSuppression,
+			rule.buildRuleSink,
+		)
+	}
+	builders = append(builders, setIdleTimeout(s.group.Rules[0].ProfilePredictorOutput), s.buildTimezone, s.buildSavepoint, s.buildSavepointUnclaimedState, s.buildSetName, s.buildJob)
+	return renderPlan(builders...)
+}
+
+func (s *RuleGroupJobWorker) buildProfilePredictorSource() (string, error) {
+	return buildPredictorSource(getRuleGroupSourceIDFrom(s.ID), s.group, func(connectorBuilder sql_builder.KafkaConnectorBuilder) {
+		s.group.Rules[0].ProfilePredictorOutput.ApplyStartup(connectorBuilder, "rule_group", s.ID)
+	})
+}
+
+// buildTimezone always sets the session time zone, which the rules of a group share
+func (s *RuleGroupJobWorker) buildTimezone() (string, error) {
+	return buildSetConfig("table.local-time-zone", s.group.Rules[0].GetAlertTimezone()), nil
+}
+
+func (s *RuleGroupJobWorker) buildSavepoint() (string, error) {
+	if s.savepoint == "" {
+		return "", nil
+	}
+	return buildSetConfig(savepointPathConfig, s.savepoint), nil
+}
+
+func (s *RuleGroupJobWorker) buildSavepointUnclaimedState() (string, error) {
+	if s.savepoint == "" {
+		return "", nil
+	}
+	return buildSetConfig(savepointUnclaimedStateConfig, "true"), nil
+}
+
+func (s *RuleGroupJobWorker) resetSavepoint() {
+	session := external.GetFlinkSQLSession()
+	for _, key := range []string{savepointPathConfig, savepointUnclaimedStateConfig} {
+		if err := submitStatement(session, s.logger, sql_builder.ResetConfigStatement(key)); err != nil {
+			s.logger.Errorf("error in resetting %v: %v", key, err)
+		}
+	}
+}
+
+func (s *RuleGroupJobWorker) buildSetName() (string, error) {
+	return buildSetConfig("pipeline.name", RuleGroupJobNamePrefix+s.ID), nil
+}
+
+// buildJob inserts the alerts of every rule in one statement set, so the rules share the predictor source
+func (s *RuleGroupJobWorker) buildJob() (string, error) {
+	stmSetBuilder := sql_builder.NewStatementSetSQLBuilder()
+	for _, rule := range s.rules {
+		stmSetBuilder.WithInsertStatement(rule.buildInsert())
+	}
+	return stmSetBuilder.Build(), nil
+}
+
+// dropStatements drops the views of the rules before the tables they read
+func (s *RuleGroupJobWorker) dropStatements() []string {
+	var views, tables []string
+	for _, rule := range s.rules {
+		ruleViews, ruleTables := rule.objects()
+		views = append(views, ruleViews...)
+		tables = append(tables, ruleTables...)
+	}
+	tables = append(tables, getRuleGroupSourceIDFrom(s.ID))
+	var statements []string
+	for _, v := range views {
+		statements = append(statements, sql_builder.DropViewStatement(v))
+	}
+	for _, t := range tables {
+		statements = append(statements, sql_builder.DropTableStatement(t))
+	}
+	return statements
+}
+
+func getRuleGroupSourceIDFrom(ID string) string {
+	return "profiling_predictor_group_" + ID
+}
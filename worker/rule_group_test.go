@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"encoding/json"
+	"flink_ueba_manager/view"
+	"strings"
+	"testing"
+)
+
+func newTestRuleGroup(t *testing.T, names ...string) *view.RuleGroup {
+	var rules []*view.RuleJobConfig
+	for _, name := range names {
+		cfg := &view.RuleJobConfig{}
+		if err := json.Unmarshal([]byte(testRules[name]), cfg); err != nil {
+			t.Fatal(err)
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, cfg)
+	}
+	groups := view.GroupRules(rules)
+	if len(groups) != 1 {
+		t.Fatalf("the rules make %v groups, want one", len(groups))
+	}
+	return groups[0]
+}
+
+// TestRuleGroupRestorePlan checks a restored group sets the savepoint to restore before its statement set
+func TestRuleGroupRestorePlan(t *testing.T) {
+	w := NewRuleGroupJobWorker(newTestRuleGroup(t, "plain", "suppressed"))
+	plan, err := w.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stm := range plan {
+		if strings.Contains(stm, "execution.savepoint") {
+			t.Errorf("the plan of a new group restores a savepoint: %v", stm)
+		}
+	}
+
+	w.RestoreFrom("s3://flink/savepoints/savepoint-1")
+	restored, err := w.Plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != len(plan)+2 {
+		t.Fatalf("the restored plan has %v statements, want %v", len(restored), len(plan)+2)
+	}
+	settings := strings.Join(restored[len(restored)-5:len(restored)-1], "\n")
+	for _, want := range []string{
+		"SET 'execution.savepoint.path' = 's3://flink/savepoints/savepoint-1'",
+		"SET 'execution.savepoint.ignore-unclaimed-state' = 'true'",
+	} {
+		if !strings.Contains(settings, want) {
+			t.Errorf("missing %v before the statement set in:\n%v", want, settings)
+		}
+	}
+}
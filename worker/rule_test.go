@@ -0,0 +1,339 @@
+package worker
+
+import (
+	"encoding/json"
+	"flink_ueba_manager/config"
+	"flink_ueba_manager/view"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testPredictorConfig = `"bootstrap.servers": "kafka:9092", "topic": "predictions",
+	"schema": {"entities": "STRING", "attributes": "STRING", "cnt": "BIGINT", "window_start": "TIMESTAMP(3)", "event_ts": "BIGINT"}`
+
+// testRules are rules whose alerts take different paths to the sink
+var testRules = map[string]string{
+	"plain": `{"id": "plain", "name": "Many logins", "filter": "cnt > 10", "object": "entities",
+		"technique": "T1078", "severity": "high", "risk_score": 50,
+		"profile_predictor_config": {` + testPredictorConfig + `},
+		"rule_output_config": {"bootstrap.servers": "kafka:9092", "topic": "alerts"}}`,
+	"suppressed": `{"id": "suppressed", "name": "Many logins", "filter": "cnt > 10", "object": "entities",
+		"technique": "T1078", "severity": "medium", "risk_score": 20,
+		"profile_predictor_config": {` + testPredictorConfig + `},
+		"rule_output_config": {"bootstrap.servers": "kafka:9092", "topic": "alerts"},
+		"suppression": {"duration": "10m", "group_by": ["entities", "attributes"], "max_alerts": 2}}`,
+	"metadata": `{"id": "metadata", "name": "Many logins", "filter": "cnt > 10", "object_label": "fleet",
+		"technique": "T1078", "severity": "low", "risk_score": 10,
+		"profile_predictor_config": {` + testPredictorConfig + `, "metadata": ["timestamp", "partition", "offset"]},
+		"rule_output_config": {"bootstrap.servers": "kafka:9092", "topic": "alerts"},
+		"suppression": {"duration": "1h"}}`,
+	"event_time": `{"id": "event_time", "name": "Many logins", "filter": "cnt > 10", "object": "entities",
+		"technique": "T1078", "severity": "critical", "risk_score": 90,
+		"profile_predictor_config": {` + testPredictorConfig + `, "timestamp_field": "event_ts", "timestamp_format": "epoch_ms"},
+		"rule_output_config": {"bootstrap.servers": "kafka:9092", "topic": "alerts"},
+		"alert_time": {"source": "event", "format": "yyyy-MM-dd HH:mm:ss", "timezone": "Asia/Ho_Chi_Minh"},
+		"suppression": {"duration": "5m"}}`,
+}
+
+// TestRuleSinkColumns checks the alerts a rule inserts match the columns of its sink by name, type and order
+func TestRuleSinkColumns(t *testing.T) {
+	for name, cfgStr := range testRules {
+		t.Run(name, func(t *testing.T) {
+			cfg := &view.RuleJobConfig{}
+			if err := json.Unmarshal([]byte(cfgStr), cfg); err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			w := NewRuleJobWorker(cfg.ID, cfg)
+			plan, err := w.Plan()
+			if err != nil {
+				t.Fatal(err)
+			}
+			catalog := newTestCatalog(t)
+			for _, stm := range plan {
+				catalog.declare(stm)
+			}
+
+			sinkStm, err := w.buildRuleSink()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := catalog.tableColumns(sinkStm)
+
+			insert := w.buildInsert()
+			m := regexp.MustCompile("^INSERT INTO (\\w+) \\((.*?)\\) (SELECT .*)$").FindStringSubmatch(insert)
+			if m == nil {
+				t.Fatalf("unexpected insert %v", insert)
+			}
+			if m[1] != getRuleSinkIDFrom(cfg.ID) {
+				t.Errorf("the alerts are inserted into %v instead of the sink", m[1])
+			}
+			var insertColumns []string
+			for _, col := range splitTopLevel(m[2], ',') {
+				insertColumns = append(insertColumns, strings.Trim(col, "`"))
+			}
+			alerts := catalog.queryColumns(m[3])
+
+			if len(sink) != len(insertColumns) || len(sink) != len(alerts) {
+				t.Fatalf("the sink has %v columns, the insert names %v and selects %v", len(sink), len(insertColumns), len(alerts))
+			}
+			for i := range sink {
+				if insertColumns[i] != sink[i].name || alerts[i].name != sink[i].name {
+					t.Errorf("column %v is %v in the sink, %v in the insert and %v in the alerts", i, sink[i].name, insertColumns[i], alerts[i].name)
+				}
+				if normalizeType(alerts[i].typ) != normalizeType(sink[i].typ) {
+					t.Errorf("column %v is %v in the sink but %v in the alerts", sink[i].name, sink[i].typ, alerts[i].typ)
+				}
+			}
+		})
+	}
+}
+
+// TestRuleWatchlistTable checks the watchlist table is looked up in the database the manager writes it to
+func TestRuleWatchlistTable(t *testing.T) {
+	defaultConfig := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = defaultConfig })
+	config.AppConfig = config.DefaultConfig()
+
+	cfg := &view.RuleJobConfig{}
+	if err := json.Unmarshal([]byte(testRules["plain"]), cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Filter = "cnt > 10 AND entities IN WATCHLIST('admins')"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "WATCHLIST needs the watchlist table") {
+		t.Errorf("expected the watchlist table to be required, got %v", err)
+	}
+
+	config.AppConfig.Watchlist.URL = "jdbc:postgresql://db:5432/ueba"
+	config.AppConfig.Watchlist.Username = "ueba"
+	config.AppConfig.Watchlist.Password = "env:WATCHLIST_DB_PASSWORD"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewRuleJobWorker(cfg.ID, cfg).buildWatchlistTable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE rule_watchlist_plain(watchlist_name STRING,watchlist_value STRING) WITH ('connector' = 'jdbc'," +
+		"'url' = 'jdbc:postgresql://db:5432/ueba','table-name' = 'ueba_watchlist','lookup.cache.max-rows' = '10000'," +
+		"'lookup.cache.ttl' = '60000 ms','username' = 'ueba','password' = '${secret:env:WATCHLIST_DB_PASSWORD}')"
+	if got != want {
+		t.Errorf("got  %v\nwant %v", got, want)
+	}
+}
+
+type (
+	testColumn struct {
+		name string
+		typ  string
+	}
+	// testCatalog types the columns of the tables and views of a plan from their statements
+	testCatalog struct {
+		t       *testing.T
+		objects map[string][]testColumn
+	}
+)
+
+func newTestCatalog(t *testing.T) *testCatalog {
+	return &testCatalog{t: t, objects: make(map[string][]testColumn)}
+}
+
+var (
+	createTableRegexp = regexp.MustCompile(`^CREATE TABLE (\w+)\(`)
+	createViewRegexp  = regexp.MustCompile(`^CREATE VIEW (\w+) AS (SELECT .*)$`)
+	identifierRegexp  = regexp.MustCompile("^(\\w+\\.)?`?(\\w+)`?$")
+	castRegexp        = regexp.MustCompile(`^CAST\((.*) AS ([^()]+(\(\d+\))?)\)$`)
+	arithmeticRegexp  = regexp.MustCompile(`^(.*) [-+] \d+$`)
+	countRegexp       = regexp.MustCompile("COUNT\\(\\*\\) AS `(\\w+)`")
+	fromTableRegexp   = regexp.MustCompile(`(?:TABLE|FROM) ([a-z]\w*)`)
+)
+
+func (c *testCatalog) declare(stm string) {
+	if m := createTableRegexp.FindStringSubmatch(stm); m != nil {
+		c.objects[m[1]] = c.tableColumns(stm)
+	} else if m := createViewRegexp.FindStringSubmatch(stm); m != nil {
+		c.objects[m[1]] = c.queryColumns(m[2])
+	}
+}
+
+// tableColumns types the physical, metadata and computed columns of a CREATE TABLE statement
+func (c *testCatalog) tableColumns(stm string) []testColumn {
+	start := strings.Index(stm, "(")
+	end := matchingParen(stm, start)
+	var columns []testColumn
+	for _, def := range splitTopLevel(stm[start+1:end], ',') {
+		if strings.HasPrefix(def, "WATERMARK ") || strings.HasPrefix(def, "PRIMARY KEY") {
+			continue
+		}
+		name, rest, _ := strings.Cut(def, " ")
+		switch {
+		case strings.HasPrefix(rest, "AS "):
+			columns = append(columns, testColumn{name: name, typ: c.exprType(strings.TrimPrefix(rest, "AS "), columns)})
+		case strings.Contains(rest, " METADATA"):
+			columns = append(columns, testColumn{name: name, typ: rest[:strings.Index(rest, " METADATA")]})
+		default:
+			columns = append(columns, testColumn{name: name, typ: rest})
+		}
+	}
+	return columns
+}
+
+// queryColumns types the columns selected by a query from the table or view it reads
+func (c *testCatalog) queryColumns(query string) []testColumn {
+	query = strings.TrimPrefix(query, "SELECT ")
+	from := indexTopLevel(query, " FROM ")
+	if from < 0 {
+		c.t.Fatalf("no FROM in %v", query)
+	}
+	fields, source := query[:from], query[from+len(" FROM "):]
+	m := fromTableRegexp.FindStringSubmatch("FROM " + source)
+	input, ok := c.objects[m[1]]
+	if !ok {
+		c.t.Fatalf("unknown table %v", m[1])
+	}
+	for _, count := range countRegexp.FindAllStringSubmatch(source, -1) {
+		input = append(input, testColumn{name: count[1], typ: "BIGINT"})
+	}
+	var columns []testColumn
+	for _, field := range splitTopLevel(fields, ',') {
+		if field == "*" || strings.HasSuffix(field, ".*") {
+			columns = append(columns, c.objects[m[1]]...)
+			continue
+		}
+		as := lastIndexTopLevel(field, " AS ")
+		if as < 0 {
+			c.t.Fatalf("field without alias %v", field)
+		}
+		columns = append(columns, testColumn{name: strings.Trim(field[as+len(" AS "):], "`"), typ: c.exprType(field[:as], input)})
+	}
+	return columns
+}
+
+// exprType types the expressions the plans of rules select
+func (c *testCatalog) exprType(expr string, input []testColumn) string {
+	expr = strings.TrimSpace(expr)
+	if m := identifierRegexp.FindStringSubmatch(expr); m != nil {
+		for _, col := range input {
+			if col.name == m[2] {
+				return col.typ
+			}
+		}
+		c.t.Fatalf("unknown column %v", expr)
+	}
+	if m := castRegexp.FindStringSubmatch(expr); m != nil && matchingParen(expr, len("CAST")) == len(expr)-1 {
+		return m[2]
+	}
+	if m := arithmeticRegexp.FindStringSubmatch(expr); m != nil {
+		return c.exprType(m[1], input)
+	}
+	switch {
+	case strings.HasPrefix(expr, "'") && strings.HasSuffix(expr, "'"):
+		return "STRING"
+	case strings.HasSuffix(expr, " IS NOT NULL"), strings.HasSuffix(expr, " IS NULL"):
+		return "BOOLEAN"
+	case expr == "UUID()", strings.HasPrefix(expr, "DATE_FORMAT("), strings.HasPrefix(expr, "CONCAT_WS("):
+		return "STRING"
+	case expr == "PROCTIME()", expr == "CURRENT_ROW_TIMESTAMP()", strings.HasPrefix(expr, "TO_TIMESTAMP_LTZ("):
+		return "TIMESTAMP_LTZ(3)"
+	case strings.HasPrefix(expr, "GREATEST("):
+		args := splitTopLevel(expr[len("GREATEST("):len(expr)-1], ',')
+		return c.exprType(args[0], input)
+	}
+	c.t.Fatalf("cannot type %v", expr)
+	return ""
+}
+
+func normalizeType(typ string) string {
+	return strings.ToUpper(strings.ReplaceAll(typ, " ", ""))
+}
+
+// splitTopLevel splits s on sep outside parentheses, literals and quoted identifiers
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == sep && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// indexTopLevel returns the first index of substr in s outside parentheses and literals
+func indexTopLevel(s string, substr string) int {
+	for _, i := range topLevelIndexes(s, substr) {
+		return i
+	}
+	return -1
+}
+
+func lastIndexTopLevel(s string, substr string) int {
+	indexes := topLevelIndexes(s, substr)
+	if len(indexes) == 0 {
+		return -1
+	}
+	return indexes[len(indexes)-1]
+}
+
+func topLevelIndexes(s string, substr string) []int {
+	var indexes []int
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], substr):
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// matchingParen returns the index of the parenthesis closing the one at open
+func matchingParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '`':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"flink_ueba_manager/sql_builder"
+	"fmt"
+	"time"
+)
+
+type (
+	// kafkaSourceConfig is the config of a Kafka topic a job reads
+	kafkaSourceConfig interface {
+		OrderedSchema() [][]string
+		ApplyMetadataColumns(builder sql_builder.SchemaSQLBuilder)
+		ApplyTopic(builder sql_builder.KafkaConnectorBuilder)
+		ApplyFormat(builder sql_builder.KafkaConnectorBuilder)
+		ApplyStartup(builder sql_builder.KafkaConnectorBuilder, kind string, jobID string)
+		ApplySecurity(builder sql_builder.KafkaConnectorBuilder)
+		EventTime() sql_builder.TimestampExpSQLBuilder
+		GetWatermarkDelay() (time.Duration, error)
+		idleTimeoutConfig
+	}
+	// idleTimeoutConfig is the config of the idle timeout of the sources of a job
+	idleTimeoutConfig interface {
+		GetIdleTimeout() (time.Duration, error)
+	}
+	// sourceColumn adds a computed column, such as a time attribute, to the schema of a source
+	sourceColumn func(schemaBuilder sql_builder.SchemaSQLBuilder) error
+)
+
+// buildKafkaSource declares the source table of a topic: the columns of its schema and metadata, then the computed
+// columns. startup sets where the job starts reading
+func buildKafkaSource(id string, cfg kafkaSourceConfig, startup func(sql_builder.KafkaConnectorBuilder), columns ...sourceColumn) (string, error) {
+	tableBuilder := sql_builder.NewTableSQLBuilder(id)
+	// build schema
+	schemaBuilder := sql_builder.NewSchemaSQLBuilder()
+	for _, v := range cfg.OrderedSchema() {
+		schemaBuilder.WithColumn(v[0], v[1])
+	}
+	cfg.ApplyMetadataColumns(schemaBuilder)
+	for _, column := range columns {
+		if err := column(schemaBuilder); err != nil {
+			return "", err
+		}
+	}
+	// build connector
+	connectorBuilder := sql_builder.NewKafkaConnectorBuilder()
+	cfg.ApplyTopic(connectorBuilder)
+	cfg.ApplyFormat(connectorBuilder)
+	startup(connectorBuilder)
+	cfg.ApplySecurity(connectorBuilder)
+	if err := connectorBuilder.Err(); err != nil {
+		return "", err
+	}
+
+	stmStr := tableBuilder.
+		WithSchema(schemaBuilder.Build()).
+		WithConnector(connectorBuilder.Build()).
+		Build()
+	return stmStr, nil
+}
+
+// jobStartup starts the source with the consumer group and startup mode of its config
+func jobStartup(cfg kafkaSourceConfig, kind string, jobID string) func(sql_builder.KafkaConnectorBuilder) {
+	return func(connectorBuilder sql_builder.KafkaConnectorBuilder) {
+		cfg.ApplyStartup(connectorBuilder, kind, jobID)
+	}
+}
+
+// eventTimeColumn declares the event time of the source as the watermarked column name
+func eventTimeColumn(cfg kafkaSourceConfig, name string) sourceColumn {
+	return func(schemaBuilder sql_builder.SchemaSQLBuilder) error {
+		watermarkDelay, err := cfg.GetWatermarkDelay()
+		if err != nil {
+			return err
+		}
+		schemaBuilder.WithEventTimeField(name, cfg.EventTime().Build(), watermarkDelay)
+		return nil
+	}
+}
+
+// procTimeColumn declares a processing time attribute
+func procTimeColumn(name string) sourceColumn {
+	return func(schemaBuilder sql_builder.SchemaSQLBuilder) error {
+		schemaBuilder.WithComputedColumn(name, "PROCTIME()")
+		return nil
+	}
+}
+
+// setIdleTimeout always sets the idle timeout of the source, so the value of a previous job in the session is not
+// inherited
+func setIdleTimeout(cfg idleTimeoutConfig) func() (string, error) {
+	return func() (string, error) {
+		idleTimeout, err := cfg.GetIdleTimeout()
+		if err != nil {
+			return "", err
+		}
+		return buildSetConfig("table.exec.source.idle-timeout", fmt.Sprintf("%v ms", idleTimeout.Milliseconds())), nil
+	}
+}
+
+// setTimezone always sets the session time zone, so the value of a previous job in the session is not inherited.
+// Jobs on event times use UTC, the zone event times are normalized to
+func setTimezone(timezone string) func() (string, error) {
+	return func() (string, error) {
+		return buildSetConfig("table.local-time-zone", timezone), nil
+	}
+}